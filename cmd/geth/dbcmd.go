@@ -258,6 +258,14 @@ func removeDB(ctx *cli.Context) error {
 	} else {
 		log.Info("Full node ancient database missing", "path", path)
 	}
+	// Remove the private chain database, including the ancient store of its own
+	// it had in earlier versions
+	path = stack.ResolvePath("privatechaindata")
+	if common.FileExist(path) {
+		confirmAndRemoveDB(path, "private chain database")
+	} else {
+		log.Info("Private chain database missing", "path", path)
+	}
 	// Remove the light node database
 	path = stack.ResolvePath("lightchaindata")
 	if common.FileExist(path) {
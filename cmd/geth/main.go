@@ -81,6 +81,9 @@ var (
 		utils.EthashDatasetsInMemoryFlag,
 		utils.EthashDatasetsOnDiskFlag,
 		utils.EthashDatasetsLockMmapFlag,
+		utils.EthashHashrateFlag,
+		utils.EthashBlockIntervalFlag,
+		utils.EthashScaleDifficultyFlag,
 		utils.TxPoolLocalsFlag,
 		utils.TxPoolNoLocalsFlag,
 		utils.TxPoolJournalFlag,
@@ -132,8 +135,35 @@ var (
 		utils.MinerRecommitIntervalFlag,
 		utils.MinerNoVerifyFlag,
 		utils.MinerStrategyFlag,
+		utils.MinerTrailDepthFlag,
+		utils.MinerPolicyFileFlag,
+		utils.MinerTimestampsFlag,
 		utils.MinerLogFileFlag,
+		utils.MinerLogFileSizeFlag,
 		utils.MinerEclipsePeersFlag,
+		utils.MinerEclipseBlocksFlag,
+		utils.MinerEclipseBlockDelayFlag,
+		utils.MinerEclipseTxsFlag,
+		utils.MinerEclipseTxDelayFlag,
+		utils.MinerEclipseFilterChainFlag,
+		utils.MinerGammaFlag,
+		utils.MinerGammaPeersFlag,
+		utils.MinerGammaDelayFlag,
+		utils.MinerComparisonFlag,
+		utils.MinerTieDifficultyFlag,
+		utils.MinerTieBlocksFlag,
+		utils.MinerPoolFlag,
+		utils.MinerIntermittentFlag,
+		utils.MinerIntermittentSelfishBlocksFlag,
+		utils.MinerIntermittentHonestBlocksFlag,
+		utils.MinerIntermittentSelfishTimeFlag,
+		utils.MinerIntermittentHonestTimeFlag,
+		utils.MinerIntermittentSelfishAboveFlag,
+		utils.MinerIntermittentHonestBelowFlag,
+		utils.MinerIntermittentPublishFlag,
+		utils.ExperimentSeedFlag,
+		utils.ForkChoiceFlag,
+		utils.ForkChoiceWindowFlag,
 		utils.NATFlag,
 		utils.NoDiscoverFlag,
 		utils.DiscoveryV5Flag,
@@ -243,6 +273,8 @@ func init() {
 		utils.ShowDeprecated,
 		// See snapshot.go
 		snapshotCommand,
+		// See selfishcmd.go
+		selfishCommand,
 	}
 	sort.Sort(cli.CommandsByName(app.Commands))
 
@@ -0,0 +1,480 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/miner/logic"
+	"github.com/ethereum/go-ethereum/miner/logic/simulation"
+	"github.com/ethereum/go-ethereum/miner/logic/testbed"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/olekukonko/tablewriter"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	selfishAlphaFlag = cli.Float64Flag{
+		Name:  "alpha",
+		Usage: "Hashrate share of the attacker",
+		Value: 1.0 / 3,
+	}
+	selfishGammaFlag = cli.Float64Flag{
+		Name:  "gamma",
+		Usage: "Share of the honest hashrate mining on the attacker's block in a tie",
+		Value: 0,
+	}
+	selfishTruncationFlag = cli.IntFlag{
+		Name:  "truncation",
+		Usage: "Maximum branch length covered by the policy table",
+		Value: 30,
+	}
+	selfishEpsilonFlag = cli.Float64Flag{
+		Name:  "epsilon",
+		Usage: "Precision of the computed relative revenue",
+		Value: 1e-6,
+	}
+	selfishFromFlag = cli.Uint64Flag{
+		Name:  "from",
+		Usage: "Number of the first block to account",
+		Value: 1,
+	}
+	selfishToFlag = cli.Uint64Flag{
+		Name:  "to",
+		Usage: "Number of the last block to account (default = head)",
+	}
+	selfishWindowFlag = cli.Uint64Flag{
+		Name:  "window",
+		Usage: "Number of blocks per accounting window (0 = no windows)",
+	}
+	selfishAttackerFlag = cli.StringFlag{
+		Name:  "attacker",
+		Usage: "Coinbase of the attacker whose share is reported",
+	}
+	selfishJSONFlag = cli.BoolFlag{
+		Name:  "json",
+		Usage: "Print the report as JSON",
+	}
+	selfishMinRacesFlag = cli.Uint64Flag{
+		Name:  "races",
+		Usage: "Number of races a coinbase must have taken part in before its win rate is judged",
+		Value: logic.DefaultDetectConfig.MinRaces,
+	}
+	selfishWinRateFlag = cli.Float64Flag{
+		Name:  "winrate",
+		Usage: "Share of won races above which a coinbase is flagged",
+		Value: logic.DefaultDetectConfig.RaceWinRate,
+	}
+	selfishStrategyFlag = cli.StringFlag{
+		Name:  "strategy",
+		Usage: "Mining strategy of the attacker (" + strings.Join(logic.Names(), ", ") + ")",
+		Value: logic.SelfishAllUncles,
+	}
+	selfishAlphasFlag = cli.StringFlag{
+		Name:  "alphas",
+		Usage: "Comma separated hashrate shares of the attacker to simulate",
+		Value: "0.05,0.1,0.15,0.2,0.25,0.3,0.35,0.4,0.45",
+	}
+	selfishDelayFlag = cli.Float64Flag{
+		Name:  "delay",
+		Usage: "Propagation delay between the attacker and the honest network, in mean block intervals",
+	}
+	selfishBlocksFlag = cli.IntFlag{
+		Name:  "blocks",
+		Usage: "Number of blocks mined per run",
+		Value: 10000,
+	}
+	selfishRunsFlag = cli.IntFlag{
+		Name:  "runs",
+		Usage: "Number of runs per hashrate share",
+		Value: 10,
+	}
+	selfishSeedFlag = cli.Int64Flag{
+		Name:  "seed",
+		Usage: "Seed of the first run, incremented for every further run",
+		Value: 1,
+	}
+
+	selfishCommand = cli.Command{
+		Name:        "selfish",
+		Usage:       "A set of commands for selfish mining experiments",
+		Category:    "MISCELLANEOUS COMMANDS",
+		Description: "",
+		Subcommands: []cli.Command{
+			{
+				Name:      "solve",
+				Usage:     "Compute the optimal selfish mining policy table",
+				ArgsUsage: "<output>",
+				Action:    utils.MigrateFlags(solvePolicy),
+				Category:  "MISCELLANEOUS COMMANDS",
+				Flags: []cli.Flag{
+					selfishAlphaFlag,
+					selfishGammaFlag,
+					selfishTruncationFlag,
+					selfishEpsilonFlag,
+				},
+				Description: `
+geth selfish solve --alpha 0.35 --gamma 0.5 policy.json
+solves the selfish mining MDP of Sapirshtein et al. for the given hashrate share
+and network capability of the attacker, and writes the policy table to the given
+file. The table can be followed by a node running --miner.strategy=optimal with
+--miner.policy pointing to the file.
+`,
+			},
+			{
+				Name:     "revenue",
+				Usage:    "Account the revenue of the miners on the canonical chain",
+				Action:   utils.MigrateFlags(accountRevenue),
+				Category: "MISCELLANEOUS COMMANDS",
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.CacheFlag,
+					utils.SyncModeFlag,
+					selfishFromFlag,
+					selfishToFlag,
+					selfishWindowFlag,
+					selfishAttackerFlag,
+					selfishJSONFlag,
+				},
+				Description: `
+geth selfish revenue --attacker 0x... --window 100
+walks the canonical chain of an existing datadir and attributes the block, uncle
+and nephew rewards and the transaction fees to the coinbases. It reports the
+absolute and relative revenue and the orphaned blocks of every miner, and the
+share of the attacker per window. The node must not be running.
+`,
+			},
+			{
+				Name:     "detect",
+				Usage:    "Look for signals of selfish mining in the chain",
+				Action:   utils.MigrateFlags(detectSelfishMining),
+				Category: "MISCELLANEOUS COMMANDS",
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.CacheFlag,
+					utils.SyncModeFlag,
+					selfishFromFlag,
+					selfishToFlag,
+					selfishMinRacesFlag,
+					selfishWinRateFlag,
+					selfishJSONFlag,
+				},
+				Description: `
+geth selfish detect --from 1000
+walks the canonical chain and the stored side branches of an existing datadir
+and reports, for every coinbase, its fork and uncle rates, the races it took
+part in and won, and its longest run of consecutive blocks. Coinbases winning
+an unusual share of the races, with a fork rate well below the other miners or
+with unlikely long runs are flagged. Withheld and bursty releases are detected
+from the times the node first saw the blocks, recorded as they arrived from its
+peers. The node must not be running.
+`,
+			},
+			{
+				Name:     "simulate",
+				Usage:    "Simulate a mining strategy against an honest network",
+				Action:   utils.MigrateFlags(simulateStrategy),
+				Category: "MISCELLANEOUS COMMANDS",
+				Flags: []cli.Flag{
+					selfishStrategyFlag,
+					selfishAlphasFlag,
+					selfishGammaFlag,
+					selfishDelayFlag,
+					selfishBlocksFlag,
+					selfishRunsFlag,
+					selfishSeedFlag,
+					utils.MinerTrailDepthFlag,
+					utils.MinerPolicyFileFlag,
+				},
+				Description: `
+geth selfish simulate --strategy selfish-all-uncles --gamma 0.5
+runs the strategy against a simulated honest network for every given hashrate
+share of the attacker. Blocks are found according to a Poisson process and
+processed by in-memory chains, without networking and proof-of-work. The mean
+relative revenue of the attacker over all runs is printed as CSV, along with
+the closed form revenue of selfish mining by Eyal and Sirer.
+`,
+			},
+			{
+				Name:      "testbed",
+				Usage:     "Run a scenario on a network of full nodes",
+				ArgsUsage: "<scenario> <resultsdir>",
+				Action:    utils.MigrateFlags(runTestbed),
+				Category:  "MISCELLANEOUS COMMANDS",
+				Description: `
+geth selfish testbed scenario.json results/
+starts the nodes of the scenario file as full eth nodes in this process,
+connected by in-memory pipes, and lets them mine for the duration of the
+scenario. A scenario looks like:
+
+  {
+    "duration": "10m",
+    "seed": 1,
+    "blockInterval": "13s",
+    "nodes": [
+      {"name": "attacker", "strategy": "selfish-all-uncles", "hashrate": 0.33, "eclipse": ["victim"]},
+      {"name": "honest", "hashrate": 0.67},
+      {"name": "victim"}
+    ],
+    "links": [["attacker", "honest"], ["honest", "victim"], ["attacker", "victim"]]
+  }
+
+All nodes are connected if no links are given. The hashrate shares are
+emulated by sealing blocks after exponentially distributed delays, such that
+all nodes together find a block every "blockInterval" (default 13s). The
+results directory receives the final chain and revenue of every node as
+results.json, and the mining event log of every node.
+`,
+			},
+		},
+	}
+)
+
+func solvePolicy(ctx *cli.Context) error {
+	if ctx.NArg() != 1 {
+		utils.Fatalf("This command requires an argument.")
+	}
+	var (
+		alpha      = ctx.Float64(selfishAlphaFlag.Name)
+		gamma      = ctx.Float64(selfishGammaFlag.Name)
+		truncation = ctx.Int(selfishTruncationFlag.Name)
+	)
+	log.Info("Solving selfish mining MDP", "alpha", alpha, "gamma", gamma, "truncation", truncation)
+	table, err := logic.Solve(alpha, gamma, truncation, ctx.Float64(selfishEpsilonFlag.Name))
+	if err != nil {
+		utils.Fatalf("Failed to solve MDP: %v", err)
+	}
+	if err := table.Save(ctx.Args().First()); err != nil {
+		utils.Fatalf("Failed to write policy table: %v", err)
+	}
+	fmt.Printf("Relative revenue: %f (honest: %f)\n", table.Revenue, alpha)
+	return nil
+}
+
+func accountRevenue(ctx *cli.Context) error {
+	var attacker common.Address
+	if ctx.IsSet(selfishAttackerFlag.Name) {
+		hex := ctx.String(selfishAttackerFlag.Name)
+		if !common.IsHexAddress(hex) {
+			utils.Fatalf("Invalid attacker address %q", hex)
+		}
+		attacker = common.HexToAddress(hex)
+	}
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	chain, db := utils.MakeChain(ctx, stack)
+	defer db.Close()
+	defer chain.Stop()
+
+	to := chain.CurrentBlock().NumberU64()
+	if ctx.IsSet(selfishToFlag.Name) {
+		to = ctx.Uint64(selfishToFlag.Name)
+	}
+	report, err := logic.AccountRevenue(chain, db, ctx.Uint64(selfishFromFlag.Name), to, ctx.Uint64(selfishWindowFlag.Name), attacker, chain.GetBlockFirstSeen)
+	if err != nil {
+		utils.Fatalf("Failed to account revenue: %v", err)
+	}
+	if ctx.Bool(selfishJSONFlag.Name) {
+		blob, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			utils.Fatalf("Failed to encode report: %v", err)
+		}
+		fmt.Println(string(blob))
+		return nil
+	}
+	printRevenueWindow(&report.RevenueWindow)
+	for _, window := range report.Windows {
+		fmt.Println()
+		printRevenueWindow(window)
+	}
+	return nil
+}
+
+func detectSelfishMining(ctx *cli.Context) error {
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	chain, db := utils.MakeChain(ctx, stack)
+	defer db.Close()
+	defer chain.Stop()
+
+	to := chain.CurrentBlock().NumberU64()
+	if ctx.IsSet(selfishToFlag.Name) {
+		to = ctx.Uint64(selfishToFlag.Name)
+	}
+	config := logic.DefaultDetectConfig
+	config.MinRaces = ctx.Uint64(selfishMinRacesFlag.Name)
+	config.RaceWinRate = ctx.Float64(selfishWinRateFlag.Name)
+
+	report, err := logic.Detect(chain, db, ctx.Uint64(selfishFromFlag.Name), to, chain.GetBlockFirstSeen, config)
+	if err != nil {
+		utils.Fatalf("Failed to detect selfish mining: %v", err)
+	}
+	if ctx.Bool(selfishJSONFlag.Name) {
+		blob, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			utils.Fatalf("Failed to encode report: %v", err)
+		}
+		fmt.Println(string(blob))
+		return nil
+	}
+	fmt.Printf("Blocks %d-%d: %d canonical, %d stale, %d races, fork rate %.4f\n",
+		report.From, report.To, report.Blocks, report.Stale, report.Races, report.ForkRate)
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Coinbase", "Blocks", "Uncles", "Orphans", "Fork rate", "Races won", "Longest run", "Suspicious"})
+	for _, miner := range report.Miners {
+		table.Append([]string{
+			miner.Coinbase.Hex(),
+			strconv.FormatUint(miner.Blocks, 10),
+			strconv.FormatUint(miner.Uncles, 10),
+			strconv.FormatUint(miner.Orphans, 10),
+			strconv.FormatFloat(miner.ForkRate, 'f', 4, 64),
+			fmt.Sprintf("%d/%d", miner.RacesWon, miner.Races),
+			fmt.Sprintf("%d (%.1f)", miner.LongestRun, miner.ExpectedRun),
+			strings.Join(miner.Reasons, "; "),
+		})
+	}
+	table.Render()
+	return nil
+}
+
+// printRevenueWindow prints the revenue of the miners in a window as a table.
+func printRevenueWindow(window *logic.RevenueWindow) {
+	fmt.Printf("Blocks %d-%d: %d canonical, %d orphaned, attacker share %.4f\n",
+		window.From, window.To, window.Blocks, window.Orphans, window.AttackerShare)
+	fmt.Printf("Duration %v, mean difficulty %v, attacker revenue %s ETH/s\n",
+		time.Duration(window.Duration)*time.Second, window.Difficulty.ToInt(), weiToEther(window.AttackerRate))
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Coinbase", "Blocks", "Uncles", "Orphans", "Revenue (ETH)", "Relative revenue", "Block share", "Revenue (ETH/s)"})
+	for _, miner := range window.Miners {
+		table.Append([]string{
+			miner.Coinbase.Hex(),
+			strconv.FormatUint(miner.Blocks, 10),
+			strconv.FormatUint(miner.Uncles, 10),
+			strconv.FormatUint(miner.Orphans, 10),
+			new(big.Float).Quo(new(big.Float).SetInt(miner.Total.ToInt()), big.NewFloat(params.Ether)).Text('f', 6),
+			strconv.FormatFloat(miner.RelativeRevenue, 'f', 4, 64),
+			strconv.FormatFloat(miner.BlockShare, 'f', 4, 64),
+			weiToEther(miner.RevenueRate),
+		})
+	}
+	table.Render()
+}
+
+// weiToEther formats a rate in wei as ether.
+func weiToEther(wei float64) string {
+	return strconv.FormatFloat(wei/params.Ether, 'f', 6, 64)
+}
+
+func runTestbed(ctx *cli.Context) error {
+	if ctx.NArg() != 2 {
+		utils.Fatalf("This command requires two arguments.")
+	}
+	scenario, err := testbed.LoadScenario(ctx.Args().Get(0))
+	if err != nil {
+		utils.Fatalf("Failed to load scenario: %v", err)
+	}
+	result, err := testbed.Run(scenario, ctx.Args().Get(1))
+	if err != nil {
+		utils.Fatalf("Testbed run failed: %v", err)
+	}
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Node", "Strategy", "Hashrate", "Peers", "Head", "Hash", "Relative revenue", "Revenue (ETH/s)"})
+	for _, node := range result.Nodes {
+		var revenue, rate string
+		if node.Revenue != nil {
+			revenue = strconv.FormatFloat(node.Revenue.AttackerShare, 'f', 4, 64)
+			rate = weiToEther(node.Revenue.AttackerRate)
+		}
+		table.Append([]string{
+			node.Name,
+			node.Strategy,
+			strconv.FormatFloat(node.Hashrate, 'f', 4, 64),
+			strconv.Itoa(node.Peers),
+			strconv.FormatUint(node.Head, 10),
+			node.HeadHash.TerminalString(),
+			revenue,
+			rate,
+		})
+	}
+	table.Render()
+	return nil
+}
+
+func simulateStrategy(ctx *cli.Context) error {
+	var alphas []float64
+	for _, field := range utils.SplitAndTrim(ctx.String(selfishAlphasFlag.Name)) {
+		alpha, err := strconv.ParseFloat(field, 64)
+		if err != nil {
+			utils.Fatalf("Invalid hashrate share %q: %v", field, err)
+		}
+		alphas = append(alphas, alpha)
+	}
+	var (
+		runs   = ctx.Int(selfishRunsFlag.Name)
+		config = simulation.Config{
+			Strategy: ctx.String(selfishStrategyFlag.Name),
+			StrategyConfig: logic.Config{
+				TrailDepth: ctx.Int(utils.MinerTrailDepthFlag.Name),
+				PolicyFile: ctx.String(utils.MinerPolicyFileFlag.Name),
+			},
+			Gamma:  ctx.Float64(selfishGammaFlag.Name),
+			Delay:  ctx.Float64(selfishDelayFlag.Name),
+			Blocks: ctx.Int(selfishBlocksFlag.Name),
+		}
+	)
+	if runs <= 0 {
+		utils.Fatalf("At least one run is required")
+	}
+	fmt.Println("strategy,alpha,gamma,delay,runs,revenue,stddev,selfish")
+	for _, alpha := range alphas {
+		config.Alpha = alpha
+
+		revenues := make([]float64, runs)
+		for i := range revenues {
+			config.Seed = ctx.Int64(selfishSeedFlag.Name) + int64(i)
+			result, err := simulation.Run(config)
+			if err != nil {
+				utils.Fatalf("Simulation failed: %v", err)
+			}
+			revenues[i] = result.Revenue
+		}
+		var mean, variance float64
+		for _, revenue := range revenues {
+			mean += revenue / float64(runs)
+		}
+		for _, revenue := range revenues {
+			variance += (revenue - mean) * (revenue - mean) / float64(runs)
+		}
+		fmt.Printf("%s,%g,%g,%g,%d,%.6f,%.6f,%.6f\n", config.Strategy, alpha, config.Gamma, config.Delay, runs,
+			mean, math.Sqrt(variance), simulation.SelfishRevenue(alpha, config.Gamma))
+	}
+	return nil
+}
@@ -89,6 +89,9 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 			utils.EthashDatasetsInMemoryFlag,
 			utils.EthashDatasetsOnDiskFlag,
 			utils.EthashDatasetsLockMmapFlag,
+			utils.EthashHashrateFlag,
+			utils.EthashBlockIntervalFlag,
+			utils.EthashScaleDifficultyFlag,
 		},
 	},
 	{
@@ -23,7 +23,6 @@ import (
 	"github.com/ethereum/go-ethereum/miner/logic"
 	"io"
 	"io/ioutil"
-	log2 "log"
 	"math"
 	"math/big"
 	"os"
@@ -336,6 +335,19 @@ var (
 		Name:  "ethash.dagslockmmap",
 		Usage: "Lock memory maps for recent ethash mining DAGs",
 	}
+	EthashHashrateFlag = cli.Float64Flag{
+		Name:  "ethash.hashrate",
+		Usage: "Emulate the given share of the network hashrate by sealing blocks after random delays instead of proof-of-work (0 = disabled)",
+	}
+	EthashBlockIntervalFlag = cli.DurationFlag{
+		Name:  "ethash.blockinterval",
+		Usage: "Mean block interval of the network whose hashrate is emulated",
+		Value: ethash.DefaultBlockInterval,
+	}
+	EthashScaleDifficultyFlag = cli.BoolFlag{
+		Name:  "ethash.scaledifficulty",
+		Usage: "Scale the emulated block interval with the difficulty relative to the genesis difficulty, like proof-of-work does",
+	}
 	// Transaction pool settings
 	TxPoolLocalsFlag = cli.StringFlag{
 		Name:  "txpool.locals",
@@ -480,22 +492,135 @@ var (
 		Name:  "miner.noverify",
 		Usage: "Disable remote sealing verification",
 	}
-	MinerStrategyFlag = cli.IntFlag{
-		Name: "miner.strategy",
-		Usage: "Specify what strategy the miner should use\n" +
-			"0=honest\n" +
-			"1=selfish without inclusion of uncle blocks\n" +
-			"2=selfish with inclusion of own uncle blocks\n" +
-			"3=selfish with inclusion of all uncle blocks\n",
-		Value: 0}
+	MinerStrategyFlag = cli.StringFlag{
+		Name:  "miner.strategy",
+		Usage: "Strategy the miner should use (" + strings.Join(logic.Names(), ", ") + ")",
+		Value: ethconfig.Defaults.Miner.MinerStrategy,
+	}
+	MinerTrailDepthFlag = cli.IntFlag{
+		Name:  "miner.trailDepth",
+		Usage: "Number of blocks a trail-stubborn miner may fall behind before adopting the public chain",
+		Value: ethconfig.Defaults.Miner.StrategyConfig.TrailDepth,
+	}
+	MinerPolicyFileFlag = cli.StringFlag{
+		Name:  "miner.policy",
+		Usage: "Path of the policy table followed by the optimal strategy (see geth selfish solve)",
+	}
+	MinerTimestampsFlag = cli.StringFlag{
+		Name:  "miner.timestamps",
+		Usage: "Timestamp policy of the private blocks of selfish strategies (wall, max-difficulty)",
+		Value: logic.TimestampWall,
+	}
 	MinerLogFileFlag = cli.StringFlag{
 		Name:  "miner.logFile",
-		Usage: "Path of the file where the logs will be written to",
+		Usage: "Path of the file where mining events are logged as JSON lines",
+	}
+	MinerLogFileSizeFlag = cli.UintFlag{
+		Name:  "miner.logFileSize",
+		Usage: "Size in megabytes at which the mining event log is rotated (0 = never)",
+		Value: ethconfig.Defaults.Miner.LogFileSize,
 	}
 	MinerEclipsePeersFlag = cli.StringFlag{
 		Name:  "miner.eclipse",
 		Usage: "comma separated list of enode urls of peers that this miner is supposed to eclipse",
 	}
+	MinerEclipseBlocksFlag = cli.StringFlag{
+		Name:  "miner.eclipse.blocks",
+		Usage: "Relay of the blocks of others to the eclipsed peers (withhold, delay, relay)",
+		Value: logic.EclipseWithhold,
+	}
+	MinerEclipseBlockDelayFlag = cli.DurationFlag{
+		Name:  "miner.eclipse.blockDelay",
+		Usage: "Delay of the blocks of others to the eclipsed peers with --miner.eclipse.blocks=delay",
+	}
+	MinerEclipseTxsFlag = cli.StringFlag{
+		Name:  "miner.eclipse.txs",
+		Usage: "Transaction gossip to the eclipsed peers (withhold, delay, relay)",
+		Value: logic.EclipseRelay,
+	}
+	MinerEclipseTxDelayFlag = cli.DurationFlag{
+		Name:  "miner.eclipse.txDelay",
+		Usage: "Delay of the transaction gossip to the eclipsed peers with --miner.eclipse.txs=delay",
+	}
+	MinerEclipseFilterChainFlag = cli.BoolFlag{
+		Name:  "miner.eclipse.filterChain",
+		Usage: "Serve the eclipsed peers only the blocks they know or this node published in header, body and receipt requests",
+	}
+	MinerGammaFlag = cli.Float64Flag{
+		Name:  "miner.gamma",
+		Usage: "Fraction of peers that racing blocks are pushed to immediately",
+	}
+	MinerGammaPeersFlag = cli.StringFlag{
+		Name:  "miner.gammaPeers",
+		Usage: "Comma separated enode URLs of peers that racing blocks are pushed to immediately (overrides --miner.gamma)",
+	}
+	MinerGammaDelayFlag = cli.DurationFlag{
+		Name:  "miner.gammaDelay",
+		Usage: "Delay after which racing blocks are sent to the remaining peers, requires --miner.gamma or --miner.gammaPeers (0 = never)",
+	}
+	MinerComparisonFlag = cli.StringFlag{
+		Name:  "miner.comparison",
+		Usage: "Comparison of the private and the public chain for the strategy decisions (length, td)",
+		Value: logic.CompareLength,
+	}
+	MinerTieDifficultyFlag = BigFlag{
+		Name:  "miner.tie.difficulty",
+		Usage: "Total difficulty difference up to which the chains are tied when compared by total difficulty",
+	}
+	MinerTieBlocksFlag = cli.Float64Flag{
+		Name:  "miner.tie.blocks",
+		Usage: "Tie threshold in blocks of the difficulty of the public head (overridden by --miner.tie.difficulty)",
+	}
+	MinerPoolFlag = cli.StringFlag{
+		Name:  "miner.pool",
+		Usage: "Comma separated enode URLs of colluding selfish miners to share the private chain with (add them as static peers)",
+	}
+	MinerIntermittentFlag = cli.StringFlag{
+		Name:  "miner.intermittent",
+		Usage: "Alternate between the selfish strategy and honest mining on a schedule (blocks, time, difficulty)",
+	}
+	MinerIntermittentSelfishBlocksFlag = cli.Uint64Flag{
+		Name:  "miner.intermittent.selfishBlocks",
+		Usage: "Number of public blocks a selfish phase lasts with the blocks schedule",
+	}
+	MinerIntermittentHonestBlocksFlag = cli.Uint64Flag{
+		Name:  "miner.intermittent.honestBlocks",
+		Usage: "Number of public blocks an honest phase lasts with the blocks schedule",
+	}
+	MinerIntermittentSelfishTimeFlag = cli.DurationFlag{
+		Name:  "miner.intermittent.selfishTime",
+		Usage: "Time a selfish phase lasts with the time schedule",
+	}
+	MinerIntermittentHonestTimeFlag = cli.DurationFlag{
+		Name:  "miner.intermittent.honestTime",
+		Usage: "Time an honest phase lasts with the time schedule",
+	}
+	MinerIntermittentSelfishAboveFlag = BigFlag{
+		Name:  "miner.intermittent.selfishAbove",
+		Usage: "Difficulty of the public head at which a selfish phase starts with the difficulty schedule",
+	}
+	MinerIntermittentHonestBelowFlag = BigFlag{
+		Name:  "miner.intermittent.honestBelow",
+		Usage: "Difficulty of the public head below which an honest phase starts (default = --miner.intermittent.selfishAbove)",
+	}
+	MinerIntermittentPublishFlag = cli.BoolFlag{
+		Name:  "miner.intermittent.publish",
+		Usage: "Publish the withheld blocks when an honest phase starts instead of discarding them",
+	}
+	ExperimentSeedFlag = cli.Int64Flag{
+		Name:  "experiment.seed",
+		Usage: "Seed of the random choices of the node (fork choice, block propagation, emulated mining), for reproducible runs (0 = random)",
+	}
+	ForkChoiceFlag = cli.StringFlag{
+		Name:  "forkchoice",
+		Usage: `Fork choice rule of the public chain ("td", "ghost", "freshness" or "publish-or-perish")`,
+		Value: core.ForkChoiceTD,
+	}
+	ForkChoiceWindowFlag = cli.Uint64Flag{
+		Name:  "forkchoice.window",
+		Usage: "Number of blocks within which ommer references are timely (publish-or-perish)",
+		Value: core.DefaultPublishWindow,
+	}
 	// Account settings
 	UnlockedAccountFlag = cli.StringFlag{
 		Name:  "unlock",
@@ -1399,6 +1524,12 @@ func setEthash(ctx *cli.Context, cfg *ethconfig.Config) {
 	if ctx.GlobalIsSet(EthashDatasetsLockMmapFlag.Name) {
 		cfg.Ethash.DatasetsLockMmap = ctx.GlobalBool(EthashDatasetsLockMmapFlag.Name)
 	}
+	if ctx.GlobalIsSet(EthashHashrateFlag.Name) {
+		cfg.Ethash.PowMode = ethash.ModePoisson
+		cfg.Ethash.Hashrate = ctx.GlobalFloat64(EthashHashrateFlag.Name)
+		cfg.Ethash.BlockInterval = ctx.GlobalDuration(EthashBlockIntervalFlag.Name)
+		cfg.Ethash.ScaleDifficulty = ctx.GlobalBool(EthashScaleDifficultyFlag.Name)
+	}
 }
 
 func setMiner(ctx *cli.Context, cfg *miner.Config) {
@@ -1421,21 +1552,121 @@ func setMiner(ctx *cli.Context, cfg *miner.Config) {
 	if ctx.GlobalIsSet(MinerNoVerifyFlag.Name) {
 		cfg.Noverify = ctx.GlobalBool(MinerNoVerifyFlag.Name)
 	}
+	if ctx.GlobalIsSet(MinerTrailDepthFlag.Name) {
+		cfg.StrategyConfig.TrailDepth = ctx.GlobalInt(MinerTrailDepthFlag.Name)
+	}
+	if ctx.GlobalIsSet(MinerPolicyFileFlag.Name) {
+		cfg.StrategyConfig.PolicyFile = ctx.GlobalString(MinerPolicyFileFlag.Name)
+	}
+	if ctx.GlobalIsSet(MinerTimestampsFlag.Name) {
+		cfg.StrategyConfig.Timestamps = ctx.GlobalString(MinerTimestampsFlag.Name)
+	}
 	if ctx.GlobalIsSet(MinerStrategyFlag.Name) {
-		cfg.MinerStrategy = logic.Strategy(ctx.GlobalInt(MinerStrategyFlag.Name))
+		cfg.MinerStrategy = ctx.GlobalString(MinerStrategyFlag.Name)
+		if _, err := logic.New(cfg.MinerStrategy, &cfg.StrategyConfig); err != nil {
+			Fatalf("Option %q: %v", MinerStrategyFlag.Name, err)
+		}
 	}
 	if ctx.GlobalIsSet(MinerEclipsePeersFlag.Name) {
-		eclipsePeers := ctx.GlobalString(MinerEclipsePeersFlag.Name)
-		if eclipsePeers == "" {
-			cfg.EclipsePeers = nil
-		} else {
-			cfg.EclipsePeers = strings.Split(eclipsePeers, ",")
+		cfg.EclipsePeers = SplitAndTrim(ctx.GlobalString(MinerEclipsePeersFlag.Name))
+		for _, url := range cfg.EclipsePeers {
+			if _, err := enode.Parse(enode.ValidSchemes, url); err != nil {
+				Fatalf("Option %q: invalid enode %q: %v", MinerEclipsePeersFlag.Name, url, err)
+			}
+		}
+	}
+	if ctx.GlobalIsSet(MinerEclipseBlocksFlag.Name) {
+		cfg.Eclipse.Blocks = ctx.GlobalString(MinerEclipseBlocksFlag.Name)
+	}
+	if ctx.GlobalIsSet(MinerEclipseBlockDelayFlag.Name) {
+		cfg.Eclipse.BlockDelay = ctx.GlobalDuration(MinerEclipseBlockDelayFlag.Name)
+	}
+	if ctx.GlobalIsSet(MinerEclipseTxsFlag.Name) {
+		cfg.Eclipse.Txs = ctx.GlobalString(MinerEclipseTxsFlag.Name)
+	}
+	if ctx.GlobalIsSet(MinerEclipseTxDelayFlag.Name) {
+		cfg.Eclipse.TxDelay = ctx.GlobalDuration(MinerEclipseTxDelayFlag.Name)
+	}
+	if ctx.GlobalIsSet(MinerEclipseFilterChainFlag.Name) {
+		cfg.Eclipse.FilterChain = ctx.GlobalBool(MinerEclipseFilterChainFlag.Name)
+	}
+	if err := cfg.Eclipse.Validate(); err != nil {
+		Fatalf("Option %q: %v", MinerEclipsePeersFlag.Name, err)
+	}
+	if ctx.GlobalIsSet(MinerGammaFlag.Name) {
+		cfg.Race.Enabled = true
+		cfg.Race.Fraction = ctx.GlobalFloat64(MinerGammaFlag.Name)
+		if cfg.Race.Fraction < 0 || cfg.Race.Fraction > 1 {
+			Fatalf("Option %q: must be in [0, 1]", MinerGammaFlag.Name)
+		}
+	}
+	if ctx.GlobalIsSet(MinerGammaPeersFlag.Name) {
+		cfg.Race.Enabled = true
+		cfg.Race.Peers = SplitAndTrim(ctx.GlobalString(MinerGammaPeersFlag.Name))
+		for _, url := range cfg.Race.Peers {
+			if _, err := enode.Parse(enode.ValidSchemes, url); err != nil {
+				Fatalf("Option %q: invalid enode %q: %v", MinerGammaPeersFlag.Name, url, err)
+			}
+		}
+	}
+	if ctx.GlobalIsSet(MinerGammaDelayFlag.Name) {
+		if !cfg.Race.Enabled {
+			Fatalf("Option %q requires %q or %q", MinerGammaDelayFlag.Name, MinerGammaFlag.Name, MinerGammaPeersFlag.Name)
+		}
+		cfg.Race.Delay = ctx.GlobalDuration(MinerGammaDelayFlag.Name)
+	}
+	if ctx.GlobalIsSet(MinerComparisonFlag.Name) {
+		cfg.Comparison.Mode = ctx.GlobalString(MinerComparisonFlag.Name)
+	}
+	if ctx.GlobalIsSet(MinerTieDifficultyFlag.Name) {
+		cfg.Comparison.TieDifficulty = GlobalBig(ctx, MinerTieDifficultyFlag.Name)
+	}
+	if ctx.GlobalIsSet(MinerTieBlocksFlag.Name) {
+		cfg.Comparison.TieBlocks = ctx.GlobalFloat64(MinerTieBlocksFlag.Name)
+	}
+	if err := cfg.Comparison.Validate(); err != nil {
+		Fatalf("Option %q: %v", MinerComparisonFlag.Name, err)
+	}
+	if ctx.GlobalIsSet(MinerPoolFlag.Name) {
+		cfg.Pool = SplitAndTrim(ctx.GlobalString(MinerPoolFlag.Name))
+		for _, url := range cfg.Pool {
+			if _, err := enode.Parse(enode.ValidSchemes, url); err != nil {
+				Fatalf("Option %q: invalid enode %q: %v", MinerPoolFlag.Name, url, err)
+			}
 		}
 	}
+	if ctx.GlobalIsSet(MinerIntermittentFlag.Name) {
+		cfg.Intermittent.Schedule = ctx.GlobalString(MinerIntermittentFlag.Name)
+	}
+	if ctx.GlobalIsSet(MinerIntermittentSelfishBlocksFlag.Name) {
+		cfg.Intermittent.SelfishBlocks = ctx.GlobalUint64(MinerIntermittentSelfishBlocksFlag.Name)
+	}
+	if ctx.GlobalIsSet(MinerIntermittentHonestBlocksFlag.Name) {
+		cfg.Intermittent.HonestBlocks = ctx.GlobalUint64(MinerIntermittentHonestBlocksFlag.Name)
+	}
+	if ctx.GlobalIsSet(MinerIntermittentSelfishTimeFlag.Name) {
+		cfg.Intermittent.SelfishTime = ctx.GlobalDuration(MinerIntermittentSelfishTimeFlag.Name)
+	}
+	if ctx.GlobalIsSet(MinerIntermittentHonestTimeFlag.Name) {
+		cfg.Intermittent.HonestTime = ctx.GlobalDuration(MinerIntermittentHonestTimeFlag.Name)
+	}
+	if ctx.GlobalIsSet(MinerIntermittentSelfishAboveFlag.Name) {
+		cfg.Intermittent.SelfishAbove = GlobalBig(ctx, MinerIntermittentSelfishAboveFlag.Name)
+	}
+	if ctx.GlobalIsSet(MinerIntermittentHonestBelowFlag.Name) {
+		cfg.Intermittent.HonestBelow = GlobalBig(ctx, MinerIntermittentHonestBelowFlag.Name)
+	}
+	if ctx.GlobalIsSet(MinerIntermittentPublishFlag.Name) {
+		cfg.Intermittent.Publish = ctx.GlobalBool(MinerIntermittentPublishFlag.Name)
+	}
+	if err := cfg.Intermittent.Validate(); err != nil {
+		Fatalf("Option %q: %v", MinerIntermittentFlag.Name, err)
+	}
 	if ctx.GlobalIsSet(MinerLogFileFlag.Name) {
-		minerLogFile := ctx.GlobalString(MinerLogFileFlag.Name)
-		f, _ := os.OpenFile(minerLogFile, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
-		log2.SetOutput(f)
+		cfg.LogFile = ctx.GlobalString(MinerLogFileFlag.Name)
+	}
+	if ctx.GlobalIsSet(MinerLogFileSizeFlag.Name) {
+		cfg.LogFileSize = ctx.GlobalUint(MinerLogFileSizeFlag.Name)
 	}
 	if ctx.GlobalIsSet(LegacyMinerGasTargetFlag.Name) {
 		log.Warn("The generic --miner.gastarget flag is deprecated and will be removed in the future!")
@@ -1531,6 +1762,19 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 	setWhitelist(ctx, cfg)
 	setLes(ctx, cfg)
 
+	if ctx.GlobalIsSet(ExperimentSeedFlag.Name) {
+		cfg.ExperimentSeed = ctx.GlobalInt64(ExperimentSeedFlag.Name)
+	}
+	if ctx.GlobalIsSet(ForkChoiceFlag.Name) {
+		cfg.ForkChoice.Rule = ctx.GlobalString(ForkChoiceFlag.Name)
+		if err := cfg.ForkChoice.Validate(); err != nil {
+			Fatalf("Option %q: %v", ForkChoiceFlag.Name, err)
+		}
+	}
+	if ctx.GlobalIsSet(ForkChoiceWindowFlag.Name) {
+		cfg.ForkChoice.Window = ctx.GlobalUint64(ForkChoiceWindowFlag.Name)
+	}
+
 	// Cap the cache allowance and tune the garbage collector
 	mem, err := gopsutil.VirtualMemory()
 	if err == nil {
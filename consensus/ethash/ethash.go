@@ -410,8 +410,13 @@ const (
 	ModeTest
 	ModeFake
 	ModeFullFake
+	ModePoisson // Fake PoW sealing after exponentially distributed delays, see Config.Hashrate
 )
 
+// DefaultBlockInterval is the mean block interval of the network emulated by
+// ModePoisson if none is configured.
+const DefaultBlockInterval = 13 * time.Second
+
 // Config are the configuration parameters of the ethash.
 type Config struct {
 	CacheDir         string
@@ -424,6 +429,20 @@ type Config struct {
 	DatasetsLockMmap bool
 	PowMode          Mode
 
+	// Emulated mining of ModePoisson: blocks are sealed as if found by a miner
+	// with the given share of the hashrate of a network finding a block every
+	// BlockInterval on average.
+	Hashrate      float64
+	BlockInterval time.Duration
+
+	// Scale the mean delay of ModePoisson with the difficulty of the sealed
+	// block relative to the genesis difficulty, so that the emulated mining
+	// responds to the difficulty adjustment like proof-of-work does.
+	ScaleDifficulty bool
+
+	// Seed of the nonces and emulated sealing delays, zero to seed randomly.
+	Seed int64 `toml:"-"`
+
 	// When set, notifications sent by the remote sealer will
 	// be block header JSON objects instead of work package arrays.
 	NotifyFull bool
@@ -482,6 +501,10 @@ func New(config Config, notify []string, noverify bool) *Ethash {
 	if config.PowMode == ModeShared {
 		ethash.shared = sharedEthash
 	}
+	if config.PowMode == ModePoisson && config.BlockInterval <= 0 {
+		config.Log.Warn("Sanitizing invalid emulated block interval", "provided", config.BlockInterval, "updated", DefaultBlockInterval)
+		ethash.config.BlockInterval = DefaultBlockInterval
+	}
 	ethash.remote = startRemoteSealer(ethash, notify, noverify)
 	return ethash
 }
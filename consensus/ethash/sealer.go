@@ -60,6 +60,10 @@ func (ethash *Ethash) Seal(chain consensus.ChainHeaderReader, block *types.Block
 		}
 		return nil
 	}
+	// If we're emulating a hashrate share, seal after a random delay
+	if ethash.config.PowMode == ModePoisson {
+		return ethash.sealPoisson(chain, block, results, stop)
+	}
 	// If we're running a shared PoW, delegate sealing to it
 	if ethash.shared != nil {
 		return ethash.shared.Seal(chain, block, results, stop)
@@ -69,13 +73,9 @@ func (ethash *Ethash) Seal(chain consensus.ChainHeaderReader, block *types.Block
 
 	ethash.lock.Lock()
 	threads := ethash.threads
-	if ethash.rand == nil {
-		seed, err := crand.Int(crand.Reader, big.NewInt(math.MaxInt64))
-		if err != nil {
-			ethash.lock.Unlock()
-			return err
-		}
-		ethash.rand = rand.New(rand.NewSource(seed.Int64()))
+	if err := ethash.seedRand(); err != nil {
+		ethash.lock.Unlock()
+		return err
 	}
 	ethash.lock.Unlock()
 	if threads == 0 {
@@ -128,6 +128,94 @@ func (ethash *Ethash) Seal(chain consensus.ChainHeaderReader, block *types.Block
 	return nil
 }
 
+// seedRand seeds the random source of the nonces if not done yet, from the
+// configured seed if any. The lock must be held.
+func (ethash *Ethash) seedRand() error {
+	if ethash.rand != nil {
+		return nil
+	}
+	if ethash.config.Seed != 0 {
+		ethash.rand = rand.New(rand.NewSource(ethash.config.Seed))
+		return nil
+	}
+	seed, err := crand.Int(crand.Reader, big.NewInt(math.MaxInt64))
+	if err != nil {
+		return err
+	}
+	ethash.rand = rand.New(rand.NewSource(seed.Int64()))
+	return nil
+}
+
+// drawPoissonSeal draws the sealing delay and the nonce of a block sealed by the
+// emulated hashrate.
+func (ethash *Ethash) drawPoissonSeal(chain consensus.ChainHeaderReader, block *types.Block) (time.Duration, uint64, error) {
+	ethash.lock.Lock()
+	if err := ethash.seedRand(); err != nil {
+		ethash.lock.Unlock()
+		return 0, 0, err
+	}
+	var (
+		delay = time.Duration(ethash.rand.ExpFloat64() * float64(ethash.config.BlockInterval) / ethash.config.Hashrate)
+		nonce = ethash.rand.Uint64()
+	)
+	ethash.lock.Unlock()
+
+	if ethash.config.ScaleDifficulty && chain != nil {
+		if genesis := chain.GetHeaderByNumber(0); genesis != nil && genesis.Difficulty.Sign() > 0 {
+			scale, _ := new(big.Float).Quo(new(big.Float).SetInt(block.Difficulty()), new(big.Float).SetInt(genesis.Difficulty)).Float64()
+			delay = time.Duration(float64(delay) * scale)
+		}
+	}
+	return delay, nonce, nil
+}
+
+// sealPoisson seals the block with a random nonce after an exponentially
+// distributed delay, with the mean of the block interval divided by the
+// hashrate share. Blocks are thus found according to a Poisson process like
+// by a miner with the configured share of the network hashrate. As the delay
+// is memoryless, restarting sealing on new work doesn't change the rate. With
+// ScaleDifficulty the mean grows with the difficulty of the block relative to
+// the genesis difficulty.
+// Negative thread counts disable sealing, other counts don't affect the rate.
+func (ethash *Ethash) sealPoisson(chain consensus.ChainHeaderReader, block *types.Block, results chan<- *types.Block, stop <-chan struct{}) error {
+	ethash.lock.Lock()
+	threads := ethash.threads
+	ethash.lock.Unlock()
+
+	delay, nonce, err := ethash.drawPoissonSeal(chain, block)
+	if err != nil {
+		return err
+	}
+	// Without a timer, wait until stopped or the thread count is changed
+	timer := time.NewTimer(delay)
+	found := timer.C
+	if threads < 0 || ethash.config.Hashrate <= 0 {
+		timer.Stop()
+		found = nil
+	}
+	go func() {
+		defer timer.Stop()
+
+		select {
+		case <-stop:
+		case <-found:
+			header := block.Header()
+			header.Nonce, header.MixDigest = types.EncodeNonce(nonce), common.Hash{}
+			select {
+			case results <- block.WithSeal(header):
+			default:
+				ethash.config.Log.Warn("Sealing result is not read by miner", "mode", "poisson", "sealhash", ethash.SealHash(block.Header()))
+			}
+		case <-ethash.update:
+			// Thread count was changed on user request, restart
+			if err := ethash.Seal(chain, block, results, stop); err != nil {
+				ethash.config.Log.Error("Failed to restart sealing after update", "err", err)
+			}
+		}
+	}()
+	return nil
+}
+
 // mine is the actual proof-of-work miner that searches for a nonce starting from
 // seed that results in correct final block difficulty.
 func (ethash *Ethash) mine(block *types.Block, id int, seed uint64, abort chan struct{}, found chan *types.Block) {
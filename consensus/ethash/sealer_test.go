@@ -22,6 +22,7 @@ import (
 	"math/big"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"strconv"
 	"testing"
 	"time"
@@ -296,3 +297,87 @@ func TestStaleSubmission(t *testing.T) {
 		}
 	}
 }
+
+// Tests that the emulated hashrate draws sealing delays at the configured rate,
+// and that the seals pass verification.
+func TestPoissonSeal(t *testing.T) {
+	ethash := New(Config{PowMode: ModePoisson, Hashrate: 0.5, BlockInterval: time.Millisecond, Seed: 1}, nil, false)
+	defer ethash.Close()
+
+	var (
+		header  = &types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(100)}
+		results = make(chan *types.Block)
+	)
+	for i := 0; i < 10; i++ {
+		if err := ethash.Seal(nil, types.NewBlockWithHeader(header), results, nil); err != nil {
+			t.Fatalf("failed to seal block: %v", err)
+		}
+		select {
+		case block := <-results:
+			if err := ethash.verifySeal(nil, block.Header(), false); err != nil {
+				t.Fatalf("emulated seal rejected: %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("sealing timed out")
+		}
+	}
+	// The mean delay is the block interval over the hashrate share
+	var (
+		draws = 10000
+		total time.Duration
+	)
+	for i := 0; i < draws; i++ {
+		delay, _, err := ethash.drawPoissonSeal(nil, types.NewBlockWithHeader(header))
+		if err != nil {
+			t.Fatalf("failed to draw seal: %v", err)
+		}
+		total += delay
+	}
+	if mean := total / time.Duration(draws); mean < 1900*time.Microsecond || mean > 2100*time.Microsecond {
+		t.Errorf("mean sealing delay %v, want about 2ms", mean)
+	}
+	// Disabled sealing doesn't produce blocks until stopped
+	ethash.SetThreads(-1)
+	stop := make(chan struct{})
+	if err := ethash.Seal(nil, types.NewBlockWithHeader(header), results, stop); err != nil {
+		t.Fatalf("failed to seal block: %v", err)
+	}
+	select {
+	case <-results:
+		t.Fatalf("block sealed with sealing disabled")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(stop)
+}
+
+// Tests that emulated sealers with the same seed produce the same nonces.
+func TestPoissonSealSeed(t *testing.T) {
+	seal := func(seed int64) []uint64 {
+		ethash := New(Config{PowMode: ModePoisson, Hashrate: 1, BlockInterval: time.Millisecond, Seed: seed}, nil, false)
+		defer ethash.Close()
+
+		var (
+			header  = &types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(100)}
+			results = make(chan *types.Block)
+			nonces  []uint64
+		)
+		for i := 0; i < 5; i++ {
+			if err := ethash.Seal(nil, types.NewBlockWithHeader(header), results, nil); err != nil {
+				t.Fatalf("failed to seal block: %v", err)
+			}
+			select {
+			case block := <-results:
+				nonces = append(nonces, block.Nonce())
+			case <-time.After(time.Second):
+				t.Fatalf("sealing timed out")
+			}
+		}
+		return nonces
+	}
+	if a, b := seal(1), seal(1); !reflect.DeepEqual(a, b) {
+		t.Errorf("nonces differ with the same seed: %v != %v", a, b)
+	}
+	if a, b := seal(1), seal(2); reflect.DeepEqual(a, b) {
+		t.Errorf("nonces equal with different seeds: %v", a)
+	}
+}
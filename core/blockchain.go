@@ -21,10 +21,8 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	log2 "log"
 	"math/big"
 	"sort"
-	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -189,6 +187,10 @@ type BlockChain struct {
 	// Readers don't need to take it, they can just read the database.
 	chainmu *syncx.ClosableMutex
 
+	// This mutex keeps the first sight of a block from being overwritten by
+	// concurrent announcements and deliveries.
+	provenanceLock sync.Mutex
+
 	currentBlock     atomic.Value // Current head of the block chain
 	currentFastBlock atomic.Value // Current head of the fast-sync chain (may be above the block chain!)
 
@@ -200,6 +202,10 @@ type BlockChain struct {
 	txLookupCache *lru.Cache     // Cache for the most recent transaction lookup data.
 	futureBlocks  *lru.Cache     // future blocks are blocks added for later processing
 
+	pendingProvenance *lru.Cache // Provenance of the blocks heard of but not imported yet
+
+	keptState atomic.Value // Root of the state another chain builds on, written to disk before it's pruned
+
 	wg            sync.WaitGroup //
 	quit          chan struct{}  // shutdown signal, closed in Stop.
 	running       int32          // 0 if chain is running, 1 when stopped
@@ -212,7 +218,7 @@ type BlockChain struct {
 	forker     *ForkChoice
 	vmConfig   vm.Config
 
-	chainBlockEventChannel chan ChainBlockEvent
+	chainBlockEventChannel atomic.Value // chan ChainBlockEvent receiving every inserted block, set by SetChainBlockEventSubscription
 }
 
 // NewBlockChain returns a fully initialised block chain using information
@@ -228,6 +234,7 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 	blockCache, _ := lru.New(blockCacheLimit)
 	txLookupCache, _ := lru.New(txLookupCacheLimit)
 	futureBlocks, _ := lru.New(maxFutureBlocks)
+	pendingProvenance, _ := lru.New(pendingProvenanceLimit)
 
 	bc := &BlockChain{
 		chainConfig: chainConfig,
@@ -249,6 +256,8 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 		futureBlocks:  futureBlocks,
 		engine:        engine,
 		vmConfig:      vmConfig,
+
+		pendingProvenance: pendingProvenance,
 	}
 	bc.forker = NewForkChoice(bc, shouldPreserve)
 	bc.validator = NewBlockValidator(chainConfig, bc, engine)
@@ -410,103 +419,70 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 	return bc, nil
 }
 
-type BranchesContainer struct {
-	Branches []types.Blocks
-}
-
-func NewBranchesContainer() *BranchesContainer {
-	var branches []types.Blocks
-	return &BranchesContainer{
-		Branches: branches,
-	}
+func (bc *BlockChain) Length() int {
+	return int(bc.CurrentBlock().NumberU64())
 }
 
-func (container *BranchesContainer) AddNewBranch(branch types.Blocks) {
-	container.Branches = append(container.Branches, branch)
+// SetForkChoiceSeed seeds the random choice between blocks of equal total
+// difficulty, so that experiment runs can be reproduced. It must be called
+// before blocks are inserted.
+func (bc *BlockChain) SetForkChoiceSeed(seed int64) {
+	bc.forker.SetSeed(seed)
 }
 
-// AddBranch try to connect branch to an existing branch,
-// if not possible, create a new branch
-func (container *BranchesContainer) AddBranch(branch types.Blocks) {
-	for i, existingBranch := range container.Branches {
-		if branch[0].ParentHash() == existingBranch[len(existingBranch)-1].Hash() {
-			// connect
-			for _, block := range branch {
-				existingBranch = append(existingBranch, block)
-			}
-			container.Branches[i] = existingBranch
-			return
-		}
+// SetForkChoiceRule replaces the rule competing branches are weighed by before
+// their total difficulty. It must be called before blocks are inserted.
+func (bc *BlockChain) SetForkChoiceRule(config ForkChoiceConfig) error {
+	rule, err := NewForkChoiceRule(config, bc)
+	if err != nil {
+		return err
 	}
-	// create a new branch
-	container.AddNewBranch(branch)
+	bc.forker.SetRule(rule)
+	return nil
 }
 
-func (container *BranchesContainer) Clear() {
-	container.Branches = nil
+// KeepState marks the state of the given block as the one another chain builds
+// on, which reads it through SharedReader. The state stays in memory as long as
+// the chain would keep it anyway, and is written to disk only when the chain
+// prunes it or stops. Only the state of the last block given is kept.
+func (bc *BlockChain) KeepState(block *types.Block) {
+	bc.keptState.Store(block.Root())
 }
 
-func (bc *BlockChain) GetAllBranches(block *types.Block, branch types.Blocks, container *BranchesContainer) {
-	if block.NumberU64() > 0 { // no need to add genesis block to branch
-		branch = append(branch, block)
-	}
-	var children types.Blocks
-	for _, hash := range rawdb.ReadAllHashes(bc.db, block.NumberU64()+1) {
-		possibleChild := bc.GetBlock(hash, block.NumberU64()+1)
-		if possibleChild.ParentHash() == block.Hash() {
-			children = append(children, possibleChild)
-		}
-	}
-
-	if len(children) == 0 {
-		// branch is complete, add it to the other branches
-		container.AddNewBranch(branch)
-		return
-	}
-
-	for _, child := range children {
-		bc.GetAllBranches(child, branch, container)
+// SharedReader returns a reader of the chain database that also finds the trie
+// nodes the chain only holds in memory, so that another chain reading this one
+// through an overlay database finds the recent state even though it's pruned.
+func (bc *BlockChain) SharedReader() ethdb.Reader {
+	return &sharedReader{
+		Reader: bc.db,
+		triedb: bc.stateCache.TrieDB(),
 	}
 }
 
-func (bc *BlockChain) Length() int {
-	return int(bc.CurrentBlock().NumberU64())
-}
-
-func (bc *BlockChain) Print(name string) {
-	chain := name + " ["
-	for i := 1; i <= int(bc.CurrentBlock().NumberU64()); i++ {
-		block := bc.GetBlockByNumber(uint64(i))
-		if block != nil {
-			address := string(block.Coinbase().Hex()[len(block.Coinbase().Hex())-1])
-			chain += address
-			chain += ", "
-		}
-	}
-	chain += "]" + " (" + strconv.Itoa(bc.Length()) + ")"
-	log2.Printf(chain)
+// sharedReader is a database reader that looks up trie nodes in the trie cache
+// before the disk.
+type sharedReader struct {
+	ethdb.Reader
+	triedb *trie.Database
 }
 
-func (bc *BlockChain) PrintAllBranches() {
-	container := NewBranchesContainer()
-	bc.GetAllBranches(bc.genesisBlock, types.Blocks{}, container)
-	for _, branch := range container.Branches {
-		str := "branch: ["
-		for _, block := range branch {
-			address := string(block.Coinbase().Hex()[len(block.Coinbase().Hex())-1])
-			str += address
-			str += ", "
-		}
-		str += "]" + " (" + strconv.Itoa(len(branch)) + ")"
-		log2.Printf(str)
+// Has retrieves if a key is present in the database, or if it is a trie node
+// held in the trie cache.
+func (r *sharedReader) Has(key []byte) (bool, error) {
+	if len(key) != common.HashLength {
+		return r.Reader.Has(key)
 	}
+	_, err := r.triedb.Node(common.BytesToHash(key))
+	return err == nil, nil
 }
 
-func (bc *BlockChain) PrintBalance(coinbase common.Address) {
-	stateDB, err := bc.State()
-	if err == nil {
-		log2.Printf("balance: %d", stateDB.GetBalance(coinbase))
+// Get retrieves the given key if it's present in the database, or if it is a
+// trie node held in the trie cache.
+func (r *sharedReader) Get(key []byte) ([]byte, error) {
+	if len(key) != common.HashLength {
+		return r.Reader.Get(key)
 	}
+	return r.triedb.Node(common.BytesToHash(key))
 }
 
 // empty returns an indicator whether the blockchain is empty.
@@ -707,6 +683,7 @@ func (bc *BlockChain) setHeadBeyondRoot(head uint64, root common.Hash, repair bo
 			rawdb.DeleteBody(db, hash, num)
 			rawdb.DeleteReceipts(db, hash, num)
 		}
+		rawdb.DeleteBlockProvenance(db, hash)
 		// Todo(rjl493456442) txlookup, bloombits, etc
 	}
 	// If SetHead was only called as a chain reparation method, try to skip
@@ -917,6 +894,12 @@ func (bc *BlockChain) Stop() {
 				log.Error("Failed to commit recent state trie", "err", err)
 			}
 		}
+		if kept, _ := bc.keptState.Load().(common.Hash); kept != (common.Hash{}) {
+			log.Info("Writing kept state to disk", "root", kept)
+			if err := triedb.Commit(kept, true, nil); err != nil {
+				log.Error("Failed to commit kept state trie", "err", err)
+			}
+		}
 		for !bc.triegc.Empty() {
 			triedb.Dereference(bc.triegc.PopItem().(common.Hash))
 		}
@@ -1227,6 +1210,9 @@ func (bc *BlockChain) InsertReceiptChain(blockChain types.Blocks, receiptChain [
 			return n, err
 		}
 	}
+	for _, block := range blockChain {
+		bc.writePendingProvenance(block.Hash())
+	}
 
 	head := blockChain[len(blockChain)-1]
 	context := []interface{}{
@@ -1258,6 +1244,7 @@ func (bc *BlockChain) writeBlockWithoutState(block *types.Block, td *big.Int) (e
 	if err := batch.Write(); err != nil {
 		log.Crit("Failed to write block into disk", "err", err)
 	}
+	bc.writePendingProvenance(block.Hash())
 	return nil
 }
 
@@ -1297,6 +1284,8 @@ func (bc *BlockChain) writeBlockWithState(block *types.Block, receipts []*types.
 	if err := blockBatch.Write(); err != nil {
 		log.Crit("Failed to write block into disk", "err", err)
 	}
+	bc.writePendingProvenance(block.Hash())
+
 	// Commit all cached state changes into underlying memory database.
 	root, err := state.Commit(bc.chainConfig.IsEIP158(block.Number()))
 	if err != nil {
@@ -1343,13 +1332,20 @@ func (bc *BlockChain) writeBlockWithState(block *types.Block, receipts []*types.
 					bc.gcproc = 0
 				}
 			}
-			// Garbage collect anything below our required write retention
+			// Garbage collect anything below our required write retention, but
+			// write the state another chain builds on to disk first
+			kept, _ := bc.keptState.Load().(common.Hash)
 			for !bc.triegc.Empty() {
 				root, number := bc.triegc.Pop()
 				if uint64(-number) > chosen {
 					bc.triegc.Push(root, number)
 					break
 				}
+				if root.(common.Hash) == kept {
+					if err := triedb.Commit(kept, false, nil); err != nil {
+						log.Error("Failed to commit kept state trie", "err", err)
+					}
+				}
 				triedb.Dereference(root.(common.Hash))
 			}
 		}
@@ -1408,15 +1404,11 @@ func (bc *BlockChain) writeBlockAndSetHead(block *types.Block, receipts []*types
 		// event here.
 		if emitHeadEvent {
 			bc.chainHeadFeed.Send(ChainHeadEvent{Block: block})
-			if bc.chainBlockEventChannel != nil {
-				bc.chainBlockEventChannel <- ChainBlockEvent{Block: block}
-			}
+			bc.sendChainBlockEvent(block)
 		}
 	} else {
 		bc.chainSideFeed.Send(ChainSideEvent{Block: block})
-		if bc.chainBlockEventChannel != nil {
-			bc.chainBlockEventChannel <- ChainBlockEvent{Block: block}
-		}
+		bc.sendChainBlockEvent(block)
 	}
 	return status, nil
 }
@@ -1500,9 +1492,7 @@ func (bc *BlockChain) insertChain(chain types.Blocks, verifySeals, setHead bool)
 	defer func() {
 		if lastCanon != nil && bc.CurrentBlock().Hash() == lastCanon.Hash() {
 			bc.chainHeadFeed.Send(ChainHeadEvent{lastCanon})
-			if bc.chainBlockEventChannel != nil {
-				bc.chainBlockEventChannel <- ChainBlockEvent{Block: lastCanon}
-			}
+			bc.sendChainBlockEvent(lastCanon)
 		}
 	}()
 	// Start the parallel header verifier
@@ -2168,9 +2158,7 @@ func (bc *BlockChain) reorg(oldBlock, newBlock *types.Block) error {
 	if len(oldChain) > 0 {
 		for i := len(oldChain) - 1; i >= 0; i-- {
 			bc.chainSideFeed.Send(ChainSideEvent{Block: oldChain[i]})
-			if bc.chainBlockEventChannel != nil {
-				bc.chainBlockEventChannel <- ChainBlockEvent{Block: oldChain[i]}
-			}
+			bc.sendChainBlockEvent(oldChain[i])
 		}
 	}
 	return nil
@@ -2216,9 +2204,7 @@ func (bc *BlockChain) SetChainHead(newBlock *types.Block) error {
 		bc.logsFeed.Send(logs)
 	}
 	bc.chainHeadFeed.Send(ChainHeadEvent{Block: newBlock})
-	if bc.chainBlockEventChannel != nil {
-		bc.chainBlockEventChannel <- ChainBlockEvent{Block: newBlock}
-	}
+	bc.sendChainBlockEvent(newBlock)
 	log.Info("Set the chain head", "number", newBlock.Number(), "hash", newBlock.Hash())
 	return nil
 }
@@ -0,0 +1,141 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// pendingProvenanceLimit is the number of blocks not imported yet whose
+// provenance is kept in memory.
+const pendingProvenanceLimit = 4096
+
+// RecordBlockAnnounce records that a peer announced the hash of a block at the
+// given time. Only the first announcement of a block is kept.
+func (bc *BlockChain) RecordBlockAnnounce(hash common.Hash, number uint64, peer string, at time.Time) {
+	bc.provenanceLock.Lock()
+	defer bc.provenanceLock.Unlock()
+
+	bc.recordProvenance(bc.db, hash, number, func(provenance *rawdb.BlockProvenance) bool {
+		if provenance.AnnouncedAt != 0 {
+			return false
+		}
+		provenance.AnnouncedAt = uint64(at.UnixNano())
+		provenance.AnnouncedBy = peer
+		return true
+	})
+}
+
+// RecordBlockDeliveries records that a peer delivered the full blocks through
+// the given component. The arrival time is taken from the ReceivedAt field of
+// the blocks if set, from the clock otherwise. Only the first delivery of a
+// block is kept.
+func (bc *BlockChain) RecordBlockDeliveries(blocks types.Blocks, peer string, source rawdb.BlockSource) {
+	bc.provenanceLock.Lock()
+	defer bc.provenanceLock.Unlock()
+
+	var (
+		batch = bc.db.NewBatch()
+		now   = time.Now()
+	)
+	for _, block := range blocks {
+		at := block.ReceivedAt
+		if at.IsZero() {
+			at = now
+		}
+		bc.recordProvenance(batch, block.Hash(), block.NumberU64(), func(provenance *rawdb.BlockProvenance) bool {
+			if provenance.ReceivedAt != 0 {
+				return false
+			}
+			provenance.ReceivedAt = uint64(at.UnixNano())
+			provenance.ReceivedBy = peer
+			provenance.Source = source
+			return true
+		})
+	}
+	if err := batch.Write(); err != nil {
+		log.Error("Failed to store block provenance", "err", err)
+	}
+}
+
+// recordProvenance applies an update to the provenance of a block. Blocks in
+// the chain have their provenance stored in the database, while blocks not
+// imported yet only have it kept in memory until their import, so peers cannot
+// fill the database with announcements of blocks that never make it into the
+// chain. The caller must hold the provenance lock.
+func (bc *BlockChain) recordProvenance(db ethdb.KeyValueWriter, hash common.Hash, number uint64, update func(*rawdb.BlockProvenance) bool) {
+	if !bc.HasBlock(hash, number) {
+		provenance := &rawdb.BlockProvenance{Number: number}
+		if pending, ok := bc.pendingProvenance.Get(hash); ok {
+			provenance = pending.(*rawdb.BlockProvenance)
+		}
+		if update(provenance) {
+			bc.pendingProvenance.Add(hash, provenance)
+		}
+		return
+	}
+	provenance := rawdb.ReadBlockProvenance(bc.db, hash)
+	if provenance == nil {
+		// The block may have been imported since it was heard of
+		provenance = &rawdb.BlockProvenance{Number: number}
+		if pending, ok := bc.pendingProvenance.Get(hash); ok {
+			provenance = pending.(*rawdb.BlockProvenance)
+			bc.pendingProvenance.Remove(hash)
+		}
+	}
+	if update(provenance) {
+		rawdb.WriteBlockProvenance(db, hash, provenance)
+	}
+}
+
+// writePendingProvenance moves the provenance of a freshly imported block from
+// memory into the database.
+func (bc *BlockChain) writePendingProvenance(hash common.Hash) {
+	bc.provenanceLock.Lock()
+	defer bc.provenanceLock.Unlock()
+
+	pending, ok := bc.pendingProvenance.Get(hash)
+	if !ok {
+		return
+	}
+	bc.pendingProvenance.Remove(hash)
+	if rawdb.ReadBlockProvenance(bc.db, hash) == nil {
+		rawdb.WriteBlockProvenance(bc.db, hash, pending.(*rawdb.BlockProvenance))
+	}
+}
+
+// readProvenance retrieves the provenance of a block from the database, or from
+// memory if the block was not imported yet.
+func (bc *BlockChain) readProvenance(hash common.Hash) *rawdb.BlockProvenance {
+	if provenance := rawdb.ReadBlockProvenance(bc.db, hash); provenance != nil {
+		return provenance
+	}
+	bc.provenanceLock.Lock()
+	defer bc.provenanceLock.Unlock()
+
+	if pending, ok := bc.pendingProvenance.Get(hash); ok {
+		provenance := *pending.(*rawdb.BlockProvenance)
+		return &provenance
+	}
+	return nil
+}
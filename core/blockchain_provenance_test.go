@@ -0,0 +1,149 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Tests that only the first announcement and the first delivery of a block are
+// recorded, and that the first sight is the earlier of the two.
+func TestBlockProvenance(t *testing.T) {
+	_, chain, err := newCanonical(ethash.NewFaker(), 0, true)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+
+	blocks := makeBlockChain(chain.CurrentBlock(), 2, ethash.NewFaker(), chain.db, 0)
+	if provenance := chain.GetBlockProvenance(blocks[0].Hash()); provenance != nil {
+		t.Fatalf("provenance of unseen block: %+v", provenance)
+	}
+	if _, ok := chain.GetBlockFirstSeen(blocks[0].Hash()); ok {
+		t.Fatalf("first sight of unseen block")
+	}
+	var (
+		announced = time.Unix(1000, 0)
+		received  = time.Unix(1002, 0)
+	)
+	chain.RecordBlockAnnounce(blocks[0].Hash(), 1, "announcer", announced)
+	chain.RecordBlockAnnounce(blocks[0].Hash(), 1, "late announcer", announced.Add(time.Second))
+
+	blocks[0].ReceivedAt = received
+	chain.RecordBlockDeliveries(types.Blocks{blocks[0]}, "deliverer", rawdb.BlockSourceFetcher)
+	chain.RecordBlockDeliveries(blocks, "downloader peer", rawdb.BlockSourceDownloader)
+
+	provenance := chain.GetBlockProvenance(blocks[0].Hash())
+	if provenance == nil {
+		t.Fatalf("missing provenance")
+	}
+	want := rawdb.BlockProvenance{
+		Number:      1,
+		AnnouncedAt: uint64(announced.UnixNano()),
+		AnnouncedBy: "announcer",
+		ReceivedAt:  uint64(received.UnixNano()),
+		ReceivedBy:  "deliverer",
+		Source:      rawdb.BlockSourceFetcher,
+	}
+	if *provenance != want {
+		t.Errorf("provenance mismatch: have %+v, want %+v", *provenance, want)
+	}
+	if seen, ok := chain.GetBlockFirstSeen(blocks[0].Hash()); !ok || !seen.Equal(announced) {
+		t.Errorf("first sight mismatch: have %v, want %v", seen, announced)
+	}
+	// The second block was never announced, only downloaded
+	provenance = chain.GetBlockProvenance(blocks[1].Hash())
+	if provenance == nil || provenance.AnnouncedAt != 0 || provenance.ReceivedBy != "downloader peer" || provenance.Source != rawdb.BlockSourceDownloader || provenance.Number != 2 {
+		t.Errorf("downloaded provenance mismatch: %+v", provenance)
+	}
+	if seen, ok := chain.GetBlockFirstSeen(blocks[1].Hash()); !ok || seen.UnixNano() != int64(provenance.ReceivedAt) {
+		t.Errorf("downloaded first sight mismatch: have %v", seen)
+	}
+	// Nothing is stored until the blocks are imported
+	for i, block := range blocks {
+		if provenance := rawdb.ReadBlockProvenance(chain.db, block.Hash()); provenance != nil {
+			t.Errorf("block %d: provenance stored before import: %+v", i, provenance)
+		}
+	}
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert blocks: %v", err)
+	}
+	if provenance := rawdb.ReadBlockProvenance(chain.db, blocks[0].Hash()); provenance == nil || *provenance != want {
+		t.Errorf("stored provenance mismatch: have %+v, want %+v", provenance, want)
+	}
+	// A late announcement of an imported block goes to the database directly
+	chain.RecordBlockAnnounce(blocks[1].Hash(), 2, "late announcer", received)
+	if provenance := rawdb.ReadBlockProvenance(chain.db, blocks[1].Hash()); provenance == nil || provenance.AnnouncedBy != "late announcer" || provenance.ReceivedBy != "downloader peer" {
+		t.Errorf("stored provenance mismatch after announcement: %+v", provenance)
+	}
+	// Rewinding the chain drops the provenance of the removed blocks
+	if err := chain.SetHead(1); err != nil {
+		t.Fatalf("failed to rewind chain: %v", err)
+	}
+	if provenance := rawdb.ReadBlockProvenance(chain.db, blocks[0].Hash()); provenance == nil {
+		t.Errorf("provenance of kept block dropped")
+	}
+	if provenance := chain.GetBlockProvenance(blocks[1].Hash()); provenance != nil {
+		t.Errorf("provenance of rewound block kept: %+v", provenance)
+	}
+}
+
+// Tests that announcements and deliveries of blocks that never make it into the
+// chain leave nothing in the database.
+func TestBlockProvenanceNotImported(t *testing.T) {
+	_, chain, err := newCanonical(ethash.NewFaker(), 0, true)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+
+	blocks := makeBlockChain(chain.CurrentBlock(), 1, ethash.NewFaker(), chain.db, 0)
+
+	// An unknown hash is announced but never delivered
+	unknown := common.Hash{0x01}
+	chain.RecordBlockAnnounce(unknown, 1, "announcer", time.Now())
+
+	// A block with a corrupt state root is announced and delivered
+	header := blocks[0].Header()
+	header.Root = common.Hash{0x02}
+	invalid := blocks[0].WithSeal(header)
+
+	chain.RecordBlockAnnounce(invalid.Hash(), 1, "announcer", time.Now())
+	chain.RecordBlockDeliveries(types.Blocks{invalid}, "deliverer", rawdb.BlockSourceFetcher)
+	if _, err := chain.InsertChain(types.Blocks{invalid}); err == nil {
+		t.Fatalf("invalid block imported")
+	}
+	for _, hash := range []common.Hash{unknown, invalid.Hash()} {
+		if provenance := rawdb.ReadBlockProvenance(chain.db, hash); provenance != nil {
+			t.Errorf("provenance stored for %x: %+v", hash, provenance)
+		}
+	}
+	// The pending provenance is bounded
+	for i := 0; i < 2*pendingProvenanceLimit; i++ {
+		chain.RecordBlockAnnounce(common.BigToHash(big.NewInt(int64(i))), 1, "spammer", time.Now())
+	}
+	if pending := chain.pendingProvenance.Len(); pending != pendingProvenanceLimit {
+		t.Errorf("pending provenance mismatch: have %d, want %d", pending, pendingProvenanceLimit)
+	}
+}
@@ -18,6 +18,7 @@ package core
 
 import (
 	"math/big"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/consensus"
@@ -73,6 +74,27 @@ func (bc *BlockChain) GetHeaderByNumber(number uint64) *types.Header {
 	return bc.hc.GetHeaderByNumber(number)
 }
 
+// GetHashesByNumber retrieves the hashes of all known blocks of the given
+// number, canonical or not.
+func (bc *BlockChain) GetHashesByNumber(number uint64) []common.Hash {
+	return rawdb.ReadAllHashes(bc.db, number)
+}
+
+// GetBlockProvenance retrieves when and from which peers the node first heard
+// of a block, or nil if it never did.
+func (bc *BlockChain) GetBlockProvenance(hash common.Hash) *rawdb.BlockProvenance {
+	return bc.readProvenance(hash)
+}
+
+// GetBlockFirstSeen retrieves the time a peer first announced or delivered a
+// block, and false if none did.
+func (bc *BlockChain) GetBlockFirstSeen(hash common.Hash) (time.Time, bool) {
+	if provenance := bc.readProvenance(hash); provenance != nil {
+		return provenance.FirstSeen()
+	}
+	return time.Time{}, false
+}
+
 // GetBody retrieves a block body (transactions and uncles) from the database by
 // hash, caching it if found.
 func (bc *BlockChain) GetBody(hash common.Hash) *types.Body {
@@ -375,8 +397,19 @@ func (bc *BlockChain) SubscribeChainSideEvent(ch chan<- ChainSideEvent) event.Su
 	return bc.scope.Track(bc.chainSideFeed.Subscribe(ch))
 }
 
+// SetChainBlockEventSubscription sets the channel receiving a ChainBlockEvent for
+// every block inserted into the chain, canonical or not. A nil channel stops the
+// delivery. Sends are blocking, the channel has to be drained.
 func (bc *BlockChain) SetChainBlockEventSubscription(ch chan ChainBlockEvent) {
-	bc.chainBlockEventChannel = ch
+	bc.chainBlockEventChannel.Store(ch)
+}
+
+// sendChainBlockEvent delivers the block to the channel set by
+// SetChainBlockEventSubscription, if any.
+func (bc *BlockChain) sendChainBlockEvent(block *types.Block) {
+	if ch, _ := bc.chainBlockEventChannel.Load().(chan ChainBlockEvent); ch != nil {
+		ch <- ChainBlockEvent{Block: block}
+	}
 }
 
 // SubscribeLogsEvent registers a subscription of []*types.Log.
@@ -1686,6 +1686,52 @@ func TestTrieForkGC(t *testing.T) {
 	}
 }
 
+// Tests that the state kept for another chain is readable through the shared
+// reader while it's in memory, and written to disk only when it's pruned.
+func TestKeepState(t *testing.T) {
+	engine := ethash.NewFaker()
+
+	db := rawdb.NewMemoryDatabase()
+	genesis := (&Genesis{BaseFee: big.NewInt(params.InitialBaseFee)}).MustCommit(db)
+	blocks, _ := GenerateChain(params.TestChainConfig, genesis, engine, db, 2*TriesInMemory, func(i int, b *BlockGen) { b.SetCoinbase(common.Address{1}) })
+
+	diskdb := rawdb.NewMemoryDatabase()
+	(&Genesis{BaseFee: big.NewInt(params.InitialBaseFee)}).MustCommit(diskdb)
+
+	chain, err := NewBlockChain(diskdb, nil, params.TestChainConfig, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	defer chain.Stop()
+
+	if _, err := chain.InsertChain(blocks[:TriesInMemory]); err != nil {
+		t.Fatalf("failed to insert into chain: %v", err)
+	}
+	kept, other := blocks[TriesInMemory-1], blocks[TriesInMemory-2]
+	chain.KeepState(kept)
+
+	reader := chain.SharedReader()
+	if ok, _ := reader.Has(kept.Root().Bytes()); !ok {
+		t.Fatalf("kept state not readable from memory")
+	}
+	if rawdb.ReadTrieNode(diskdb, kept.Root()) != nil {
+		t.Fatalf("kept state written to disk while in memory")
+	}
+	// Push both states out of the recent tries
+	if _, err := chain.InsertChain(blocks[TriesInMemory:]); err != nil {
+		t.Fatalf("failed to insert into chain: %v", err)
+	}
+	if rawdb.ReadTrieNode(diskdb, kept.Root()) == nil {
+		t.Errorf("kept state not written to disk when pruned")
+	}
+	if rawdb.ReadTrieNode(diskdb, other.Root()) != nil {
+		t.Errorf("other state written to disk when pruned")
+	}
+	if ok, _ := reader.Has(other.Root().Bytes()); ok {
+		t.Errorf("pruned state still readable")
+	}
+}
+
 // Tests that doing large reorgs works even if the state associated with the
 // forking point is not available any more.
 func TestLargeReorgTrieGC(t *testing.T) {
@@ -54,6 +54,10 @@ type ForkChoice struct {
 	// local td is equal to the extern one. It can be nil for light
 	// client
 	preserve func(header *types.Header) bool
+
+	// rule weighs the competing heads before the total difficulty does,
+	// nil to choose by total difficulty alone.
+	rule ForkChoiceRule
 }
 
 func NewForkChoice(chainReader ChainReader, preserve func(header *types.Header) bool) *ForkChoice {
@@ -69,6 +73,18 @@ func NewForkChoice(chainReader ChainReader, preserve func(header *types.Header)
 	}
 }
 
+// SetSeed seeds the random tie breaking, so that experiment runs can be
+// reproduced. It must be called before the fork choice is used.
+func (f *ForkChoice) SetSeed(seed int64) {
+	f.rand = mrand.New(mrand.NewSource(seed))
+}
+
+// SetRule replaces the rule the competing heads are weighed by before the
+// total difficulty decides. It must be called before the fork choice is used.
+func (f *ForkChoice) SetRule(rule ForkChoiceRule) {
+	f.rule = rule
+}
+
 // ReorgNeeded returns whether the reorg should be applied
 // based on the given external header and local canonical chain.
 // In the td mode, the new head is chosen if the corresponding
@@ -88,6 +104,17 @@ func (f *ForkChoice) ReorgNeeded(current *types.Header, header *types.Header) (b
 	if ttd := f.chain.Config().TerminalTotalDifficulty; ttd != nil && ttd.Cmp(externTd) <= 0 {
 		return true, nil
 	}
+	// Let the configured rule decide between competing branches, falling back
+	// to the total difficulty if it can't tell them apart
+	if f.rule != nil {
+		cmp, err := f.rule.Compare(current, header)
+		if err != nil {
+			return false, err
+		}
+		if cmp != 0 {
+			return cmp > 0, nil
+		}
+	}
 	// If the total difficulty is higher than our known, add it to the canonical chain
 	// Second clause in the if statement reduces the vulnerability to selfish mining.
 	// Please refer to http://www.cs.cornell.edu/~ie53/publications/btcProcFC.pdf
@@ -0,0 +1,288 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Fork choice rules a node can weigh competing branches by.
+const (
+	ForkChoiceTD              = "td"                // Highest total difficulty, the rule of the protocol
+	ForkChoiceGHOST           = "ghost"             // Heaviest subtree, counting the ommers its blocks reference
+	ForkChoiceFreshness       = "freshness"         // Freshest head among heads of the same height (Heilman)
+	ForkChoicePublishOrPerish = "publish-or-perish" // Most timely ommer references (Zhang and Preneel)
+)
+
+// DefaultPublishWindow is the number of blocks within which an ommer reference
+// counts as timely for the publish-or-perish rule.
+const DefaultPublishWindow = 3
+
+var errMissingAncestor = errors.New("missing ancestor")
+
+// ForkChoiceConfig selects the fork choice rule of a chain.
+type ForkChoiceConfig struct {
+	Rule   string // Name of the rule, by total difficulty if empty
+	Window uint64 `toml:",omitempty"` // Blocks within which ommer references are timely (publish-or-perish, default = 3)
+}
+
+// Validate checks that the rule exists and its parameters are valid.
+func (c ForkChoiceConfig) Validate() error {
+	switch c.Rule {
+	case "", ForkChoiceTD, ForkChoiceGHOST, ForkChoiceFreshness, ForkChoicePublishOrPerish:
+		return nil
+	default:
+		return fmt.Errorf("unknown fork choice rule %q", c.Rule)
+	}
+}
+
+// ForkChoiceChain defines the chain access of the fork choice rules that look
+// beyond the total difficulty of the competing heads.
+type ForkChoiceChain interface {
+	ChainReader
+
+	// GetHeader retrieves a block header from the database by hash and number.
+	GetHeader(common.Hash, uint64) *types.Header
+
+	// GetBody retrieves a block body (transactions and uncles) by hash.
+	GetBody(common.Hash) *types.Body
+
+	// GetHashesByNumber retrieves the hashes of all known blocks of a number.
+	GetHashesByNumber(uint64) []common.Hash
+
+	// GetBlockFirstSeen retrieves the time a peer first announced or delivered
+	// a block, and false if none did.
+	GetBlockFirstSeen(common.Hash) (time.Time, bool)
+}
+
+// ForkChoiceRule weighs the current head against a competing header. Compare
+// returns a positive number if the header should become the head, a negative
+// one if the current head should be kept, and zero if the rule can't tell them
+// apart, in which case the total difficulty decides.
+type ForkChoiceRule interface {
+	Compare(current *types.Header, header *types.Header) (int, error)
+}
+
+// NewForkChoiceRule creates the configured fork choice rule on top of the given
+// chain. It returns nil for the total difficulty rule.
+func NewForkChoiceRule(config ForkChoiceConfig, chain ForkChoiceChain) (ForkChoiceRule, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	switch config.Rule {
+	case ForkChoiceGHOST:
+		return &ghostRule{chain: chain}, nil
+	case ForkChoiceFreshness:
+		return &freshnessRule{chain: chain}, nil
+	case ForkChoicePublishOrPerish:
+		window := config.Window
+		if window == 0 {
+			window = DefaultPublishWindow
+		}
+		return &publishOrPerishRule{chain: chain, window: window}, nil
+	default:
+		return nil, nil
+	}
+}
+
+// forkPoint returns the first headers of the two branches after their common
+// ancestor, or nils if one of the headers is an ancestor of the other.
+func forkPoint(chain ForkChoiceChain, a, b *types.Header) (*types.Header, *types.Header, error) {
+	var forkA, forkB *types.Header
+	parent := func(header *types.Header) (*types.Header, error) {
+		if parent := chain.GetHeader(header.ParentHash, header.Number.Uint64()-1); parent != nil {
+			return parent, nil
+		}
+		return nil, fmt.Errorf("%w of block %d [%x]", errMissingAncestor, header.Number, header.Hash())
+	}
+	var err error
+	for a.Number.Uint64() > b.Number.Uint64() {
+		forkA = a
+		if a, err = parent(a); err != nil {
+			return nil, nil, err
+		}
+	}
+	for b.Number.Uint64() > a.Number.Uint64() {
+		forkB = b
+		if b, err = parent(b); err != nil {
+			return nil, nil, err
+		}
+	}
+	for a.Hash() != b.Hash() {
+		forkA, forkB = a, b
+		if a, err = parent(a); err != nil {
+			return nil, nil, err
+		}
+		if b, err = parent(b); err != nil {
+			return nil, nil, err
+		}
+	}
+	if forkA == nil || forkB == nil {
+		return nil, nil, nil
+	}
+	return forkA, forkB, nil
+}
+
+// ghostRule prefers the branch whose subtree of known blocks is the heaviest,
+// adding the difficulty of the ommers the blocks of the subtree reference. Blocks
+// that lost a race still count towards the branch they extend, so a withheld
+// branch has to outweigh all the work of the honest miners since the fork.
+type ghostRule struct {
+	chain ForkChoiceChain
+}
+
+func (r *ghostRule) Compare(current *types.Header, header *types.Header) (int, error) {
+	local, extern, err := forkPoint(r.chain, current, header)
+	if err != nil || local == nil {
+		return 0, err
+	}
+	return r.weight(extern).Cmp(r.weight(local)), nil
+}
+
+// weight returns the difficulty of the subtree rooted at the given header,
+// including the ommers its blocks reference.
+func (r *ghostRule) weight(root *types.Header) *big.Int {
+	weight := new(big.Int)
+	for level := []*types.Header{root}; len(level) > 0; {
+		members := make(map[common.Hash]bool, len(level))
+		for _, header := range level {
+			weight.Add(weight, header.Difficulty)
+			if body := r.chain.GetBody(header.Hash()); body != nil {
+				for _, uncle := range body.Uncles {
+					weight.Add(weight, uncle.Difficulty)
+				}
+			}
+			members[header.Hash()] = true
+		}
+		number := level[0].Number.Uint64() + 1
+		level = level[:0:0]
+		for _, hash := range r.chain.GetHashesByNumber(number) {
+			if child := r.chain.GetHeader(hash, number); child != nil && members[child.ParentHash] {
+				level = append(level, child)
+			}
+		}
+	}
+	return weight
+}
+
+// freshnessRule prefers the head with the most recent timestamp among heads of
+// the same height. A withheld block carries the time it was found, so it loses
+// against a block found after it was due to be published. Heads of different
+// heights are left to the total difficulty.
+//
+// Header timestamps are chosen by the miner. Backdating a block only makes it
+// staler, but a block dated ahead would win every race, so the time of a block
+// is capped at the time a peer first announced or delivered it. Blocks never
+// seen from a peer, like the ones mined locally, are taken at their timestamp.
+type freshnessRule struct {
+	chain ForkChoiceChain
+}
+
+func (r *freshnessRule) Compare(current *types.Header, header *types.Header) (int, error) {
+	if current.Number.Cmp(header.Number) != 0 {
+		return 0, nil
+	}
+	currentTime, headerTime := r.freshness(current), r.freshness(header)
+	switch {
+	case headerTime > currentTime:
+		return 1, nil
+	case headerTime < currentTime:
+		return -1, nil
+	default:
+		return 0, nil
+	}
+}
+
+// freshness returns the time the block was found, as far as the node can tell.
+func (r *freshnessRule) freshness(header *types.Header) uint64 {
+	if seen, ok := r.chain.GetBlockFirstSeen(header.Hash()); ok && uint64(seen.Unix()) < header.Time {
+		return uint64(seen.Unix())
+	}
+	return header.Time
+}
+
+// publishOrPerishRule weighs the competing branches by their blocks and the
+// timely ommer references these gathered: every block counts once, plus once
+// for every known block at most window blocks above it that references it as
+// an ommer. Ommers are referenced by the miners of other branches, which can
+// only acknowledge a block in time if it was published in time, so withheld
+// blocks gather no weight beyond their own.
+type publishOrPerishRule struct {
+	chain  ForkChoiceChain
+	window uint64
+}
+
+func (r *publishOrPerishRule) Compare(current *types.Header, header *types.Header) (int, error) {
+	local, extern, err := forkPoint(r.chain, current, header)
+	if err != nil || local == nil {
+		return 0, err
+	}
+	localWeight, err := r.weight(local, current)
+	if err != nil {
+		return 0, err
+	}
+	externWeight, err := r.weight(extern, header)
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case externWeight > localWeight:
+		return 1, nil
+	case externWeight < localWeight:
+		return -1, nil
+	default:
+		return 0, nil
+	}
+}
+
+// weight returns the weight of the branch from the first block to the head.
+func (r *publishOrPerishRule) weight(first, head *types.Header) (int, error) {
+	var (
+		from, to = first.Number.Uint64(), head.Number.Uint64()
+		branch   = make(map[common.Hash]uint64)
+	)
+	for header := head; ; {
+		branch[header.Hash()] = header.Number.Uint64()
+		if header.Hash() == first.Hash() {
+			break
+		}
+		if header = r.chain.GetHeader(header.ParentHash, header.Number.Uint64()-1); header == nil {
+			return 0, errMissingAncestor
+		}
+	}
+	weight := len(branch)
+	for number := from + 1; number <= to+r.window; number++ {
+		for _, hash := range r.chain.GetHashesByNumber(number) {
+			body := r.chain.GetBody(hash)
+			if body == nil {
+				continue
+			}
+			for _, uncle := range body.Uncles {
+				if height, ok := branch[uncle.Hash()]; ok && number <= height+r.window {
+					weight++
+				}
+			}
+		}
+	}
+	return weight, nil
+}
@@ -0,0 +1,200 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// testForkChoice inserts the chunks into a fresh chain with the given fork
+// choice rule and returns the resulting head.
+func testForkChoice(t *testing.T, gspec *Genesis, rule string, chunks ...[]*types.Block) *types.Block {
+	db := rawdb.NewMemoryDatabase()
+	gspec.MustCommit(db)
+
+	chain, err := NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+
+	if err := chain.SetForkChoiceRule(ForkChoiceConfig{Rule: rule}); err != nil {
+		t.Fatalf("failed to set fork choice rule: %v", err)
+	}
+	for _, chunk := range chunks {
+		if _, err := chain.InsertChain(chunk); err != nil {
+			t.Fatalf("%s: failed to insert blocks: %v", rule, err)
+		}
+	}
+	return chain.CurrentBlock()
+}
+
+// addOmmer references the given block as an ommer as is, unlike AddUncle, which
+// derives a new ommer header from it.
+func addOmmer(b *BlockGen, header *types.Header) {
+	b.uncles = append(b.uncles, header)
+}
+
+// Tests that the GHOST rule keeps a branch whose subtree, ommers included, is
+// heavier than a longer competing branch.
+func TestForkChoiceGHOST(t *testing.T) {
+	var (
+		db      = rawdb.NewMemoryDatabase()
+		gspec   = &Genesis{Config: params.TestChainConfig}
+		genesis = gspec.MustCommit(db)
+	)
+	// Branch a has a stale block s2, referenced as an ommer by a3
+	a, _ := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), db, 2, nil)
+	s, _ := GenerateChain(gspec.Config, a[0], ethash.NewFaker(), db, 1, func(i int, b *BlockGen) {
+		b.SetCoinbase(common.Address{0x01})
+	})
+	a3, _ := GenerateChain(gspec.Config, a[1], ethash.NewFaker(), db, 1, func(i int, b *BlockGen) {
+		addOmmer(b, s[0].Header())
+	})
+	b, _ := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), db, 4, func(i int, b *BlockGen) {
+		b.SetCoinbase(common.Address{0x02})
+	})
+	if head := testForkChoice(t, gspec, ForkChoiceTD, a, s, a3, b); head.Hash() != b[3].Hash() {
+		t.Errorf("td: head mismatch: have %d [%x], want %d [%x]", head.Number(), head.Hash(), b[3].Number(), b[3].Hash())
+	}
+	if head := testForkChoice(t, gspec, ForkChoiceGHOST, a, s, a3, b); head.Hash() != a3[0].Hash() {
+		t.Errorf("ghost: head mismatch: have %d [%x], want %d [%x]", head.Number(), head.Hash(), a3[0].Number(), a3[0].Hash())
+	}
+}
+
+// Tests that the freshness rule prefers the later of two blocks of the same
+// height, so a backdated block loses even though it has the higher difficulty.
+func TestForkChoiceFreshness(t *testing.T) {
+	var (
+		db      = rawdb.NewMemoryDatabase()
+		gspec   = &Genesis{Config: params.TestChainConfig}
+		genesis = gspec.MustCommit(db)
+	)
+	fresh, _ := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), db, 1, nil)
+	stale, _ := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), db, 1, func(i int, b *BlockGen) {
+		b.SetCoinbase(common.Address{0x01})
+		b.OffsetTime(-5)
+	})
+	if head := testForkChoice(t, gspec, ForkChoiceTD, fresh, stale); head.Hash() != stale[0].Hash() {
+		t.Errorf("td: head mismatch: have [%x], want [%x]", head.Hash(), stale[0].Hash())
+	}
+	if head := testForkChoice(t, gspec, ForkChoiceFreshness, fresh, stale); head.Hash() != fresh[0].Hash() {
+		t.Errorf("freshness: head mismatch: have [%x], want [%x]", head.Hash(), fresh[0].Hash())
+	}
+	if head := testForkChoice(t, gspec, ForkChoiceFreshness, stale, fresh); head.Hash() != fresh[0].Hash() {
+		t.Errorf("freshness: head mismatch after reorg: have [%x], want [%x]", head.Hash(), fresh[0].Hash())
+	}
+}
+
+// Tests that the freshness rule doesn't take a block dated ahead at its word,
+// but at the time a peer first delivered it.
+func TestForkChoiceFreshnessFirstSeen(t *testing.T) {
+	var (
+		db      = rawdb.NewMemoryDatabase()
+		gspec   = &Genesis{Config: params.TestChainConfig}
+		genesis = gspec.MustCommit(db)
+	)
+	// The local block is found at 10, the competing one is dated at 20 but was
+	// delivered at 5 already
+	local, _ := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), db, 1, nil)
+	ahead, _ := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), db, 1, func(i int, b *BlockGen) {
+		b.SetCoinbase(common.Address{0x01})
+		b.OffsetTime(10)
+	})
+	ahead[0].ReceivedAt = time.Unix(5, 0)
+
+	for i, chunks := range [][]types.Blocks{{local, ahead}, {ahead, local}} {
+		db := rawdb.NewMemoryDatabase()
+		gspec.MustCommit(db)
+
+		chain, err := NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{}, nil, nil)
+		if err != nil {
+			t.Fatalf("failed to create chain: %v", err)
+		}
+		if err := chain.SetForkChoiceRule(ForkChoiceConfig{Rule: ForkChoiceFreshness}); err != nil {
+			t.Fatalf("failed to set fork choice rule: %v", err)
+		}
+		chain.RecordBlockDeliveries(ahead, "peer", rawdb.BlockSourceFetcher)
+		for _, chunk := range chunks {
+			if _, err := chain.InsertChain(chunk); err != nil {
+				t.Fatalf("test %d: failed to insert blocks: %v", i, err)
+			}
+		}
+		if head := chain.CurrentBlock(); head.Hash() != local[0].Hash() {
+			t.Errorf("test %d: head mismatch: have [%x], want [%x]", i, head.Hash(), local[0].Hash())
+		}
+		chain.Stop()
+	}
+	// Without a delivery on record, the timestamp is all there is to go by
+	if head := testForkChoice(t, gspec, ForkChoiceFreshness, local, ahead); head.Hash() != ahead[0].Hash() {
+		t.Errorf("head mismatch without delivery: have [%x], want [%x]", head.Hash(), ahead[0].Hash())
+	}
+}
+
+// Tests that the publish-or-perish rule keeps a branch whose blocks were
+// referenced in time against longer branches that gathered no references.
+func TestForkChoicePublishOrPerish(t *testing.T) {
+	var (
+		db      = rawdb.NewMemoryDatabase()
+		gspec   = &Genesis{Config: params.TestChainConfig}
+		genesis = gspec.MustCommit(db)
+	)
+	// The first block of h is referenced by both children of w1
+	h, _ := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), db, 2, nil)
+	w, _ := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), db, 1, func(i int, b *BlockGen) {
+		b.SetCoinbase(common.Address{0x01})
+	})
+	y, _ := GenerateChain(gspec.Config, w[0], ethash.NewFaker(), db, 1, func(i int, b *BlockGen) {
+		b.SetCoinbase(common.Address{0x02})
+		addOmmer(b, h[0].Header())
+	})
+	wrest, _ := GenerateChain(gspec.Config, w[0], ethash.NewFaker(), db, 2, func(i int, b *BlockGen) {
+		b.SetCoinbase(common.Address{0x01})
+		if i == 0 {
+			addOmmer(b, h[0].Header())
+		}
+	})
+	// The withheld branch is longer, but nobody saw it in time
+	p, _ := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), db, 3, func(i int, b *BlockGen) {
+		b.SetCoinbase(common.Address{0x03})
+	})
+	if head := testForkChoice(t, gspec, ForkChoiceTD, h, append(w, y...), wrest, p); head.NumberU64() != 3 {
+		t.Errorf("td: head number mismatch: have %d, want 3", head.NumberU64())
+	}
+	if head := testForkChoice(t, gspec, ForkChoicePublishOrPerish, h, append(w, y...), wrest, p); head.Hash() != h[1].Hash() {
+		t.Errorf("publish-or-perish: head mismatch: have %d [%x], want %d [%x]", head.Number(), head.Hash(), h[1].Number(), h[1].Hash())
+	}
+}
+
+func TestForkChoiceConfigValidate(t *testing.T) {
+	for _, rule := range []string{"", ForkChoiceTD, ForkChoiceGHOST, ForkChoiceFreshness, ForkChoicePublishOrPerish} {
+		if err := (ForkChoiceConfig{Rule: rule}).Validate(); err != nil {
+			t.Errorf("rule %q: unexpected error: %v", rule, err)
+		}
+	}
+	if err := (ForkChoiceConfig{Rule: "longest"}).Validate(); err == nil {
+		t.Errorf("unknown rule accepted")
+	}
+}
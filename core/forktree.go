@@ -0,0 +1,415 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+var (
+	// errUnknownForkParent is returned when a block is added to the fork tree
+	// before its parent.
+	errUnknownForkParent = errors.New("unknown parent")
+
+	// errNotAncestor is returned when a path is requested between two blocks of
+	// the fork tree that are not on the same branch.
+	errNotAncestor = errors.New("not an ancestor")
+)
+
+// BlockOrigin tells where a block of the fork tree came from.
+type BlockOrigin uint8
+
+const (
+	OriginUnknown BlockOrigin = iota // loaded from the database, the origin was not recorded
+	OriginLocal                      // mined by this node
+	OriginPeer                       // received from a peer
+)
+
+// String implements fmt.Stringer.
+func (o BlockOrigin) String() string {
+	switch o {
+	case OriginLocal:
+		return "local"
+	case OriginPeer:
+		return "peer"
+	default:
+		return "unknown"
+	}
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (o BlockOrigin) MarshalText() ([]byte, error) {
+	return []byte(o.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (o *BlockOrigin) UnmarshalText(input []byte) error {
+	switch string(input) {
+	case "local":
+		*o = OriginLocal
+	case "peer":
+		*o = OriginPeer
+	case "unknown":
+		*o = OriginUnknown
+	default:
+		return fmt.Errorf("invalid block origin %q", input)
+	}
+	return nil
+}
+
+// ForkNode is a block of the fork tree. The fields are set when the block is
+// added and must not be modified.
+type ForkNode struct {
+	Block     *types.Block
+	Td        *big.Int    // Total difficulty of the chain up to and including the block
+	FirstSeen time.Time   // Time the block was added to the tree, zero if loaded from the database
+	Origin    BlockOrigin // Whether the block was mined locally or received from a peer
+	Peer      string      // Peer the block was received from, if any
+
+	parent   *ForkNode
+	children []*ForkNode
+}
+
+// Hash returns the hash of the block.
+func (n *ForkNode) Hash() common.Hash {
+	return n.Block.Hash()
+}
+
+// Number returns the number of the block.
+func (n *ForkNode) Number() uint64 {
+	return n.Block.NumberU64()
+}
+
+// ForkTree is an in-memory index of the blocks of a chain above a root block,
+// including the blocks of all side branches. Blocks are linked to their parent
+// and children, so that forks can be followed in either direction. The tree is
+// safe for concurrent use.
+type ForkTree struct {
+	root     *ForkNode
+	heaviest *ForkNode // Leaf with the highest total difficulty, the first seen one in a tie
+	nodes    map[common.Hash]*ForkNode
+	lock     sync.RWMutex
+}
+
+// NewForkTree creates a fork tree rooted at the given block, which has the
+// given total difficulty.
+func NewForkTree(root *types.Block, td *big.Int) *ForkTree {
+	node := &ForkNode{Block: root, Td: new(big.Int).Set(td)}
+	return &ForkTree{
+		root:     node,
+		heaviest: node,
+		nodes:    map[common.Hash]*ForkNode{root.Hash(): node},
+	}
+}
+
+// NewForkTree creates a fork tree rooted at the canonical block with the given
+// number, holding all stored blocks descending from it.
+func (bc *BlockChain) NewForkTree(number uint64) (*ForkTree, error) {
+	root := bc.GetBlockByNumber(number)
+	if root == nil {
+		return nil, fmt.Errorf("missing canonical block %d", number)
+	}
+	td := bc.GetTd(root.Hash(), number)
+	if td == nil {
+		return nil, fmt.Errorf("missing total difficulty of block %d", number)
+	}
+	tree := NewForkTree(root, td)
+	for n := number + 1; ; n++ {
+		hashes := rawdb.ReadAllHashes(bc.db, n)
+		if len(hashes) == 0 {
+			break
+		}
+		for _, hash := range hashes {
+			// Blocks of side branches forking below the root are skipped
+			if block := bc.GetBlock(hash, n); block != nil {
+				tree.add(block, OriginUnknown, "", time.Time{})
+			}
+		}
+	}
+	return tree, nil
+}
+
+// Add inserts a block into the tree, recording the current time as the time it
+// was first seen. The parent of the block must be in the tree already. Adding a
+// known block keeps the block as it was first added.
+func (t *ForkTree) Add(block *types.Block, origin BlockOrigin, peer string) error {
+	return t.add(block, origin, peer, time.Now())
+}
+
+func (t *ForkTree) add(block *types.Block, origin BlockOrigin, peer string, seen time.Time) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	hash := block.Hash()
+	if _, ok := t.nodes[hash]; ok {
+		return nil
+	}
+	parent, ok := t.nodes[block.ParentHash()]
+	if !ok {
+		return errUnknownForkParent
+	}
+	node := &ForkNode{
+		Block:     block,
+		Td:        new(big.Int).Add(parent.Td, block.Difficulty()),
+		FirstSeen: seen,
+		Origin:    origin,
+		Peer:      peer,
+		parent:    parent,
+	}
+	parent.children = append(parent.children, node)
+	t.nodes[hash] = node
+
+	if node.Td.Cmp(t.heaviest.Td) > 0 {
+		t.heaviest = node
+	}
+	return nil
+}
+
+// Has reports whether the block with the given hash is in the tree.
+func (t *ForkTree) Has(hash common.Hash) bool {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	_, ok := t.nodes[hash]
+	return ok
+}
+
+// Get returns the block with the given hash, or nil if it is not in the tree.
+func (t *ForkTree) Get(hash common.Hash) *ForkNode {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	return t.nodes[hash]
+}
+
+// Len returns the number of blocks in the tree, including the root.
+func (t *ForkTree) Len() int {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	return len(t.nodes)
+}
+
+// Root returns the root block of the tree.
+func (t *ForkTree) Root() *ForkNode {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	return t.root
+}
+
+// HeaviestLeaf returns the block with the highest total difficulty. Of blocks
+// with equal total difficulty, the first one added is returned. This is not
+// necessarily the head of the chain, whose fork choice may break ties or pick
+// heads by other rules.
+func (t *ForkTree) HeaviestLeaf() *ForkNode {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	return t.heaviest
+}
+
+// Parent returns the parent of the block with the given hash, or nil if the
+// block is the root or not in the tree.
+func (t *ForkTree) Parent(hash common.Hash) *ForkNode {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	if node, ok := t.nodes[hash]; ok {
+		return node.parent
+	}
+	return nil
+}
+
+// Children returns the children of the block with the given hash, in the order
+// they were added.
+func (t *ForkTree) Children(hash common.Hash) []*ForkNode {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	node, ok := t.nodes[hash]
+	if !ok {
+		return nil
+	}
+	children := make([]*ForkNode, len(node.children))
+	copy(children, node.children)
+	return children
+}
+
+// Leaves returns the blocks without children, in depth-first order.
+func (t *ForkTree) Leaves() []*ForkNode {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	var leaves []*ForkNode
+	t.walk(t.root, func(node *ForkNode) {
+		if len(node.children) == 0 {
+			leaves = append(leaves, node)
+		}
+	})
+	return leaves
+}
+
+// CommonAncestor returns the latest block both given blocks descend from, or
+// nil if either block is not in the tree.
+func (t *ForkTree) CommonAncestor(a, b common.Hash) *ForkNode {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	x, y := t.nodes[a], t.nodes[b]
+	if x == nil || y == nil {
+		return nil
+	}
+	return commonForkAncestor(x, y)
+}
+
+// Path returns the blocks leading from the ancestor (exclusive) to the head
+// (inclusive), in ascending order.
+func (t *ForkTree) Path(ancestor, head common.Hash) (types.Blocks, error) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	from, to := t.nodes[ancestor], t.nodes[head]
+	if from == nil || to == nil {
+		return nil, errors.New("unknown block")
+	}
+	if to.Number() < from.Number() {
+		return nil, errNotAncestor
+	}
+	blocks := make(types.Blocks, to.Number()-from.Number())
+	for i := len(blocks) - 1; i >= 0; i-- {
+		blocks[i] = to.Block
+		to = to.parent
+	}
+	if to != from {
+		return nil, errNotAncestor
+	}
+	return blocks, nil
+}
+
+// Branches returns the blocks leading from the given block (exclusive) to each
+// of the leaves descending from it, in depth-first order.
+func (t *ForkTree) Branches(from common.Hash) []types.Blocks {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	node, ok := t.nodes[from]
+	if !ok {
+		return nil
+	}
+	var (
+		branches []types.Blocks
+		branch   types.Blocks
+		visit    func(node *ForkNode)
+	)
+	visit = func(node *ForkNode) {
+		for _, child := range node.children {
+			branch = append(branch, child.Block)
+			if len(child.children) == 0 {
+				branches = append(branches, append(types.Blocks{}, branch...))
+			} else {
+				visit(child)
+			}
+			branch = branch[:len(branch)-1]
+		}
+	}
+	visit(node)
+	return branches
+}
+
+// Prune drops the blocks that are more than the given depth below the given
+// head, along with all branches forking off before that depth. The ancestor of
+// the head at the given depth becomes the new root. The head is the one picked
+// by the fork choice of the chain, which need not be the heaviest leaf. It
+// returns the number of blocks dropped.
+func (t *ForkTree) Prune(head common.Hash, depth uint64) int {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	node, ok := t.nodes[head]
+	if !ok {
+		return 0
+	}
+	number := node.Number()
+	if number < depth || number-depth <= t.root.Number() {
+		return 0
+	}
+	root := node
+	for root.Number() > number-depth {
+		root = root.parent
+	}
+	before := len(t.nodes)
+	t.drop(t.root, root)
+	root.parent = nil
+	t.root = root
+	if _, ok := t.nodes[t.heaviest.Hash()]; !ok {
+		t.heaviest = nil
+		t.walk(root, func(n *ForkNode) {
+			if len(n.children) == 0 && (t.heaviest == nil || n.Td.Cmp(t.heaviest.Td) > 0) {
+				t.heaviest = n
+			}
+		})
+	}
+	return before - len(t.nodes)
+}
+
+// drop removes the node and its descendants from the index, except for the
+// subtree of the kept node.
+func (t *ForkTree) drop(node *ForkNode, keep *ForkNode) {
+	if node == keep {
+		return
+	}
+	delete(t.nodes, node.Hash())
+	for _, child := range node.children {
+		t.drop(child, keep)
+	}
+}
+
+// walk calls the function for the node and all its descendants, in depth-first
+// order.
+func (t *ForkTree) walk(node *ForkNode, fn func(*ForkNode)) {
+	fn(node)
+	for _, child := range node.children {
+		t.walk(child, fn)
+	}
+}
+
+// commonForkAncestor returns the latest block both nodes descend from, or nil
+// if they are not connected.
+func commonForkAncestor(a, b *ForkNode) *ForkNode {
+	for a != nil && b != nil && a.Number() > b.Number() {
+		a = a.parent
+	}
+	for a != nil && b != nil && b.Number() > a.Number() {
+		b = b.parent
+	}
+	for a != nil && b != nil && a != b {
+		a, b = a.parent, b.parent
+	}
+	if a == nil || b == nil {
+		return nil
+	}
+	return a
+}
@@ -0,0 +1,171 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// newForkTreeChains generates a main chain of five blocks and a side chain of
+// two blocks forking off after the second block of the main chain.
+func newForkTreeChains() (*Genesis, *types.Block, []*types.Block, []*types.Block) {
+	var (
+		db      = rawdb.NewMemoryDatabase()
+		gspec   = &Genesis{Config: params.TestChainConfig}
+		genesis = gspec.MustCommit(db)
+	)
+	main, _ := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), db, 5, nil)
+	side, _ := GenerateChain(gspec.Config, main[1], ethash.NewFaker(), db, 2, func(i int, b *BlockGen) {
+		b.SetCoinbase(common.Address{0x01})
+	})
+	return gspec, genesis, main, side
+}
+
+// Tests that the fork tree links blocks across branches and answers the
+// queries on them.
+func TestForkTree(t *testing.T) {
+	_, genesis, main, side := newForkTreeChains()
+
+	tree := NewForkTree(genesis, genesis.Difficulty())
+	for _, block := range append(main, side...) {
+		if err := tree.Add(block, OriginPeer, "peer"); err != nil {
+			t.Fatalf("failed to add block %d: %v", block.NumberU64(), err)
+		}
+	}
+	if err := tree.Add(main[0], OriginLocal, ""); err != nil {
+		t.Fatalf("failed to add known block: %v", err)
+	}
+	if node := tree.Get(main[0].Hash()); node.Origin != OriginPeer {
+		t.Errorf("known block overwritten, origin %v", node.Origin)
+	}
+	orphan := types.NewBlockWithHeader(&types.Header{ParentHash: common.Hash{0x01}, Number: main[4].Number()})
+	if err := tree.Add(orphan, OriginPeer, "peer"); err != errUnknownForkParent {
+		t.Errorf("orphan block error mismatch: have %v, want %v", err, errUnknownForkParent)
+	}
+	if tree.Len() != 8 {
+		t.Errorf("tree size mismatch: have %d, want %d", tree.Len(), 8)
+	}
+	if leaf := tree.HeaviestLeaf(); leaf.Hash() != main[4].Hash() {
+		t.Errorf("heaviest leaf mismatch: have %d, want %d", leaf.Number(), main[4].NumberU64())
+	}
+	if leaves := tree.Leaves(); len(leaves) != 2 {
+		t.Errorf("leaf count mismatch: have %d, want %d", len(leaves), 2)
+	}
+	if children := tree.Children(main[1].Hash()); len(children) != 2 {
+		t.Errorf("child count mismatch: have %d, want %d", len(children), 2)
+	}
+	if ancestor := tree.CommonAncestor(main[4].Hash(), side[1].Hash()); ancestor == nil || ancestor.Hash() != main[1].Hash() {
+		t.Errorf("common ancestor mismatch: have %v, want %d", ancestor, main[1].NumberU64())
+	}
+	path, err := tree.Path(main[1].Hash(), side[1].Hash())
+	if err != nil {
+		t.Fatalf("failed to get path: %v", err)
+	}
+	if len(path) != 2 || path[0].Hash() != side[0].Hash() || path[1].Hash() != side[1].Hash() {
+		t.Errorf("path mismatch: have %d blocks", len(path))
+	}
+	if _, err := tree.Path(side[0].Hash(), main[4].Hash()); err != errNotAncestor {
+		t.Errorf("path across branches error mismatch: have %v, want %v", err, errNotAncestor)
+	}
+	if branches := tree.Branches(main[1].Hash()); len(branches) != 2 || len(branches[0]) != 3 || len(branches[1]) != 2 {
+		t.Errorf("branches mismatch: have %v", branches)
+	}
+	// Pruning to two blocks below the head drops the side branch
+	if dropped := tree.Prune(main[4].Hash(), 2); dropped != 5 {
+		t.Errorf("dropped block count mismatch: have %d, want %d", dropped, 5)
+	}
+	if root := tree.Root(); root.Hash() != main[2].Hash() || tree.Parent(root.Hash()) != nil {
+		t.Errorf("root mismatch: have %d, want %d", root.Number(), main[2].NumberU64())
+	}
+	if tree.Len() != 3 || tree.Has(side[0].Hash()) {
+		t.Errorf("pruned tree mismatch: %d blocks, side branch kept %t", tree.Len(), tree.Has(side[0].Hash()))
+	}
+	if dropped := tree.Prune(main[4].Hash(), 2); dropped != 0 {
+		t.Errorf("repeated pruning dropped %d blocks", dropped)
+	}
+}
+
+// Tests that pruning keeps the path of the given head, even if it is not the
+// heaviest leaf of the tree.
+func TestForkTreePruneHead(t *testing.T) {
+	_, genesis, main, side := newForkTreeChains()
+
+	tree := NewForkTree(genesis, genesis.Difficulty())
+	for _, block := range append(main[:4], side...) {
+		if err := tree.Add(block, OriginPeer, "peer"); err != nil {
+			t.Fatalf("failed to add block %d: %v", block.NumberU64(), err)
+		}
+	}
+	// Both branches are equally heavy, the main one was added first
+	if leaf := tree.HeaviestLeaf(); leaf.Hash() != main[3].Hash() {
+		t.Fatalf("heaviest leaf mismatch: have %x, want %x", leaf.Hash(), main[3].Hash())
+	}
+	if dropped := tree.Prune(side[1].Hash(), 1); dropped != 5 {
+		t.Errorf("dropped block count mismatch: have %d, want %d", dropped, 5)
+	}
+	if root := tree.Root(); root.Hash() != side[0].Hash() {
+		t.Errorf("root mismatch: have %x, want %x", root.Hash(), side[0].Hash())
+	}
+	if !tree.Has(side[1].Hash()) || tree.Has(main[3].Hash()) {
+		t.Errorf("pruned tree mismatch: head kept %t, other branch kept %t", tree.Has(side[1].Hash()), tree.Has(main[3].Hash()))
+	}
+	if leaf := tree.HeaviestLeaf(); leaf.Hash() != side[1].Hash() {
+		t.Errorf("heaviest leaf mismatch after pruning: have %x, want %x", leaf.Hash(), side[1].Hash())
+	}
+	if dropped := tree.Prune(common.Hash{0x01}, 1); dropped != 0 {
+		t.Errorf("pruning on unknown head dropped %d blocks", dropped)
+	}
+}
+
+// Tests that the fork tree of a chain holds the stored side branches.
+func TestBlockChainForkTree(t *testing.T) {
+	gspec, _, main, side := newForkTreeChains()
+
+	db := rawdb.NewMemoryDatabase()
+	gspec.MustCommit(db)
+	chain, err := NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+
+	for _, blocks := range [][]*types.Block{main, side} {
+		if _, err := chain.InsertChain(blocks); err != nil {
+			t.Fatalf("failed to insert blocks: %v", err)
+		}
+	}
+	tree, err := chain.NewForkTree(1)
+	if err != nil {
+		t.Fatalf("failed to create fork tree: %v", err)
+	}
+	if tree.Len() != 7 {
+		t.Errorf("tree size mismatch: have %d, want %d", tree.Len(), 7)
+	}
+	if td := chain.GetTd(main[4].Hash(), 5); tree.HeaviestLeaf().Td.Cmp(td) != 0 {
+		t.Errorf("total difficulty mismatch: have %v, want %v", tree.HeaviestLeaf().Td, td)
+	}
+	if node := tree.Get(side[1].Hash()); node == nil || node.Origin != OriginUnknown || !node.FirstSeen.IsZero() {
+		t.Errorf("stored block mismatch: have %+v", node)
+	}
+}
@@ -151,3 +151,18 @@ func WriteTransitionStatus(db ethdb.KeyValueWriter, data []byte) {
 		log.Crit("Failed to store the eth2 transition status", "err", err)
 	}
 }
+
+// ReadSelfishMiningState retrieves the serialized state of the mining strategy
+// from the database
+func ReadSelfishMiningState(db ethdb.KeyValueReader) []byte {
+	data, _ := db.Get(selfishMiningStateKey)
+	return data
+}
+
+// WriteSelfishMiningState stores the serialized state of the mining strategy to
+// the database
+func WriteSelfishMiningState(db ethdb.KeyValueWriter, data []byte) {
+	if err := db.Put(selfishMiningStateKey, data); err != nil {
+		log.Crit("Failed to store the selfish mining state", "err", err)
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// BlockSource is the component of the node a full block arrived through.
+type BlockSource uint8
+
+const (
+	BlockSourceUnknown    BlockSource = iota // Full block not received yet
+	BlockSourceFetcher                       // Propagated block, broadcast or fetched after an announcement
+	BlockSourceDownloader                    // Block retrieved by the chain synchroniser
+)
+
+// String implements the stringer interface.
+func (s BlockSource) String() string {
+	switch s {
+	case BlockSourceFetcher:
+		return "fetcher"
+	case BlockSourceDownloader:
+		return "downloader"
+	default:
+		return "unknown"
+	}
+}
+
+// BlockProvenance records when the node first heard of a block and which peers
+// it learned about it from. Times are unix nanoseconds, zero if the event did
+// not happen yet.
+type BlockProvenance struct {
+	Number      uint64
+	AnnouncedAt uint64      // First announcement of the hash
+	AnnouncedBy string      // Peer of the first announcement
+	ReceivedAt  uint64      // First delivery of the full block
+	ReceivedBy  string      // Peer of the first delivery
+	Source      BlockSource // Component the full block arrived through
+}
+
+// FirstSeen returns the time the block was first announced or delivered,
+// whichever came first, and false if neither happened.
+func (p *BlockProvenance) FirstSeen() (time.Time, bool) {
+	seen := p.AnnouncedAt
+	if seen == 0 || (p.ReceivedAt != 0 && p.ReceivedAt < seen) {
+		seen = p.ReceivedAt
+	}
+	if seen == 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(0, int64(seen)), true
+}
+
+// ReadBlockProvenance retrieves the provenance of a block, or nil if none was
+// stored. Provenance is only stored for blocks written into the database.
+func ReadBlockProvenance(db ethdb.KeyValueReader, hash common.Hash) *BlockProvenance {
+	data, _ := db.Get(blockProvenanceKey(hash))
+	if len(data) == 0 {
+		return nil
+	}
+	provenance := new(BlockProvenance)
+	if err := rlp.DecodeBytes(data, provenance); err != nil {
+		log.Error("Invalid block provenance RLP", "hash", hash, "err", err)
+		return nil
+	}
+	return provenance
+}
+
+// WriteBlockProvenance stores the provenance of a block into the database.
+func WriteBlockProvenance(db ethdb.KeyValueWriter, hash common.Hash, provenance *BlockProvenance) {
+	data, err := rlp.EncodeToBytes(provenance)
+	if err != nil {
+		log.Crit("Failed to RLP encode block provenance", "err", err)
+	}
+	if err := db.Put(blockProvenanceKey(hash), data); err != nil {
+		log.Crit("Failed to store block provenance", "err", err)
+	}
+}
+
+// DeleteBlockProvenance removes the provenance of a block from the database.
+func DeleteBlockProvenance(db ethdb.KeyValueWriter, hash common.Hash) {
+	if err := db.Delete(blockProvenanceKey(hash)); err != nil {
+		log.Crit("Failed to delete block provenance", "err", err)
+	}
+}
@@ -437,6 +437,7 @@ func (f *freezer) freeze(db ethdb.KeyValueStore) {
 				for _, hash := range dangling {
 					log.Trace("Deleting side chain", "number", number, "hash", hash)
 					DeleteBlock(batch, hash, number)
+					DeleteBlockProvenance(batch, hash)
 				}
 			}
 		}
@@ -470,6 +471,7 @@ func (f *freezer) freeze(db ethdb.KeyValueStore) {
 					// Delete all block data associated with the child
 					log.Debug("Deleting dangling block", "number", tip, "hash", children[i], "parent", child.ParentHash)
 					DeleteBlock(batch, children[i], tip)
+					DeleteBlockProvenance(batch, children[i])
 				}
 				dangling = children
 				tip++
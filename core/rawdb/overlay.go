@@ -0,0 +1,139 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"bytes"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+// overlay is a wrapper around a database that falls back to a shared database
+// when reading content-addressed data it doesn't have, and reads the ancient
+// store of the shared database instead of its own.
+type overlay struct {
+	ethdb.Database
+	shared ethdb.Reader
+}
+
+// NewOverlayDatabase returns a database object that writes into the given
+// database, but reads the blocks, receipts, total difficulties, trie nodes and
+// contract code missing from it out of the shared database. This allows a second
+// chain to use the blocks and state of a chain without storing or executing them
+// again.
+//
+// Data that is specific to a chain, like the canonical hashes, the head markers,
+// the transaction lookups and the snapshot, is only read from the given database.
+// Deletions don't affect the shared database.
+//
+// Frozen blocks are final for both chains, so the ancient store is the one of
+// the shared database, which the overlay never modifies. The given database is
+// expected to run without a freezer of its own.
+func NewOverlayDatabase(db ethdb.Database, shared ethdb.Reader) ethdb.Database {
+	return &overlay{
+		Database: db,
+		shared:   shared,
+	}
+}
+
+// Has retrieves if a key is present in the database, or if it is a shared key
+// present in the shared database.
+func (o *overlay) Has(key []byte) (bool, error) {
+	if ok, err := o.Database.Has(key); ok || err != nil || !isSharedKey(key) {
+		return ok, err
+	}
+	return o.shared.Has(key)
+}
+
+// Get retrieves the given key if it's present in the database, or if it is a
+// shared key present in the shared database.
+func (o *overlay) Get(key []byte) ([]byte, error) {
+	if data, err := o.Database.Get(key); err == nil || !isSharedKey(key) {
+		return data, err
+	}
+	return o.shared.Get(key)
+}
+
+// HasAncient returns an indicator whether the specified data exists in the
+// shared ancient store.
+func (o *overlay) HasAncient(kind string, number uint64) (bool, error) {
+	return o.shared.HasAncient(kind, number)
+}
+
+// Ancient retrieves an ancient binary blob from the shared ancient store.
+func (o *overlay) Ancient(kind string, number uint64) ([]byte, error) {
+	return o.shared.Ancient(kind, number)
+}
+
+// AncientRange retrieves multiple items in sequence from the shared ancient
+// store.
+func (o *overlay) AncientRange(kind string, start, count, maxBytes uint64) ([][]byte, error) {
+	return o.shared.AncientRange(kind, start, count, maxBytes)
+}
+
+// Ancients returns the number of items in the shared ancient store.
+func (o *overlay) Ancients() (uint64, error) {
+	return o.shared.Ancients()
+}
+
+// AncientSize returns the size of the specified category in the shared ancient
+// store.
+func (o *overlay) AncientSize(kind string) (uint64, error) {
+	return o.shared.AncientSize(kind)
+}
+
+// ReadAncients runs the given read operation on the shared ancient store.
+func (o *overlay) ReadAncients(fn func(reader ethdb.AncientReader) error) (err error) {
+	return o.shared.ReadAncients(fn)
+}
+
+// ModifyAncients is not supported, the shared ancient store is only written by
+// the chain owning it.
+func (o *overlay) ModifyAncients(func(ethdb.AncientWriteOp) error) (int64, error) {
+	return 0, errNotSupported
+}
+
+// TruncateAncients is a noop. The shared ancient store only holds blocks final
+// for both chains, so a rewind of the overlay chain below it leaves it intact.
+func (o *overlay) TruncateAncients(items uint64) error {
+	return nil
+}
+
+// Sync is a noop, the overlay never writes to the shared ancient store.
+func (o *overlay) Sync() error {
+	return nil
+}
+
+// isSharedKey reports whether the key refers to data identified by its hash,
+// which is the same in every chain containing it.
+func isSharedKey(key []byte) bool {
+	switch {
+	case len(key) == common.HashLength:
+		// Legacy trie node keyed by its hash
+		return true
+	case bytes.HasPrefix(key, headerPrefix):
+		// Headers and total difficulties, but not the canonical hashes
+		return len(key) == len(headerPrefix)+8+common.HashLength ||
+			len(key) == len(headerPrefix)+8+common.HashLength+len(headerTDSuffix)
+	case bytes.HasPrefix(key, blockBodyPrefix), bytes.HasPrefix(key, blockReceiptsPrefix):
+		return len(key) == len(blockBodyPrefix)+8+common.HashLength
+	case bytes.HasPrefix(key, headerNumberPrefix), bytes.HasPrefix(key, CodePrefix):
+		return len(key) == len(CodePrefix)+common.HashLength
+	}
+	return false
+}
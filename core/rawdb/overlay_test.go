@@ -0,0 +1,119 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Tests that an overlay database reads the blocks of the shared database, but
+// keeps its own canonical chain.
+func TestOverlayDatabase(t *testing.T) {
+	var (
+		shared = NewMemoryDatabase()
+		db     = NewOverlayDatabase(NewMemoryDatabase(), shared)
+		block  = types.NewBlockWithHeader(&types.Header{Number: big.NewInt(1), Extra: []byte("shared")})
+	)
+	WriteBlock(shared, block)
+	WriteTd(shared, block.Hash(), 1, big.NewInt(2))
+	WriteCanonicalHash(shared, block.Hash(), 1)
+	WriteHeadBlockHash(shared, block.Hash())
+	WriteCode(shared, common.Hash{0x01}, []byte{0x02})
+
+	if read := ReadBlock(db, block.Hash(), 1); read == nil || read.Hash() != block.Hash() {
+		t.Errorf("shared block not readable")
+	}
+	if !HasBody(db, block.Hash(), 1) {
+		t.Errorf("shared body not found")
+	}
+	if td := ReadTd(db, block.Hash(), 1); td == nil || td.Uint64() != 2 {
+		t.Errorf("shared total difficulty mismatch: have %v, want %d", td, 2)
+	}
+	if number := ReadHeaderNumber(db, block.Hash()); number == nil || *number != 1 {
+		t.Errorf("shared header number mismatch: have %v, want %d", number, 1)
+	}
+	if code := ReadCode(db, common.Hash{0x01}); len(code) != 1 {
+		t.Errorf("shared code not readable")
+	}
+	if hash := ReadCanonicalHash(db, 1); hash != (common.Hash{}) {
+		t.Errorf("canonical hash read from the shared database: %x", hash)
+	}
+	if hash := ReadHeadBlockHash(db); hash != (common.Hash{}) {
+		t.Errorf("head block hash read from the shared database: %x", hash)
+	}
+	// Writes and deletions don't reach the shared database
+	other := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(1), Extra: []byte("own")})
+	WriteBlock(db, other)
+	WriteCanonicalHash(db, other.Hash(), 1)
+	if HasHeader(shared, other.Hash(), 1) || ReadCanonicalHash(shared, 1) != block.Hash() {
+		t.Errorf("write leaked into the shared database")
+	}
+	DeleteBlock(db, block.Hash(), 1)
+	if !HasHeader(shared, block.Hash(), 1) {
+		t.Errorf("deletion leaked into the shared database")
+	}
+}
+
+// Tests that an overlay database reads the frozen blocks out of the ancient
+// store of the shared database and leaves it untouched.
+func TestOverlayDatabaseAncients(t *testing.T) {
+	dir, err := ioutil.TempDir("", "overlay")
+	if err != nil {
+		t.Fatalf("failed to create temp freezer dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	shared, err := NewDatabaseWithFreezer(NewMemoryDatabase(), dir, "", false)
+	if err != nil {
+		t.Fatalf("failed to create database with freezer: %v", err)
+	}
+	defer shared.Close()
+
+	var (
+		db     = NewOverlayDatabase(NewMemoryDatabase(), shared)
+		blocks = []*types.Block{
+			types.NewBlockWithHeader(&types.Header{Number: big.NewInt(0), Extra: []byte("frozen")}),
+		}
+	)
+	if _, err := WriteAncientBlocks(shared, blocks, []types.Receipts{nil}, big.NewInt(1)); err != nil {
+		t.Fatalf("failed to freeze block: %v", err)
+	}
+	if frozen, err := db.Ancients(); err != nil || frozen != 1 {
+		t.Errorf("ancient count mismatch: have %d (%v), want %d", frozen, err, 1)
+	}
+	if read := ReadBlock(db, blocks[0].Hash(), 0); read == nil || read.Hash() != blocks[0].Hash() {
+		t.Errorf("frozen block not readable")
+	}
+	if hash := ReadCanonicalHash(db, 0); hash != blocks[0].Hash() {
+		t.Errorf("frozen canonical hash mismatch: have %x, want %x", hash, blocks[0].Hash())
+	}
+	if _, err := WriteAncientBlocks(db, blocks, []types.Receipts{nil}, big.NewInt(1)); err == nil {
+		t.Errorf("ancient write accepted")
+	}
+	if err := db.TruncateAncients(0); err != nil {
+		t.Errorf("failed to truncate ancients: %v", err)
+	}
+	if frozen, _ := shared.Ancients(); frozen != 1 {
+		t.Errorf("truncation leaked into the shared ancient store")
+	}
+}
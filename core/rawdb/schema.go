@@ -78,6 +78,9 @@ var (
 	// transitionStatusKey tracks the eth2 transition status.
 	transitionStatusKey = []byte("eth2-transition")
 
+	// selfishMiningStateKey tracks the state of the mining strategy across restarts.
+	selfishMiningStateKey = []byte("SelfishMiningState")
+
 	// Data item prefixes (use single byte to avoid mixing data types, avoid `i`, used for indexes).
 	headerPrefix       = []byte("h") // headerPrefix + num (uint64 big endian) + hash -> header
 	headerTDSuffix     = []byte("t") // headerPrefix + num (uint64 big endian) + hash + headerTDSuffix -> td
@@ -93,6 +96,8 @@ var (
 	SnapshotStoragePrefix = []byte("o") // SnapshotStoragePrefix + account hash + storage hash -> storage trie value
 	CodePrefix            = []byte("c") // CodePrefix + code hash -> account code
 
+	blockProvenancePrefix = []byte("p") // blockProvenancePrefix + hash -> first sight of the block
+
 	PreimagePrefix = []byte("secure-key-")      // PreimagePrefix + hash -> preimage
 	configPrefix   = []byte("ethereum-config-") // config prefix for the db
 
@@ -229,6 +234,11 @@ func IsCodeKey(key []byte) (bool, []byte) {
 	return false, nil
 }
 
+// blockProvenanceKey = blockProvenancePrefix + hash
+func blockProvenanceKey(hash common.Hash) []byte {
+	return append(blockProvenancePrefix, hash.Bytes()...)
+}
+
 // configKey = configPrefix + hash
 func configKey(hash common.Hash) []byte {
 	return append(configPrefix, hash.Bytes()...)
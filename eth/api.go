@@ -36,6 +36,8 @@ import (
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/internal/ethapi"
 	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/miner/logic"
+	"github.com/ethereum/go-ethereum/p2p/enode"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/ethereum/go-ethereum/trie"
@@ -147,6 +149,187 @@ func (api *PrivateMinerAPI) SetRecommitInterval(interval int) {
 	api.e.Miner().SetRecommitInterval(time.Duration(interval) * time.Millisecond)
 }
 
+// SetStrategy switches the mining strategy of the running node. The blocks
+// withheld by a selfish strategy are published if publish is set, and discarded
+// otherwise. Either way the private chain adopts the public chain before the
+// new strategy takes over.
+func (api *PrivateMinerAPI) SetStrategy(name string, publish bool) error {
+	return api.e.SetStrategy(name, publish)
+}
+
+// PrivateSelfishAPI provides private RPC methods to inspect the state of the
+// mining strategy, including the withheld blocks of a selfish miner.
+type PrivateSelfishAPI struct {
+	e *Ethereum
+}
+
+// NewPrivateSelfishAPI creates a new RPC service which exposes the mining strategy state.
+func NewPrivateSelfishAPI(e *Ethereum) *PrivateSelfishAPI {
+	return &PrivateSelfishAPI{e: e}
+}
+
+// PrivateHead returns the head of the private chain.
+func (api *PrivateSelfishAPI) PrivateHead() *types.Header {
+	return api.e.miningData.PrivateChain.CurrentHeader()
+}
+
+// PublicHead returns the head of the public chain.
+func (api *PrivateSelfishAPI) PublicHead() *types.Header {
+	return api.e.miningData.PublicChain.CurrentHeader()
+}
+
+// PrivateBranchLength returns the number of blocks mined on the private branch.
+func (api *PrivateSelfishAPI) PrivateBranchLength() int {
+	return api.e.miningData.Status().PrivateBranchLength
+}
+
+// NextToPublish returns the number of the first unpublished private block.
+func (api *PrivateSelfishAPI) NextToPublish() int {
+	return api.e.miningData.Status().NextToPublish
+}
+
+// Status returns the view of the private and public chain the strategy bases
+// its decisions on.
+func (api *PrivateSelfishAPI) Status() logic.State {
+	return api.e.miningData.Status()
+}
+
+// UnpublishedBlocks returns the headers of the withheld private blocks.
+func (api *PrivateSelfishAPI) UnpublishedBlocks() []*types.Header {
+	blocks := api.e.miningData.UnpublishedBlocks()
+	headers := make([]*types.Header, len(blocks))
+	for i, block := range blocks {
+		headers[i] = block.Header()
+	}
+	return headers
+}
+
+// BranchesToImport returns the headers of the public branches the private chain
+// imports the next time it adopts the public chain.
+func (api *PrivateSelfishAPI) BranchesToImport() [][]*types.Header {
+	branches := api.e.miningData.BranchesToImport()
+	result := make([][]*types.Header, len(branches))
+	for i, branch := range branches {
+		result[i] = make([]*types.Header, len(branch))
+		for j, block := range branch {
+			result[i][j] = block.Header()
+		}
+	}
+	return result
+}
+
+// SideBranches returns the recent branches of the public chain that lost against
+// the branch of the head, with the origin and first-seen time of their blocks.
+func (api *PrivateSelfishAPI) SideBranches() []*logic.SideBranch {
+	return api.e.miningData.SideBranches()
+}
+
+// Strategy returns the name of the mining strategy.
+func (api *PrivateSelfishAPI) Strategy() string {
+	return api.e.miningData.Strategy().Name()
+}
+
+// Revenue returns the revenue of the miners over the canonical blocks from..to
+// of the public chain, split into windows of the given number of blocks if
+// non-zero. The block range defaults to the whole chain, and the coinbase of
+// this node is reported as the attacker.
+func (api *PrivateSelfishAPI) Revenue(from uint64, to *uint64, window *uint64) (*logic.RevenueReport, error) {
+	end := api.e.blockchain.CurrentBlock().NumberU64()
+	if to != nil {
+		end = *to
+	}
+	var size uint64
+	if window != nil {
+		size = *window
+	}
+	return logic.AccountRevenue(api.e.blockchain, api.e.ChainDb(), from, end, size, api.e.miningData.Coinbase, api.firstSeen)
+}
+
+// Detect looks for signals of selfish mining in the blocks from..to of the
+// public chain and its side branches, with the default thresholds. The block
+// range defaults to the whole chain. Withheld and bursty releases are detected
+// for the blocks whose arrival this node has witnessed.
+func (api *PrivateSelfishAPI) Detect(from uint64, to *uint64) (*logic.DetectionReport, error) {
+	end := api.e.blockchain.CurrentBlock().NumberU64()
+	if to != nil {
+		end = *to
+	}
+	return logic.Detect(api.e.blockchain, api.e.ChainDb(), from, end, api.firstSeen, logic.DefaultDetectConfig)
+}
+
+// firstSeen returns when this node first saw a block. Blocks of peers were
+// recorded as they arrived, the recent blocks of this node as they were added
+// to the fork tree.
+func (api *PrivateSelfishAPI) firstSeen(hash common.Hash) (time.Time, bool) {
+	if seen, ok := api.e.blockchain.GetBlockFirstSeen(hash); ok {
+		return seen, true
+	}
+	return api.e.miningData.FirstSeen(hash)
+}
+
+// BlockProvenance is the first sight of a block by the node, as returned by
+// selfish_blockProvenance.
+type BlockProvenance struct {
+	Number      uint64     `json:"number"`
+	AnnouncedAt *time.Time `json:"announcedAt"`           // first announcement of the hash, null if never announced
+	AnnouncedBy string     `json:"announcedBy,omitempty"` // peer of the first announcement
+	ReceivedAt  *time.Time `json:"receivedAt"`            // first delivery of the full block, null if not delivered yet
+	ReceivedBy  string     `json:"receivedBy,omitempty"`  // peer of the first delivery
+	Source      string     `json:"source"`                // fetcher or downloader
+}
+
+// BlockProvenance returns when this node first heard of a block and from which
+// peers, or null if no peer ever announced or delivered it.
+func (api *PrivateSelfishAPI) BlockProvenance(hash common.Hash) *BlockProvenance {
+	provenance := api.e.blockchain.GetBlockProvenance(hash)
+	if provenance == nil {
+		return nil
+	}
+	result := &BlockProvenance{
+		Number:      provenance.Number,
+		AnnouncedBy: provenance.AnnouncedBy,
+		ReceivedBy:  provenance.ReceivedBy,
+		Source:      provenance.Source.String(),
+	}
+	if provenance.AnnouncedAt != 0 {
+		announced := time.Unix(0, int64(provenance.AnnouncedAt))
+		result.AnnouncedAt = &announced
+	}
+	if provenance.ReceivedAt != 0 {
+		received := time.Unix(0, int64(provenance.ReceivedAt))
+		result.ReceivedAt = &received
+	}
+	return result
+}
+
+// Decisions creates a subscription that is triggered for every publish decision
+// (adopt, match, override, publish-one) of the mining strategy.
+func (api *PrivateSelfishAPI) Decisions(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		decisions := make(chan logic.Decision, 16)
+		decisionsSub := api.e.miningData.SubscribeDecisions(decisions)
+		defer decisionsSub.Unsubscribe()
+
+		for {
+			select {
+			case decision := <-decisions:
+				notifier.Notify(rpcSub.ID, decision)
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+	return rpcSub, nil
+}
+
 // PrivateAdminAPI is the collection of Ethereum full node-related APIs
 // exposed over the private admin endpoint.
 type PrivateAdminAPI struct {
@@ -159,6 +342,82 @@ func NewPrivateAdminAPI(eth *Ethereum) *PrivateAdminAPI {
 	return &PrivateAdminAPI{eth: eth}
 }
 
+// AddEclipsePeer eclipses a peer, given by its enode URL or node ID, with the
+// default eclipse policy of the miner. It returns false if the peer is already
+// eclipsed, leaving its policy unchanged. The peer is matched by its node ID,
+// whichever address it connects from.
+func (api *PrivateAdminAPI) AddEclipsePeer(node string) (bool, error) {
+	id, err := parseNodeID(node)
+	if err != nil {
+		return false, err
+	}
+	return api.eth.miningData.EclipsePeers.Add(id, api.eth.miningData.Eclipse, api.eth.blockchain.CurrentBlock().NumberU64()), nil
+}
+
+// RemoveEclipsePeer ends the eclipse of a peer, given by its enode URL or node
+// ID, returning whether it was eclipsed. Blocks and transactions already delayed
+// to the peer are still sent once their delay expires.
+func (api *PrivateAdminAPI) RemoveEclipsePeer(node string) (bool, error) {
+	id, err := parseNodeID(node)
+	if err != nil {
+		return false, err
+	}
+	return api.eth.miningData.EclipsePeers.Remove(id), nil
+}
+
+// SetEclipsePolicy eclipses a peer, given by its enode URL or node ID, with the
+// given policy, or changes the policy of a peer that is already eclipsed.
+func (api *PrivateAdminAPI) SetEclipsePolicy(node string, policy logic.EclipsePolicy) error {
+	id, err := parseNodeID(node)
+	if err != nil {
+		return err
+	}
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+	api.eth.miningData.EclipsePeers.Set(id, policy, api.eth.blockchain.CurrentBlock().NumberU64())
+	return nil
+}
+
+// EclipsedPeer is an eclipsed peer and its treatment.
+type EclipsedPeer struct {
+	ID     enode.ID            `json:"id"`
+	Enode  string              `json:"enode,omitempty"` // Enode URL of the peer if connected
+	Since  uint64              `json:"since"`           // Head number when the peer was eclipsed
+	Policy logic.EclipsePolicy `json:"policy"`
+}
+
+// EclipsePeers returns the eclipsed peers and their treatment.
+func (api *PrivateAdminAPI) EclipsePeers() []EclipsedPeer {
+	set := api.eth.miningData.EclipsePeers
+
+	peers := make([]EclipsedPeer, 0)
+	for _, id := range set.IDs() {
+		policy, since, ok := set.Get(id)
+		if !ok {
+			continue // Removed meanwhile
+		}
+		peer := EclipsedPeer{ID: id, Since: since, Policy: policy}
+		if p := api.eth.handler.peers.peer(id.String()); p != nil {
+			peer.Enode = p.Node().URLv4()
+		}
+		peers = append(peers, peer)
+	}
+	return peers
+}
+
+// parseNodeID returns the ID of a node given by its enode URL or node ID.
+func parseNodeID(node string) (enode.ID, error) {
+	if n, err := enode.Parse(enode.ValidSchemes, node); err == nil {
+		return n.ID(), nil
+	}
+	id, err := enode.ParseID(node)
+	if err != nil {
+		return enode.ID{}, fmt.Errorf("invalid enode URL or node ID %q", node)
+	}
+	return id, nil
+}
+
 // ExportChain exports the current blockchain into a local file,
 // or a range of blocks if first and last are non-nil
 func (api *PrivateAdminAPI) ExportChain(file string, first *uint64, last *uint64) (bool, error) {
@@ -18,10 +18,13 @@
 package eth
 
 import (
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"github.com/ethereum/go-ethereum/miner/logic"
+	"io"
 	"math/big"
+	"path/filepath"
 	"runtime"
 	"sync"
 	"sync/atomic"
@@ -39,11 +42,13 @@ import (
 	"github.com/ethereum/go-ethereum/core/state/pruner"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/eth/downloader"
 	"github.com/ethereum/go-ethereum/eth/ethconfig"
 	"github.com/ethereum/go-ethereum/eth/filters"
 	"github.com/ethereum/go-ethereum/eth/gasprice"
 	"github.com/ethereum/go-ethereum/eth/protocols/eth"
+	"github.com/ethereum/go-ethereum/eth/protocols/pool"
 	"github.com/ethereum/go-ethereum/eth/protocols/snap"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
@@ -78,9 +83,13 @@ type Ethereum struct {
 	// DB interfaces
 	chainDb ethdb.Database // Block chain database
 
-	eventMux     *event.TypeMux
-	engine       consensus.Engine
-	miningEngine consensus.Engine // engine used for mining
+	eventMux      *event.TypeMux
+	engine        consensus.Engine
+	miningEngine  consensus.Engine  // engine used for mining, protected by lock as it changes with the strategy
+	privateEngine consensus.Engine  // engine of the private chain
+	switchLock    sync.Mutex        // Serializes strategy switches and mining thread changes
+	miningData    *logic.MiningData // state of the mining strategy
+	eventLog      io.Closer         // file of the mining event log, nil if not logging
 
 	accountManager *accounts.Manager
 
@@ -88,6 +97,9 @@ type Ethereum struct {
 	bloomIndexer      *core.ChainIndexer             // Bloom indexer operating during block imports
 	closeBloomHandler chan struct{}
 
+	closeIntermittent chan struct{}  // Channel to stop the intermittent mining phases
+	intermittentWg    sync.WaitGroup // Wait group of the intermittent mining phases
+
 	APIBackend *EthAPIBackend
 
 	miner     *miner.Miner
@@ -130,6 +142,11 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 	// Transfer mining-related config to the ethash config.
 	ethashConfig := config.Ethash
 	ethashConfig.NotifyFull = config.Miner.NotifyFull
+	privateEthashConfig := ethashConfig
+	if config.ExperimentSeed != 0 {
+		ethashConfig.Seed = deriveSeed(config.ExperimentSeed, "ethash")
+		privateEthashConfig.Seed = deriveSeed(config.ExperimentSeed, "privateethash")
+	}
 
 	// Assemble the Ethereum object
 	chainDb, err := stack.OpenDatabaseWithFreezer("chaindata", config.DatabaseCache, config.DatabaseHandles, config.DatabaseFreezer, "eth/db/chaindata/", false)
@@ -148,12 +165,19 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 	merger := consensus.NewMerger(chainDb)
 
 	// private chain config
-	privateChainDb, err := stack.OpenDatabaseWithFreezer("privatechaindata", config.DatabaseCache, config.DatabaseHandles, config.DatabaseFreezer, "eth/db/privatechaindata/", false)
+	//
+	// The private chain used to keep its own ancient store, which it can't read
+	// anymore now that it reads the frozen blocks of the public chain. Its frozen
+	// blocks would be missing, so refuse to start until the old database is gone.
+	if path := stack.ResolvePath("privatechaindata"); path != "" && common.FileExist(filepath.Join(path, "ancient")) {
+		return nil, fmt.Errorf("private chain database %s has an ancient store of its own, which is no longer supported: remove the private chain database with 'geth removedb'", path)
+	}
+	privateChainDb, err := stack.OpenDatabase("privatechaindata", config.DatabaseCache, config.DatabaseHandles, "eth/db/privatechaindata/", false)
 	if err != nil {
 		return nil, err
 	}
 	privateChainConfig, _, _ := core.SetupGenesisBlockWithOverride(privateChainDb, config.Genesis, config.OverrideArrowGlacier, config.OverrideTerminalTotalDifficulty)
-	privateEngine := ethconfig.CreateConsensusEngine(stack, privateChainConfig, &ethashConfig, config.Miner.Notify, config.Miner.Noverify, privateChainDb)
+	privateEngine := ethconfig.CreateConsensusEngine(stack, privateChainConfig, &privateEthashConfig, config.Miner.Notify, config.Miner.Noverify, privateChainDb)
 
 	eth := &Ethereum{
 		config:            config,
@@ -162,7 +186,9 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 		eventMux:          stack.EventMux(),
 		accountManager:    stack.AccountManager(),
 		engine:            ethconfig.CreateConsensusEngine(stack, chainConfig, &ethashConfig, config.Miner.Notify, config.Miner.Noverify, chainDb),
+		privateEngine:     privateEngine,
 		closeBloomHandler: make(chan struct{}),
+		closeIntermittent: make(chan struct{}),
 		networkID:         config.NetworkId,
 		gasPrice:          config.Miner.GasPrice,
 		etherbase:         config.Miner.Etherbase,
@@ -171,7 +197,20 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 		p2pServer:         stack.Server(),
 	}
 
-	if config.Miner.MinerStrategy.IsHonest() {
+	strategy, err := logic.New(config.Miner.MinerStrategy, &config.Miner.StrategyConfig)
+	if err != nil {
+		return nil, err
+	}
+	if err := config.Miner.Intermittent.Validate(); err != nil {
+		return nil, err
+	}
+	if config.Miner.Intermittent.Enabled() && strategy.IsHonest() {
+		return nil, errors.New("intermittent mining requires a selfish strategy")
+	}
+	if err := config.Miner.Comparison.Validate(); err != nil {
+		return nil, err
+	}
+	if strategy.IsHonest() {
 		eth.miningEngine = eth.engine
 	} else {
 		eth.miningEngine = privateEngine
@@ -221,22 +260,68 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 		rawdb.WriteChainConfig(chainDb, genesisHash, chainConfig)
 	}
 
-	privateChain, err := core.NewBlockChain(privateChainDb, cacheConfig, privateChainConfig, privateEngine, vmConfig, eth.shouldPreserve, &config.TxLookupLimit)
+	// The head of the private chain jumps to the public head when adopting, which
+	// the snapshot of the private chain can't follow
+	privateCacheConfig := *cacheConfig
+	privateCacheConfig.SnapshotLimit = 0
+
+	// The private chain reads the public blocks and state from the public chain,
+	// so that adopting the public chain doesn't store and execute them again. It
+	// has no freezer of its own, the frozen blocks are the public ones.
+	privateChainDb = rawdb.NewOverlayDatabase(privateChainDb, eth.blockchain.SharedReader())
+	privateChain, err := core.NewBlockChain(privateChainDb, &privateCacheConfig, privateChainConfig, privateEngine, vmConfig, eth.shouldPreserve, &config.TxLookupLimit)
+	if err != nil {
+		return nil, err
+	}
+	if config.ExperimentSeed != 0 {
+		eth.blockchain.SetForkChoiceSeed(deriveSeed(config.ExperimentSeed, "forkchoice"))
+		privateChain.SetForkChoiceSeed(deriveSeed(config.ExperimentSeed, "privateforkchoice"))
+	}
+	// Only the public chain defends itself, the private chain is ours anyway
+	if err := eth.blockchain.SetForkChoiceRule(config.ForkChoice); err != nil {
+		return nil, err
+	}
 	privateBranchLength := 0
 	privateBranchLengthPointer := &privateBranchLength
 
 	nextToPublish := 1
 	nextToPublishPointer := &nextToPublish
 
-	miningData := &logic.MiningData{
-		PublicChain:                          eth.blockchain,
-		PrivateChain:                         privateChain,
-		PrivateBranchLength:                  privateBranchLengthPointer,
-		NextToPublish:                        nextToPublishPointer,
-		MinerStrategy:                        config.Miner.MinerStrategy,
-		EclipsePeers:                         config.Miner.EclipsePeers,
-		PublicChainBranchesToImportContainer: core.NewBranchesContainer(),
+	var eventLog log.Logger
+	if config.Miner.LogFile != "" {
+		if eventLog, eth.eventLog, err = logic.NewEventLog(config.Miner.LogFile, config.Miner.LogFileSize*1024*1024); err != nil {
+			return nil, err
+		}
+	}
+	forks, err := eth.blockchain.NewForkTree(eth.blockchain.CurrentBlock().NumberU64())
+	if err != nil {
+		return nil, err
 	}
+	eclipsePeers := logic.NewEclipseSet()
+	for _, url := range config.Miner.EclipsePeers {
+		node, err := enode.Parse(enode.ValidSchemes, url)
+		if err != nil {
+			return nil, fmt.Errorf("invalid eclipsed peer %q: %v", url, err)
+		}
+		eclipsePeers.Add(node.ID(), config.Miner.Eclipse, eth.blockchain.CurrentBlock().NumberU64())
+	}
+	miningData := &logic.MiningData{
+		PublicChain:         eth.blockchain,
+		PrivateChain:        privateChain,
+		PrivateBranchLength: privateBranchLengthPointer,
+		NextToPublish:       nextToPublishPointer,
+		MinerStrategy:       strategy,
+		EclipsePeers:        eclipsePeers,
+		Eclipse:             config.Miner.Eclipse,
+		Race:                config.Miner.Race,
+		Comparison:          config.Miner.Comparison,
+		Pool:                len(config.Miner.Pool) > 0,
+		EventLog:            eventLog,
+		PrivateChainDb:      privateChainDb,
+		ForkTree:            forks,
+	}
+	miningData.Restore()
+	eth.miningData = miningData
 
 	eth.bloomIndexer.Start(eth.blockchain)
 
@@ -264,6 +349,8 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 		EventMux:   eth.eventMux,
 		Checkpoint: checkpoint,
 		Whitelist:  config.Whitelist,
+		Seed:       deriveSeed(config.ExperimentSeed, "handler"),
+		Pool:       config.Miner.Pool,
 	}); err != nil {
 		return nil, err
 	}
@@ -340,6 +427,22 @@ func makeExtraData(extra []byte) []byte {
 	return extra
 }
 
+// deriveSeed derives the seed of one of the random sources of the node from the
+// experiment seed, so that the sources do not draw the same sequences. A zero
+// experiment seed yields zero, leaving the source randomly seeded.
+func deriveSeed(seed int64, component string) int64 {
+	if seed == 0 {
+		return 0
+	}
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], uint64(seed))
+	derived := int64(binary.BigEndian.Uint64(crypto.Keccak256(buf[:], []byte(component))))
+	if derived == 0 {
+		derived = 1
+	}
+	return derived
+}
+
 // APIs return the collection of RPC services the ethereum package offers.
 // NOTE, some of these services probably need to be moved to somewhere else.
 func (s *Ethereum) APIs() []rpc.API {
@@ -370,6 +473,11 @@ func (s *Ethereum) APIs() []rpc.API {
 			Version:   "1.0",
 			Service:   NewPrivateMinerAPI(s),
 			Public:    false,
+		}, {
+			Namespace: "selfish",
+			Version:   "1.0",
+			Service:   NewPrivateSelfishAPI(s),
+			Public:    false,
 		}, {
 			Namespace: "eth",
 			Version:   "1.0",
@@ -496,13 +604,19 @@ func (s *Ethereum) StartMining(threads int) error {
 		SetThreads(threads int)
 	}
 
-	if th, ok := s.miningEngine.(threaded); ok {
+	s.switchLock.Lock()
+	s.lock.RLock()
+	engine := s.miningEngine
+	s.lock.RUnlock()
+
+	if th, ok := engine.(threaded); ok {
 		log.Info("Updated mining threads", "threads", threads)
 		if threads == 0 {
 			threads = -1 // Disable the miner from within
 		}
 		th.SetThreads(threads)
 	}
+	s.switchLock.Unlock()
 	// If the miner was not running, initialize it
 	if !s.IsMining() {
 		// Propagate the initial price point to the transaction pool
@@ -518,9 +632,9 @@ func (s *Ethereum) StartMining(threads int) error {
 			return fmt.Errorf("etherbase missing: %v", err)
 		}
 		var cli *clique.Clique
-		if c, ok := s.miningEngine.(*clique.Clique); ok {
+		if c, ok := engine.(*clique.Clique); ok {
 			cli = c
-		} else if cl, ok := s.miningEngine.(*beacon.Beacon); ok {
+		} else if cl, ok := engine.(*beacon.Beacon); ok {
 			if c, ok := cl.InnerEngine().(*clique.Clique); ok {
 				cli = c
 			}
@@ -550,16 +664,59 @@ func (s *Ethereum) StopMining() {
 		SetThreads(threads int)
 	}
 
-	if th, ok := s.miningEngine.(threaded); ok {
+	s.switchLock.Lock()
+	s.lock.RLock()
+	engine := s.miningEngine
+	s.lock.RUnlock()
+
+	if th, ok := engine.(threaded); ok {
 		th.SetThreads(-1)
 	}
+	s.switchLock.Unlock()
 	// Stop the block creating itself
 	s.miner.Stop()
 }
 
+// SetStrategy switches the mining strategy of the running node. The blocks
+// withheld by a selfish strategy are published if publish is set, and discarded
+// otherwise. The mining threads move over to the engine of the chain the new
+// strategy mines on.
+func (s *Ethereum) SetStrategy(name string, publish bool) error {
+	s.switchLock.Lock()
+	defer s.switchLock.Unlock()
+
+	strategy, err := logic.New(name, &s.config.Miner.StrategyConfig)
+	if err != nil {
+		return err
+	}
+	engine := s.engine
+	if !strategy.IsHonest() {
+		engine = s.config.Miner.PrivateChainEngine
+	}
+	s.lock.Lock()
+	previous := s.miningEngine
+	s.miningEngine = engine
+	s.lock.Unlock()
+
+	type threaded interface {
+		Threads() int
+		SetThreads(threads int)
+	}
+	if from, ok := previous.(threaded); ok && previous != engine {
+		if to, ok := engine.(threaded); ok {
+			to.SetThreads(from.Threads())
+			from.SetThreads(-1)
+		}
+	}
+	s.miner.SetStrategy(strategy, publish)
+	return nil
+}
+
 func (s *Ethereum) IsMining() bool      { return s.miner.Mining() }
 func (s *Ethereum) Miner() *miner.Miner { return s.miner }
 
+func (s *Ethereum) MiningData() *logic.MiningData { return s.miningData }
+
 func (s *Ethereum) AccountManager() *accounts.Manager  { return s.accountManager }
 func (s *Ethereum) BlockChain() *core.BlockChain       { return s.blockchain }
 func (s *Ethereum) TxPool() *core.TxPool               { return s.txPool }
@@ -585,6 +742,9 @@ func (s *Ethereum) Protocols() []p2p.Protocol {
 	if s.config.SnapshotCache > 0 {
 		protos = append(protos, snap.MakeProtocols((*snapHandler)(s.handler), s.snapDialCandidates)...)
 	}
+	if len(s.config.Miner.Pool) > 0 {
+		protos = append(protos, pool.MakeProtocols((*poolHandler)(s.handler))...)
+	}
 	return protos
 }
 
@@ -606,6 +766,12 @@ func (s *Ethereum) Start() error {
 	}
 	// Start the networking layer and the light server if requested
 	s.handler.Start(maxPeers)
+
+	// Alternate between selfish and honest mining if requested
+	if s.config.Miner.Intermittent.Enabled() {
+		s.intermittentWg.Add(1)
+		go s.intermittentLoop()
+	}
 	return nil
 }
 
@@ -618,12 +784,21 @@ func (s *Ethereum) Stop() error {
 	s.handler.Stop()
 
 	// Then stop everything else.
+	close(s.closeIntermittent)
+	s.intermittentWg.Wait()
 	s.bloomIndexer.Close()
 	close(s.closeBloomHandler)
 	s.txPool.Stop()
 	s.miner.Close()
+	// The private chain writes the state of its head on stop and reads the
+	// public blocks and state through the public chain, so it stops first
+	s.miningData.PrivateChain.Stop()
+	s.privateEngine.Close()
 	s.blockchain.Stop()
 	s.engine.Close()
+	if s.eventLog != nil {
+		s.eventLog.Close()
+	}
 	rawdb.PopUncleanShutdownMarker(s.chainDb)
 	s.chainDb.Close()
 	s.eventMux.Stop()
@@ -0,0 +1,52 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/eth/ethconfig"
+	"github.com/ethereum/go-ethereum/node"
+)
+
+// Tests that a node refuses to start on a private chain database that has an
+// ancient store of its own, which the private chain can't read anymore.
+func TestPrivateAncientStoreRefused(t *testing.T) {
+	datadir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("failed to create temporary datadir: %v", err)
+	}
+	defer os.RemoveAll(datadir)
+
+	stack, err := node.New(&node.Config{DataDir: datadir})
+	if err != nil {
+		t.Fatalf("failed to create node: %v", err)
+	}
+	defer stack.Close()
+
+	if err := os.MkdirAll(filepath.Join(stack.ResolvePath("privatechaindata"), "ancient"), 0755); err != nil {
+		t.Fatalf("failed to create private ancient store: %v", err)
+	}
+	config := ethconfig.Defaults
+	if _, err := New(stack, &config); err == nil || !strings.Contains(err.Error(), "ancient store") {
+		t.Fatalf("node started on private ancient store: %v", err)
+	}
+}
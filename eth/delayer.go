@@ -0,0 +1,73 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"sync"
+	"time"
+)
+
+// delayer runs the delayed sends of the handler: the racing blocks withheld
+// from part of the peers, and the blocks and transactions delayed to eclipsed
+// peers. Pending sends are dropped when the delayer is stopped.
+type delayer struct {
+	quit   chan struct{}
+	closed bool
+	lock   sync.Mutex // Protects closed against concurrent scheduling and stopping
+	wg     sync.WaitGroup
+}
+
+// newDelayer creates a delayer ready to schedule sends.
+func newDelayer() *delayer {
+	return &delayer{quit: make(chan struct{})}
+}
+
+// after runs the send after the given delay, unless the delayer is stopped
+// first.
+func (d *delayer) after(delay time.Duration, send func()) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if d.closed {
+		return
+	}
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+			send()
+		case <-d.quit:
+		}
+	}()
+}
+
+// stop drops the pending sends and waits for the running ones to finish.
+func (d *delayer) stop() {
+	d.lock.Lock()
+	if !d.closed {
+		d.closed = true
+		close(d.quit)
+	}
+	d.lock.Unlock()
+
+	d.wg.Wait()
+}
@@ -0,0 +1,61 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Tests that delayed sends run after their delay, and that stopping the delayer
+// drops the pending ones and any scheduled afterwards.
+func TestDelayer(t *testing.T) {
+	var (
+		d    = newDelayer()
+		sent int32
+		done = make(chan struct{})
+	)
+	d.after(time.Millisecond, func() {
+		atomic.AddInt32(&sent, 1)
+		close(done)
+	})
+	d.after(time.Hour, func() { atomic.AddInt32(&sent, 1) })
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("delayed send not run")
+	}
+	stopped := make(chan struct{})
+	go func() {
+		d.stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatalf("stop waited for the pending send")
+	}
+	d.after(0, func() { atomic.AddInt32(&sent, 1) })
+	d.stop()
+
+	time.Sleep(10 * time.Millisecond)
+	if n := atomic.LoadInt32(&sent); n != 1 {
+		t.Errorf("send count mismatch: have %d, want 1", n)
+	}
+}
@@ -22,7 +22,6 @@ import (
 	"fmt"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/miner/logic"
-	log2 "log"
 	"math/big"
 	"sync"
 	"sync/atomic"
@@ -1367,7 +1366,6 @@ func (d *Downloader) processFullSyncContent() error {
 }
 
 func (d *Downloader) importBlockResults(results []*fetchResult) error {
-	log2.Printf("importBlockResults, size: %d", len(results))
 	// Check for any early termination requests
 	if len(results) == 0 {
 		return nil
@@ -1388,7 +1386,12 @@ func (d *Downloader) importBlockResults(results []*fetchResult) error {
 		blocks[i] = types.NewBlockWithHeader(result.Header).WithBody(result.Transactions, result.Uncles)
 	}
 
-	index, err := logic.OnOthersFoundBlocks(blocks, d.miningData)
+	d.cancelLock.RLock()
+	peer := d.cancelPeer
+	d.cancelLock.RUnlock()
+
+	d.blockchain.RecordBlockDeliveries(blocks, peer, rawdb.BlockSourceDownloader)
+	index, err := logic.OnOthersFoundBlocks(blocks, peer, d.miningData)
 
 	if err != nil {
 		if index < len(results) {
@@ -0,0 +1,76 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/miner/logic"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+// maxReleasedBlocks is the number of blocks published by this node that are
+// remembered to be served to the eclipsed peers filtering the chain.
+const maxReleasedBlocks = 16384
+
+// eclipse applies the treatment of the eclipsed peers, which are shared with the
+// mining logic and may change at runtime, and keeps track of the blocks this
+// node published and thereby released to them.
+type eclipse struct {
+	peers    *logic.EclipseSet
+	released *lru.Cache // Hashes of the blocks published by this node
+}
+
+// newEclipse creates the eclipse treatment of the given set of peers.
+func newEclipse(peers *logic.EclipseSet) *eclipse {
+	released, _ := lru.New(maxReleasedBlocks)
+	return &eclipse{
+		peers:    peers,
+		released: released,
+	}
+}
+
+// policy returns the policy of a peer, and false if the peer isn't eclipsed.
+func (e *eclipse) policy(id enode.ID) (logic.EclipsePolicy, bool) {
+	policy, _, ok := e.peers.Get(id)
+	return policy, ok
+}
+
+// release marks a block published by this node, which the eclipsed peers may
+// learn about.
+func (e *eclipse) release(hash common.Hash) {
+	e.released.Add(hash, struct{}{})
+}
+
+// filters reports whether the chain data served to a peer is filtered.
+func (e *eclipse) filters(id enode.ID) bool {
+	policy, _, ok := e.peers.Get(id)
+	return ok && policy.FilterChain
+}
+
+// serves reports whether the header, body and receipts of a block may be served
+// to a peer. Peers filtering the chain are only served the blocks they know,
+// the blocks published by this node and the chain up to the head at the time
+// they were eclipsed. The number of the block is only looked up if needed.
+func (e *eclipse) serves(id enode.ID, hash common.Hash, known bool, number func() (uint64, bool)) bool {
+	policy, since, ok := e.peers.Get(id)
+	if !ok || !policy.FilterChain || known || e.released.Contains(hash) {
+		return true
+	}
+	n, found := number()
+	return found && n <= since
+}
@@ -36,6 +36,7 @@ import (
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/miner"
+	"github.com/ethereum/go-ethereum/miner/logic"
 	"github.com/ethereum/go-ethereum/node"
 	"github.com/ethereum/go-ethereum/params"
 )
@@ -87,6 +88,12 @@ var Defaults = Config{
 		GasCeil:  8000000,
 		GasPrice: big.NewInt(params.GWei),
 		Recommit: 3 * time.Second,
+
+		MinerStrategy: logic.HONEST,
+		StrategyConfig: logic.Config{
+			TrailDepth: 1,
+		},
+		LogFileSize: 100,
 	},
 	TxPool:        core.DefaultTxPoolConfig,
 	RPCGasCap:     50000000,
@@ -208,6 +215,14 @@ type Config struct {
 
 	// OverrideTerminalTotalDifficulty (TODO: remove after the fork)
 	OverrideTerminalTotalDifficulty *big.Int `toml:",omitempty"`
+
+	// Seed of the random choices of the node, for reproducible experiment runs.
+	// Zero seeds them randomly.
+	ExperimentSeed int64 `toml:",omitempty"`
+
+	// Fork choice rule of the public chain, to run defenses against selfish
+	// mining.
+	ForkChoice core.ForkChoiceConfig
 }
 
 // CreateConsensusEngine creates a consensus engine for the given chain configuration.
@@ -224,6 +239,8 @@ func CreateConsensusEngine(stack *node.Node, chainConfig *params.ChainConfig, co
 			log.Warn("Ethash used in test mode")
 		case ethash.ModeShared:
 			log.Warn("Ethash used in shared mode")
+		case ethash.ModePoisson:
+			log.Warn("Ethash used in emulated hashrate mode", "hashrate", config.Hashrate, "interval", config.BlockInterval, "scaled", config.ScaleDifficulty)
 		}
 		engine = ethash.New(ethash.Config{
 			PowMode:          config.PowMode,
@@ -236,6 +253,10 @@ func CreateConsensusEngine(stack *node.Node, chainConfig *params.ChainConfig, co
 			DatasetsOnDisk:   config.DatasetsOnDisk,
 			DatasetsLockMmap: config.DatasetsLockMmap,
 			NotifyFull:       config.NotifyFull,
+			Hashrate:         config.Hashrate,
+			BlockInterval:    config.BlockInterval,
+			ScaleDifficulty:  config.ScaleDifficulty,
+			Seed:             config.Seed,
 		}, notify, noverify)
 		engine.(*ethash.Ethash).SetThreads(-1) // Disable CPU mining
 	}
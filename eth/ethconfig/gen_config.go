@@ -61,6 +61,8 @@ func (c Config) MarshalTOML() (interface{}, error) {
 		CheckpointOracle                *params.CheckpointOracleConfig `toml:",omitempty"`
 		OverrideArrowGlacier            *big.Int                       `toml:",omitempty"`
 		OverrideTerminalTotalDifficulty *big.Int                       `toml:",omitempty"`
+		ExperimentSeed                  int64                          `toml:",omitempty"`
+		ForkChoice                      core.ForkChoiceConfig
 	}
 	var enc Config
 	enc.Genesis = c.Genesis
@@ -106,6 +108,8 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.CheckpointOracle = c.CheckpointOracle
 	enc.OverrideArrowGlacier = c.OverrideArrowGlacier
 	enc.OverrideTerminalTotalDifficulty = c.OverrideTerminalTotalDifficulty
+	enc.ExperimentSeed = c.ExperimentSeed
+	enc.ForkChoice = c.ForkChoice
 	return &enc, nil
 }
 
@@ -155,6 +159,8 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 		CheckpointOracle                *params.CheckpointOracleConfig `toml:",omitempty"`
 		OverrideArrowGlacier            *big.Int                       `toml:",omitempty"`
 		OverrideTerminalTotalDifficulty *big.Int                       `toml:",omitempty"`
+		ExperimentSeed                  *int64                         `toml:",omitempty"`
+		ForkChoice                      *core.ForkChoiceConfig
 	}
 	var dec Config
 	if err := unmarshal(&dec); err != nil {
@@ -289,5 +295,11 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.OverrideTerminalTotalDifficulty != nil {
 		c.OverrideTerminalTotalDifficulty = dec.OverrideTerminalTotalDifficulty
 	}
+	if dec.ExperimentSeed != nil {
+		c.ExperimentSeed = *dec.ExperimentSeed
+	}
+	if dec.ForkChoice != nil {
+		c.ForkChoice = *dec.ForkChoice
+	}
 	return nil
 }
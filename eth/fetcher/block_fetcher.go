@@ -92,8 +92,9 @@ type chainHeightFn func() uint64
 // headersInsertFn is a callback type to insert a batch of headers into the local chain.
 type headersInsertFn func(headers []*types.Header) (int, error)
 
-// chainInsertFn is a callback type to insert a batch of blocks into the local chain.
-type chainInsertFn func(types.Blocks) (int, error)
+// chainInsertFn is a callback type to insert a batch of blocks received from a
+// peer into the local chain.
+type chainInsertFn func(peer string, blocks types.Blocks) (int, error)
 
 // peerDropFn is a callback type for dropping a peer detected as malicious.
 type peerDropFn func(id string)
@@ -179,6 +180,8 @@ type BlockFetcher struct {
 	queues map[string]int                       // Per peer block counts to prevent memory exhaustion
 	queued map[common.Hash]*blockOrHeaderInject // Set of already queued blocks (to dedup imports)
 
+	rand *rand.Rand // Source of the random choice among the peers announcing a block
+
 	// Callbacks
 	getHeader      HeaderRetrievalFn  // Retrieves a header from the local chain
 	getBlock       blockRetrievalFn   // Retrieves a block from the local chain
@@ -215,6 +218,7 @@ func NewBlockFetcher(light bool, getHeader HeaderRetrievalFn, getBlock blockRetr
 		queue:          prque.New(nil),
 		queues:         make(map[string]int),
 		queued:         make(map[common.Hash]*blockOrHeaderInject),
+		rand:           rand.New(rand.NewSource(time.Now().UnixNano())),
 		getHeader:      getHeader,
 		getBlock:       getBlock,
 		verifyHeader:   verifyHeader,
@@ -226,6 +230,12 @@ func NewBlockFetcher(light bool, getHeader HeaderRetrievalFn, getBlock blockRetr
 	}
 }
 
+// SetSeed seeds the random choice among the peers announcing a block, so that
+// experiment runs can be reproduced. It must be called before Start.
+func (f *BlockFetcher) SetSeed(seed int64) {
+	f.rand = rand.New(rand.NewSource(seed))
+}
+
 // Start boots up the announcement based synchroniser, accepting and processing
 // hash notifications and block fetches until termination requested.
 func (f *BlockFetcher) Start() {
@@ -446,7 +456,7 @@ func (f *BlockFetcher) loop() {
 				}
 				if time.Since(announces[0].time) > timeout {
 					// Pick a random peer to retrieve from, reset all others
-					announce := announces[rand.Intn(len(announces))]
+					announce := announces[f.rand.Intn(len(announces))]
 					f.forgetHash(hash)
 
 					// If the block still didn't arrive, queue for fetching
@@ -494,7 +504,7 @@ func (f *BlockFetcher) loop() {
 
 			for hash, announces := range f.fetched {
 				// Pick a random peer to retrieve from, reset all others
-				announce := announces[rand.Intn(len(announces))]
+				announce := announces[f.rand.Intn(len(announces))]
 				f.forgetHash(hash)
 
 				// If the block still didn't arrive, queue for completion
@@ -845,7 +855,7 @@ func (f *BlockFetcher) importBlocks(peer string, block *types.Block) {
 			return
 		}
 		// Run the actual import and log any issues
-		if _, err := f.insertChain(types.Blocks{block}); err != nil {
+		if _, err := f.insertChain(peer, types.Blocks{block}); err != nil {
 			log.Debug("Propagated block import failed", "peer", peer, "number", block.Number(), "hash", hash, "err", err)
 			return
 		}
@@ -17,10 +17,14 @@
 package eth
 
 import (
+	crand "crypto/rand"
 	"errors"
+	"fmt"
 	"github.com/ethereum/go-ethereum/miner/logic"
 	"math"
 	"math/big"
+	mrand "math/rand"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -30,15 +34,18 @@ import (
 	"github.com/ethereum/go-ethereum/consensus/beacon"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/forkid"
+	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/eth/downloader"
 	"github.com/ethereum/go-ethereum/eth/fetcher"
 	"github.com/ethereum/go-ethereum/eth/protocols/eth"
+	"github.com/ethereum/go-ethereum/eth/protocols/pool"
 	"github.com/ethereum/go-ethereum/eth/protocols/snap"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
 	"github.com/ethereum/go-ethereum/params"
 )
 
@@ -89,6 +96,8 @@ type handlerConfig struct {
 	EventMux   *event.TypeMux            // Legacy event mux, deprecate for `feed`
 	Checkpoint *params.TrustedCheckpoint // Hard coded checkpoint for sync challenges
 	Whitelist  map[uint64]common.Hash    // Hard coded whitelist for sync challenged
+	Seed       int64                     // Seed of the peer selection for block propagation, random if zero
+	Pool       []string                  // Enode URLs of the members of a colluding mining pool
 }
 
 type handler struct {
@@ -105,6 +114,16 @@ type handler struct {
 	txpool     txPool
 	chain      *core.BlockChain
 	miningData *logic.MiningData
+	racePeers  map[enode.ID]struct{} // Peers receiving racing blocks immediately, if configured
+	delays     *delayer              // Sends delayed to part of the peers, dropped on stop
+	eclipse    *eclipse              // Peers whose view of the network this node controls
+
+	poolMembers map[enode.ID]struct{} // Members of the colluding mining pool, if configured
+	poolPeers   map[string]*pool.Peer // Connected pool members, keyed by peer id
+	poolLock    sync.RWMutex          // Protects poolPeers
+
+	rand     *mrand.Rand // Source of the peer selection for block propagation
+	randLock sync.Mutex  // Protects rand, used by the broadcast and fetcher goroutines
 
 	maxPeers int
 
@@ -118,6 +137,7 @@ type handler struct {
 	txsCh         chan core.NewTxsEvent
 	txsSub        event.Subscription
 	minedBlockSub *event.TypeMuxSubscription
+	poolSub       *event.TypeMuxSubscription
 
 	whitelist map[uint64]common.Hash
 
@@ -148,10 +168,44 @@ func newHandler(config *handlerConfig) (*handler, error) {
 		merger:     config.Merger,
 		whitelist:  config.Whitelist,
 		quitSync:   make(chan struct{}),
+		delays:     newDelayer(),
+		eclipse:    newEclipse(config.MiningData.EclipsePeers),
 	}
 
 	h.miningData.EventMux = h.eventMux
 
+	seed := config.Seed
+	if seed == 0 {
+		n, err := crand.Int(crand.Reader, big.NewInt(math.MaxInt64))
+		if err != nil {
+			return nil, err
+		}
+		seed = n.Int64()
+	}
+	h.rand = mrand.New(mrand.NewSource(seed))
+
+	if len(h.miningData.Race.Peers) > 0 {
+		h.racePeers = make(map[enode.ID]struct{})
+		for _, url := range h.miningData.Race.Peers {
+			node, err := enode.Parse(enode.ValidSchemes, url)
+			if err != nil {
+				return nil, fmt.Errorf("invalid race peer %q: %v", url, err)
+			}
+			h.racePeers[node.ID()] = struct{}{}
+		}
+	}
+	if len(config.Pool) > 0 {
+		h.poolMembers = make(map[enode.ID]struct{})
+		h.poolPeers = make(map[string]*pool.Peer)
+		for _, url := range config.Pool {
+			node, err := enode.Parse(enode.ValidSchemes, url)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pool member %q: %v", url, err)
+			}
+			h.poolMembers[node.ID()] = struct{}{}
+		}
+	}
+
 	if config.Sync == downloader.FullSync {
 		// The database seems empty as the current block is the genesis. Yet the snap
 		// block is ahead, so snap sync was enabled for this node at a certain point.
@@ -207,7 +261,10 @@ func newHandler(config *handlerConfig) (*handler, error) {
 	heighter := func() uint64 {
 		return h.chain.CurrentBlock().NumberU64()
 	}
-	inserter := func(blocks types.Blocks) (int, error) {
+	inserter := func(peer string, blocks types.Blocks) (int, error) {
+		// Keep the first sight of the blocks, whether they get imported or not
+		h.chain.RecordBlockDeliveries(blocks, peer, rawdb.BlockSourceFetcher)
+
 		// All the block fetcher activities should be disabled
 		// after the transition. Print the warning log.
 		if h.merger.PoSFinalized() {
@@ -270,7 +327,7 @@ func newHandler(config *handlerConfig) (*handler, error) {
 			return 0, nil
 		}
 
-		n, err := logic.OnOthersFoundBlocks(blocks, h.miningData)
+		n, err := logic.OnOthersFoundBlocks(blocks, peer, h.miningData)
 
 		if err == nil {
 			atomic.StoreUint32(&h.acceptTxs, 1) // Mark initial sync done on any fetcher import
@@ -280,6 +337,7 @@ func newHandler(config *handlerConfig) (*handler, error) {
 	}
 
 	h.blockFetcher = fetcher.NewBlockFetcher(false, nil, h.chain.GetBlockByHash, validator, h.BroadcastBlock, heighter, nil, inserter, h.removePeer)
+	h.blockFetcher.SetSeed(h.rand.Int63())
 
 	fetchTx := func(peer string, hashes []common.Hash) error {
 		p := h.peers.peer(peer)
@@ -527,9 +585,16 @@ func (h *handler) Start(maxPeers int) {
 
 	// broadcast mined blocks
 	h.wg.Add(1)
-	h.minedBlockSub = h.eventMux.Subscribe(core.NewMinedBlockEvent{})
+	h.minedBlockSub = h.eventMux.Subscribe(core.NewMinedBlockEvent{}, core.NewRacingBlockEvent{})
 	go h.minedBroadcastLoop()
 
+	// share withheld blocks and decisions with the mining pool
+	if h.poolMembers != nil {
+		h.wg.Add(1)
+		h.poolSub = h.eventMux.Subscribe(logic.PoolBlockEvent{}, logic.PoolDecisionEvent{})
+		go h.poolBroadcastLoop()
+	}
+
 	// start sync handlers
 	h.wg.Add(1)
 	go h.chainSync.loop()
@@ -538,11 +603,15 @@ func (h *handler) Start(maxPeers int) {
 func (h *handler) Stop() {
 	h.txsSub.Unsubscribe()        // quits txBroadcastLoop
 	h.minedBlockSub.Unsubscribe() // quits blockBroadcastLoop
+	if h.poolSub != nil {
+		h.poolSub.Unsubscribe() // quits poolBroadcastLoop
+	}
 
 	// Quit chainSync and txsync64.
 	// After this is done, no new peers will be accepted.
 	close(h.quitSync)
 	h.wg.Wait()
+	h.delays.stop()
 
 	// Disconnect existing sessions.
 	// This also closes the gate for any new registrations on the peer set.
@@ -574,19 +643,17 @@ func (h *handler) broadcastBlock(block *types.Block, propagate bool, eclipse boo
 	}
 	hash := block.Hash()
 
-	peersWithoutBlock := h.peers.peersWithoutBlock(hash)
-
-	var peers = peersWithoutBlock
-
+	// Blocks of others are withheld from or delayed to the eclipsed peers,
+	// blocks published by this node are released to them
+	var (
+		peers   = h.shufflePeers(h.peers.peersWithoutBlock(hash))
+		delayed []*ethPeer
+	)
 	if eclipse {
-		peers = nil
-		for _, peer := range peersWithoutBlock {
-			if !logic.Contains(h.miningData.EclipsePeers, peer.Info().Enode) {
-				peers = append(peers, peer)
-			}
-		}
+		peers, delayed = h.eclipseBlock(peers)
+	} else {
+		h.eclipse.release(hash)
 	}
-
 	// If propagation is requested, send to a subset of the peer
 	if propagate {
 		// Calculate the TD of the block (it's not imported yet, so block.Td is not valid)
@@ -602,7 +669,17 @@ func (h *handler) broadcastBlock(block *types.Block, propagate bool, eclipse boo
 		for _, peer := range transfer {
 			peer.AsyncSendNewBlock(block, td)
 		}
-		log.Trace("Propagated block", "hash", hash, "recipients", len(transfer), "duration", common.PrettyDuration(time.Since(block.ReceivedAt)))
+		// The delayed eclipsed peers receive the block itself rather than an
+		// announcement, as they would only fetch it from this node
+		for _, peer := range delayed {
+			peer := peer
+			h.afterEclipseDelay(peer, true, func() {
+				if !peer.KnownBlock(hash) {
+					peer.AsyncSendNewBlock(block, td)
+				}
+			})
+		}
+		log.Trace("Propagated block", "hash", hash, "recipients", len(transfer), "delayed", len(delayed), "duration", common.PrettyDuration(time.Since(block.ReceivedAt)))
 		return
 	}
 	// Otherwise if the block is indeed in out own chain, announce it
@@ -610,8 +687,113 @@ func (h *handler) broadcastBlock(block *types.Block, propagate bool, eclipse boo
 		for _, peer := range peers {
 			peer.AsyncSendNewBlockHash(block)
 		}
-		log.Trace("Announced block", "hash", hash, "recipients", len(peers), "duration", common.PrettyDuration(time.Since(block.ReceivedAt)))
+		for _, peer := range delayed {
+			peer := peer
+			h.afterEclipseDelay(peer, true, func() {
+				if !peer.KnownBlock(hash) {
+					peer.AsyncSendNewBlockHash(block)
+				}
+			})
+		}
+		log.Trace("Announced block", "hash", hash, "recipients", len(peers), "delayed", len(delayed), "duration", common.PrettyDuration(time.Since(block.ReceivedAt)))
+	}
+}
+
+// eclipseBlock splits the peers a block of others would be relayed to into the
+// peers it is relayed to right away and the eclipsed peers it is delayed to.
+// The eclipsed peers it is withheld from are dropped.
+func (h *handler) eclipseBlock(peers []*ethPeer) ([]*ethPeer, []*ethPeer) {
+	var relayed, delayed []*ethPeer
+	for _, peer := range peers {
+		policy, ok := h.eclipse.policy(peer.Node().ID())
+		switch {
+		case !ok || policy.BlockMode() == logic.EclipseRelay:
+			relayed = append(relayed, peer)
+		case policy.BlockMode() == logic.EclipseDelay:
+			delayed = append(delayed, peer)
+		}
+	}
+	return relayed, delayed
+}
+
+// afterEclipseDelay runs a send to an eclipsed peer after the block or the
+// transaction delay of its policy. The send happens right away if the peer is
+// no longer eclipsed.
+func (h *handler) afterEclipseDelay(peer *ethPeer, block bool, send func()) {
+	policy, _ := h.eclipse.policy(peer.Node().ID())
+	delay := policy.TxDelay
+	if block {
+		delay = policy.BlockDelay
 	}
+	h.delays.after(delay, send)
+}
+
+// broadcastRacingBlock pushes a block racing against a public block of the same
+// height to the configured share of peers, and to the remaining peers after the
+// configured delay. The block is not announced, so that the first group alone
+// determines which peers learn about it before the race is decided.
+func (h *handler) broadcastRacingBlock(block *types.Block) {
+	hash := block.Hash()
+
+	parent := h.chain.GetBlock(block.ParentHash(), block.NumberU64()-1)
+	if parent == nil {
+		log.Error("Propagating dangling racing block", "number", block.Number(), "hash", hash)
+		return
+	}
+	td := new(big.Int).Add(block.Difficulty(), h.chain.GetTd(block.ParentHash(), block.NumberU64()-1))
+
+	// The racing block is published by this node, so the eclipsed peers may
+	// learn about it
+	h.eclipse.release(hash)
+
+	var first, rest []*ethPeer
+	peers := h.shufflePeers(h.peers.peersWithoutBlock(hash))
+	if h.racePeers != nil {
+		for _, peer := range peers {
+			if _, ok := h.racePeers[peer.Node().ID()]; ok {
+				first = append(first, peer)
+			} else {
+				rest = append(rest, peer)
+			}
+		}
+	} else {
+		n := int(math.Round(h.miningData.Race.Fraction * float64(len(peers))))
+		if n > len(peers) {
+			n = len(peers)
+		}
+		first, rest = peers[:n], peers[n:]
+	}
+	for _, peer := range first {
+		peer.AsyncSendNewBlock(block, td)
+	}
+	log.Trace("Propagated racing block", "hash", hash, "recipients", len(first), "withheld", len(rest))
+
+	if delay := h.miningData.Race.Delay; delay > 0 && len(rest) > 0 {
+		h.delays.after(delay, func() {
+			for _, peer := range rest {
+				if !peer.KnownBlock(hash) {
+					peer.AsyncSendNewBlock(block, td)
+				}
+			}
+			log.Trace("Propagated delayed racing block", "hash", hash, "recipients", len(rest))
+		})
+	}
+}
+
+// shufflePeers puts the peers in a random order drawn from the seeded source of
+// the handler. The peers are sorted by ID first, so that the order only depends
+// on the seed and not on the iteration order of the peer set.
+func (h *handler) shufflePeers(peers []*ethPeer) []*ethPeer {
+	sort.Slice(peers, func(i, j int) bool {
+		return peers[i].ID() < peers[j].ID()
+	})
+	h.randLock.Lock()
+	defer h.randLock.Unlock()
+
+	h.rand.Shuffle(len(peers), func(i, j int) {
+		peers[i], peers[j] = peers[j], peers[i]
+	})
+	return peers
 }
 
 // BroadcastTransactions will propagate a batch of transactions
@@ -628,10 +810,11 @@ func (h *handler) BroadcastTransactions(txs types.Transactions) {
 		txset = make(map[*ethPeer][]common.Hash) // Set peer->hash to transfer directly
 		annos = make(map[*ethPeer][]common.Hash) // Set peer->hash to announce
 
+		delayed = make(map[*ethPeer][]common.Hash) // Set peer->hash to announce after the eclipse delay
 	)
 	// Broadcast transactions to a batch of peers not knowing about it
 	for _, tx := range txs {
-		peers := h.peers.peersWithoutTransaction(tx.Hash())
+		peers := h.eclipseTransaction(h.peers.peersWithoutTransaction(tx.Hash()), tx.Hash(), delayed)
 		// Send the tx unconditionally to a subset of our peers
 		numDirect := int(math.Sqrt(float64(len(peers))))
 		for _, peer := range peers[:numDirect] {
@@ -652,9 +835,32 @@ func (h *handler) BroadcastTransactions(txs types.Transactions) {
 		annoCount += len(hashes)
 		peer.AsyncSendPooledTransactionHashes(hashes)
 	}
+	for peer, hashes := range delayed {
+		peer, hashes := peer, hashes
+		h.afterEclipseDelay(peer, false, func() {
+			peer.AsyncSendPooledTransactionHashes(hashes)
+		})
+	}
 	log.Debug("Transaction broadcast", "txs", len(txs),
 		"announce packs", annoPeers, "announced hashes", annoCount,
-		"tx packs", directPeers, "broadcast txs", directCount)
+		"tx packs", directPeers, "broadcast txs", directCount, "delayed packs", len(delayed))
+}
+
+// eclipseTransaction returns the peers a transaction is gossiped to right away.
+// The eclipsed peers it is delayed to are collected in delayed, the eclipsed
+// peers it is withheld from are dropped.
+func (h *handler) eclipseTransaction(peers []*ethPeer, hash common.Hash, delayed map[*ethPeer][]common.Hash) []*ethPeer {
+	relayed := peers[:0:0]
+	for _, peer := range peers {
+		policy, ok := h.eclipse.policy(peer.Node().ID())
+		switch {
+		case !ok || policy.TxMode() == logic.EclipseRelay:
+			relayed = append(relayed, peer)
+		case policy.TxMode() == logic.EclipseDelay:
+			delayed[peer] = append(delayed[peer], hash)
+		}
+	}
+	return relayed
 }
 
 // minedBroadcastLoop sends mined blocks to connected peers.
@@ -662,9 +868,17 @@ func (h *handler) minedBroadcastLoop() {
 	defer h.wg.Done()
 
 	for obj := range h.minedBlockSub.Chan() {
-		if ev, ok := obj.Data.(core.NewMinedBlockEvent); ok {
+		switch ev := obj.Data.(type) {
+		case core.NewMinedBlockEvent:
 			h.broadcastBlock(ev.Block, true, false)  // First propagate block to peers
 			h.broadcastBlock(ev.Block, false, false) // Only then announce to the rest
+		case core.NewRacingBlockEvent:
+			if !h.miningData.Race.Enabled {
+				h.broadcastBlock(ev.Block, true, false)
+				h.broadcastBlock(ev.Block, false, false)
+				continue
+			}
+			h.broadcastRacingBlock(ev.Block)
 		}
 	}
 }
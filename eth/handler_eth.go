@@ -24,6 +24,7 @@ import (
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/eth/protocols/eth"
 	"github.com/ethereum/go-ethereum/p2p/enode"
@@ -49,6 +50,25 @@ func (h *ethHandler) PeerInfo(id enode.ID) interface{} {
 	return nil
 }
 
+// FiltersChain reports whether the peer is eclipsed with a policy filtering the
+// chain data served to it.
+func (h *ethHandler) FiltersChain(peer *eth.Peer) bool {
+	return h.eclipse.filters(peer.Node().ID())
+}
+
+// ServeBlock reports whether the headers, body and receipts of a block may be
+// served to a peer, which is not the case for the blocks withheld from an
+// eclipsed peer filtering the chain.
+func (h *ethHandler) ServeBlock(peer *eth.Peer, hash common.Hash) bool {
+	number := func() (uint64, bool) {
+		if number := rawdb.ReadHeaderNumber(h.database, hash); number != nil {
+			return *number, true
+		}
+		return 0, false
+	}
+	return h.eclipse.serves(peer.Node().ID(), hash, peer.KnownBlock(hash), number)
+}
+
 // AcceptTxs retrieves whether transaction processing is enabled on the node
 // or if inbound transactions should simply be dropped.
 func (h *ethHandler) AcceptTxs() bool {
@@ -103,8 +123,10 @@ func (h *ethHandler) handleBlockAnnounces(peer *eth.Peer, hashes []common.Hash,
 			unknownNumbers = append(unknownNumbers, numbers[i])
 		}
 	}
+	now := time.Now()
 	for i := 0; i < len(unknownHashes); i++ {
-		h.blockFetcher.Notify(peer.ID(), unknownHashes[i], unknownNumbers[i], time.Now(), peer.RequestOneHeader, peer.RequestBodies)
+		h.chain.RecordBlockAnnounce(unknownHashes[i], unknownNumbers[i], peer.ID(), now)
+		h.blockFetcher.Notify(peer.ID(), unknownHashes[i], unknownNumbers[i], now, peer.RequestOneHeader, peer.RequestBodies)
 	}
 	return nil
 }
@@ -121,6 +143,7 @@ func (h *ethHandler) handleBlockBroadcast(peer *eth.Peer, block *types.Block, td
 		// return errors.New("unexpected block announces")
 	}
 	// Schedule the block for import
+	h.chain.RecordBlockDeliveries(types.Blocks{block}, peer.ID(), rawdb.BlockSourceFetcher)
 	h.blockFetcher.Enqueue(peer.ID(), block)
 
 	// Assuming the block is importable by the peer, but possibly not yet done so,
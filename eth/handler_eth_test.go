@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"math/big"
 	"math/rand"
+	"net"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -32,9 +33,11 @@ import (
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/eth/downloader"
 	"github.com/ethereum/go-ethereum/eth/protocols/eth"
 	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/miner/logic"
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/p2p/enode"
 	"github.com/ethereum/go-ethereum/params"
@@ -676,6 +679,101 @@ func testBroadcastBlock(t *testing.T, peers, bcasts int) {
 	}
 }
 
+// Tests that racing blocks are pushed to the configured share or list of peers
+// right away, and to the remaining peers only after the configured delay.
+func TestBroadcastRacingBlockFraction(t *testing.T) { testBroadcastRacingBlock(t, false) }
+func TestBroadcastRacingBlockPeers(t *testing.T)    { testBroadcastRacingBlock(t, true) }
+
+func testBroadcastRacingBlock(t *testing.T, explicit bool) {
+	t.Parallel()
+
+	// Create the sinks with proper node keys, so they can be listed by URL
+	var (
+		sinks = make([]*testEthHandler, 4)
+		nodes = make([]*enode.Node, len(sinks))
+		race  = logic.RaceConfig{Enabled: true, Fraction: 0.5, Delay: 500 * time.Millisecond}
+	)
+	for i := range sinks {
+		sinks[i] = new(testEthHandler)
+		key, _ := crypto.GenerateKey()
+		nodes[i] = enode.NewV4(&key.PublicKey, net.IP{127, 0, 0, 1}, 30303, 30303)
+	}
+	if explicit {
+		race.Peers = []string{nodes[1].URLv4(), nodes[3].URLv4()}
+	}
+	source := newTestHandlerWithMiningData(1, &logic.MiningData{Race: race, EclipsePeers: logic.NewEclipseSet()})
+	defer source.close()
+
+	var (
+		genesis = source.chain.Genesis()
+		td      = source.chain.GetTd(genesis.Hash(), genesis.NumberU64())
+	)
+	for i, sink := range sinks {
+		sink := sink // Closure for gorotuine below
+
+		sourcePipe, sinkPipe := p2p.MsgPipe()
+		defer sourcePipe.Close()
+		defer sinkPipe.Close()
+
+		sourcePeer := eth.NewPeer(eth.ETH66, p2p.NewPeerPipe(nodes[i].ID(), "", nil, sourcePipe), sourcePipe, nil)
+		sinkPeer := eth.NewPeer(eth.ETH66, p2p.NewPeerPipe(enode.ID{0}, "", nil, sinkPipe), sinkPipe, nil)
+		defer sourcePeer.Close()
+		defer sinkPeer.Close()
+
+		go source.handler.runEthPeer(sourcePeer, func(peer *eth.Peer) error {
+			return eth.Handle((*ethHandler)(source.handler), peer)
+		})
+		if err := sinkPeer.Handshake(1, td, genesis.Hash(), genesis.Hash(), forkid.NewIDWithChain(source.chain), forkid.NewFilter(source.chain)); err != nil {
+			t.Fatalf("failed to run protocol handshake")
+		}
+		go eth.Handle(sink, sinkPeer)
+	}
+	// Collect the sinks receiving the block, in the order of arrival
+	arrivals := make(chan int, 2*len(sinks))
+	for i, sink := range sinks {
+		ch := make(chan *types.Block, 1)
+		sub := sink.blockBroadcasts.Subscribe(ch)
+		defer sub.Unsubscribe()
+
+		go func(i int) {
+			for range ch {
+				arrivals <- i
+			}
+		}(i)
+	}
+	time.Sleep(100 * time.Millisecond)
+	source.handler.broadcastRacingBlock(source.chain.CurrentBlock())
+
+	collect := func(window time.Duration) map[int]bool {
+		received := make(map[int]bool)
+		timeout := time.After(window)
+		for {
+			select {
+			case i := <-arrivals:
+				if received[i] {
+					t.Errorf("sink %d received the block twice", i)
+				}
+				received[i] = true
+			case <-timeout:
+				return received
+			}
+		}
+	}
+	first := collect(250 * time.Millisecond)
+	if len(first) != 2 {
+		t.Errorf("immediate recipient count mismatch: have %d, want %d", len(first), 2)
+	}
+	if explicit && (!first[1] || !first[3]) {
+		t.Errorf("immediate recipients mismatch: have %v, want sinks 1 and 3", first)
+	}
+	rest := collect(750 * time.Millisecond)
+	for i := range sinks {
+		if first[i] == rest[i] {
+			t.Errorf("sink %d: received immediately %t, after the delay %t", i, first[i], rest[i])
+		}
+	}
+}
+
 // Tests that a propagated malformed block (uncles or transactions don't match
 // with the hashes in the header) gets discarded and not broadcast forward.
 func TestBroadcastMalformedBlock66(t *testing.T) { testBroadcastMalformedBlock(t, eth.ETH66) }
@@ -0,0 +1,124 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth/protocols/pool"
+	"github.com/ethereum/go-ethereum/miner/logic"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+// poolHandler implements the pool.Backend interface to handle the blocks and
+// decisions shared by the other members of a colluding mining pool.
+type poolHandler handler
+
+func (h *poolHandler) Chain() *core.BlockChain { return h.chain }
+
+// RunPeer is invoked when a peer joins on the `pool` protocol. Peers that are
+// not members of the pool are kept connected for their other protocols, but
+// nothing is shared with them.
+func (h *poolHandler) RunPeer(peer *pool.Peer, hand pool.Handler) error {
+	if _, ok := h.poolMembers[peer.Node().ID()]; !ok {
+		return pool.Ignore(peer)
+	}
+	if err := peer.Handshake(h.chain.Genesis().Hash()); err != nil {
+		peer.Log().Debug("Pool handshake failed", "err", err)
+		return err
+	}
+	h.poolLock.Lock()
+	if _, ok := h.poolPeers[peer.ID()]; ok {
+		h.poolLock.Unlock()
+		return fmt.Errorf("pool peer %s already registered", peer.ID())
+	}
+	h.poolPeers[peer.ID()] = peer
+	h.poolLock.Unlock()
+
+	defer func() {
+		h.poolLock.Lock()
+		delete(h.poolPeers, peer.ID())
+		h.poolLock.Unlock()
+	}()
+	peer.Log().Debug("Pool member connected", "name", peer.Name())
+	return hand(peer)
+}
+
+// PeerInfo retrieves all known `pool` information about a peer.
+func (h *poolHandler) PeerInfo(id enode.ID) interface{} {
+	h.poolLock.RLock()
+	defer h.poolLock.RUnlock()
+
+	if p, ok := h.poolPeers[id.String()]; ok {
+		return &struct {
+			Version uint `json:"version"`
+		}{p.Version()}
+	}
+	return nil
+}
+
+// Handle is invoked from a peer's message handler when it receives a block or
+// decision shared by a pool member. Blocks that fail to insert are not held
+// against the member, as they may simply have raced with a fork of our own.
+func (h *poolHandler) Handle(peer *pool.Peer, packet pool.Packet) error {
+	switch packet := packet.(type) {
+	case *pool.BlocksPacket:
+		blocks := types.Blocks(*packet)
+		if _, err := logic.OnPoolBlocks(blocks, peer.ID(), h.miningData); err != nil {
+			peer.Log().Debug("Failed to insert pool blocks", "count", len(blocks), "err", err)
+		}
+		return nil
+
+	case *pool.DecisionPacket:
+		logic.OnPoolDecision(logic.PoolDecisionEvent{
+			Action:    logic.Action(packet.Action),
+			Head:      packet.Head,
+			Number:    packet.Number,
+			Published: packet.Published,
+		}, peer.ID(), h.miningData)
+		return nil
+
+	default:
+		return fmt.Errorf("unexpected pool packet type: %T", packet)
+	}
+}
+
+// poolBroadcastLoop shares the withheld blocks and the publish decisions of this
+// node with the connected pool members.
+func (h *handler) poolBroadcastLoop() {
+	defer h.wg.Done()
+
+	for obj := range h.poolSub.Chan() {
+		h.poolLock.RLock()
+		for _, peer := range h.poolPeers {
+			switch ev := obj.Data.(type) {
+			case logic.PoolBlockEvent:
+				peer.AsyncSendBlocks(types.Blocks{ev.Block})
+			case logic.PoolDecisionEvent:
+				peer.AsyncSendDecision(&pool.DecisionPacket{
+					Action:    uint64(ev.Action),
+					Head:      ev.Head,
+					Number:    ev.Number,
+					Published: ev.Published,
+				})
+			}
+		}
+		h.poolLock.RUnlock()
+	}
+}
@@ -32,6 +32,7 @@ import (
 	"github.com/ethereum/go-ethereum/eth/downloader"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/miner/logic"
 	"github.com/ethereum/go-ethereum/params"
 )
 
@@ -131,6 +132,12 @@ func newTestHandler() *testHandler {
 // newTestHandlerWithBlocks creates a new handler for testing purposes, with a
 // given number of initial blocks.
 func newTestHandlerWithBlocks(blocks int) *testHandler {
+	return newTestHandlerWithMiningData(blocks, nil)
+}
+
+// newTestHandlerWithMiningData creates a new handler for testing purposes, with
+// a given number of initial blocks and the given mining configuration.
+func newTestHandlerWithMiningData(blocks int, miningData *logic.MiningData) *testHandler {
 	// Create a database pre-initialize with a genesis block
 	db := rawdb.NewMemoryDatabase()
 	(&core.Genesis{
@@ -154,6 +161,7 @@ func newTestHandlerWithBlocks(blocks int) *testHandler {
 		Network:    1,
 		Sync:       downloader.SnapSync,
 		BloomCache: 1,
+		MiningData: miningData,
 	})
 	handler.Start(1000)
 
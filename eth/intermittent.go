@@ -0,0 +1,94 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"time"
+
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/miner/logic"
+)
+
+// intermittentHeadChanSize is the size of channel listening to the public heads
+// the intermittent mining phases are checked on.
+const intermittentHeadChanSize = 10
+
+// intermittentLoop alternates between the configured selfish strategy and
+// honest mining on the schedule of the intermittent config, until the node is
+// stopped. Phase changes are checked on every new public head and, with the
+// time schedule, when the phase runs out.
+func (s *Ethereum) intermittentLoop() {
+	defer s.intermittentWg.Done()
+
+	var (
+		config   = s.config.Miner.Intermittent
+		selfish  = s.config.Miner.MinerStrategy
+		phases   = logic.NewIntermittent(&config, s.blockchain.CurrentHeader(), time.Now())
+		heads    = make(chan core.ChainHeadEvent, intermittentHeadChanSize)
+		timer    = time.NewTimer(0)
+		deadline <-chan time.Time
+	)
+	defer timer.Stop()
+	<-timer.C
+
+	// switchPhase moves the miner to the strategy of the current phase. The head
+	// subscription is dropped meanwhile, as publishing the withheld blocks emits
+	// head events nobody would receive.
+	sub := s.blockchain.SubscribeChainHeadEvent(heads)
+	switchPhase := func() {
+		sub.Unsubscribe()
+		name := logic.HONEST
+		if phases.Selfish() {
+			name = selfish
+		}
+		if err := s.SetStrategy(name, config.Publish); err != nil {
+			log.Error("Failed to switch intermittent mining phase", "strategy", name, "err", err)
+		}
+		sub = s.blockchain.SubscribeChainHeadEvent(heads)
+	}
+	defer func() { sub.Unsubscribe() }()
+
+	if !phases.Selfish() {
+		switchPhase()
+	}
+	log.Info("Started intermittent mining", "schedule", config.Schedule, "selfish", phases.Selfish())
+	for {
+		if end := phases.Deadline(); !end.IsZero() {
+			timer.Reset(time.Until(end))
+			deadline = timer.C
+		}
+		select {
+		case <-heads:
+		case <-deadline:
+		case <-sub.Err():
+			return
+		case <-s.closeIntermittent:
+			return
+		}
+		if !timer.Stop() && deadline != nil {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		deadline = nil
+		if phases.Update(s.blockchain.CurrentHeader(), time.Now()) {
+			switchPhase()
+		}
+	}
+}
@@ -90,6 +90,18 @@ type Backend interface {
 	Handle(peer *Peer, packet Packet) error
 }
 
+// ChainFilter is an optional interface of the backend to withhold parts of the
+// chain from some peers. Headers, bodies and receipts of the blocks it doesn't
+// serve to a peer are left out of the responses as if they were unknown.
+type ChainFilter interface {
+	// FiltersChain reports whether any blocks may be withheld from the peer.
+	// If not, the responses to the peer are served unfiltered.
+	FiltersChain(peer *Peer) bool
+
+	// ServeBlock reports whether the data of a block may be served to the peer.
+	ServeBlock(peer *Peer, hash common.Hash) bool
+}
+
 // TxPool defines the methods needed by the protocol handler to serve transactions.
 type TxPool interface {
 	// Get retrieves the transaction from the local txpool with the given hash.
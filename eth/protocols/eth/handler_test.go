@@ -370,6 +370,72 @@ func testGetBlockBodies(t *testing.T, protocol uint) {
 	}
 }
 
+// filteringBackend is a test backend withholding some blocks from its peers.
+type filteringBackend struct {
+	*testBackend
+	filtered bool
+	withheld map[common.Hash]bool
+}
+
+func (b *filteringBackend) FiltersChain(peer *Peer) bool { return b.filtered }
+
+func (b *filteringBackend) ServeBlock(peer *Peer, hash common.Hash) bool {
+	return !b.withheld[hash]
+}
+
+// Tests that the blocks withheld by a chain filter are left out of the header,
+// body and receipt responses, and that header responses stop at them. Peers the
+// filter doesn't apply to are served everything.
+func TestChainFilter66(t *testing.T) {
+	t.Parallel()
+
+	backend := &filteringBackend{testBackend: newTestBackend(10), filtered: true, withheld: make(map[common.Hash]bool)}
+	defer backend.close()
+
+	peer, _ := newTestPeer("peer", ETH66, backend)
+	defer peer.close()
+
+	var (
+		served   = backend.chain.GetBlockByNumber(3)
+		withheld = backend.chain.GetBlockByNumber(6)
+	)
+	backend.withheld[withheld.Hash()] = true
+
+	p2p.Send(peer.app, GetBlockHeadersMsg, GetBlockHeadersPacket66{
+		RequestId:             1,
+		GetBlockHeadersPacket: &GetBlockHeadersPacket{Origin: HashOrNumber{Number: 4}, Amount: 5},
+	})
+	headers := []*types.Header{backend.chain.GetHeaderByNumber(4), backend.chain.GetHeaderByNumber(5)}
+	if err := p2p.ExpectMsg(peer.app, BlockHeadersMsg, BlockHeadersPacket66{RequestId: 1, BlockHeadersPacket: headers}); err != nil {
+		t.Errorf("headers mismatch: %v", err)
+	}
+	p2p.Send(peer.app, GetBlockBodiesMsg, GetBlockBodiesPacket66{
+		RequestId:            2,
+		GetBlockBodiesPacket: []common.Hash{withheld.Hash(), served.Hash()},
+	})
+	bodies := []*BlockBody{{Transactions: served.Transactions(), Uncles: served.Uncles()}}
+	if err := p2p.ExpectMsg(peer.app, BlockBodiesMsg, BlockBodiesPacket66{RequestId: 2, BlockBodiesPacket: bodies}); err != nil {
+		t.Errorf("bodies mismatch: %v", err)
+	}
+	p2p.Send(peer.app, GetReceiptsMsg, GetReceiptsPacket66{
+		RequestId:         3,
+		GetReceiptsPacket: []common.Hash{withheld.Hash(), served.Hash()},
+	})
+	receipts := [][]*types.Receipt{backend.chain.GetReceiptsByHash(served.Hash())}
+	if err := p2p.ExpectMsg(peer.app, ReceiptsMsg, ReceiptsPacket66{RequestId: 3, ReceiptsPacket: receipts}); err != nil {
+		t.Errorf("receipts mismatch: %v", err)
+	}
+	backend.filtered = false
+	p2p.Send(peer.app, GetBlockBodiesMsg, GetBlockBodiesPacket66{
+		RequestId:            4,
+		GetBlockBodiesPacket: []common.Hash{withheld.Hash(), served.Hash()},
+	})
+	bodies = []*BlockBody{{Transactions: withheld.Transactions(), Uncles: withheld.Uncles()}, bodies[0]}
+	if err := p2p.ExpectMsg(peer.app, BlockBodiesMsg, BlockBodiesPacket66{RequestId: 4, BlockBodiesPacket: bodies}); err != nil {
+		t.Errorf("unfiltered bodies mismatch: %v", err)
+	}
+}
+
 // Tests that the state trie nodes can be retrieved based on hashes.
 func TestGetNodeData66(t *testing.T) { testGetNodeData(t, ETH66) }
 
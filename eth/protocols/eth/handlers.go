@@ -36,9 +36,43 @@ func handleGetBlockHeaders66(backend Backend, msg Decoder, peer *Peer) error {
 		return fmt.Errorf("%w: message %v: %v", errDecode, msg, err)
 	}
 	response := ServiceGetBlockHeadersQuery(backend.Chain(), query.GetBlockHeadersPacket, peer)
+	if filter := chainFilter(backend, peer); filter != nil {
+		// Cut the response at the first withheld header to keep it contiguous
+		for i, header := range response {
+			if !filter.ServeBlock(peer, header.Hash()) {
+				response = response[:i]
+				break
+			}
+		}
+	}
 	return peer.ReplyBlockHeaders(query.RequestId, response)
 }
 
+// chainFilter returns the chain filter of the backend if it may withhold blocks
+// from the peer, nil otherwise.
+func chainFilter(backend Backend, peer *Peer) ChainFilter {
+	if filter, ok := backend.(ChainFilter); ok && filter.FiltersChain(peer) {
+		return filter
+	}
+	return nil
+}
+
+// filterBlockHashes drops the hashes of the blocks the backend doesn't serve to
+// the peer from a body or receipt query.
+func filterBlockHashes(backend Backend, peer *Peer, hashes []common.Hash) []common.Hash {
+	filter := chainFilter(backend, peer)
+	if filter == nil {
+		return hashes
+	}
+	served := make([]common.Hash, 0, len(hashes))
+	for _, hash := range hashes {
+		if filter.ServeBlock(peer, hash) {
+			served = append(served, hash)
+		}
+	}
+	return served
+}
+
 // ServiceGetBlockHeadersQuery assembles the response to a header query. It is
 // exposed to allow external packages to test protocol behavior.
 func ServiceGetBlockHeadersQuery(chain *core.BlockChain, query *GetBlockHeadersPacket, peer *Peer) []*types.Header {
@@ -133,6 +167,7 @@ func handleGetBlockBodies66(backend Backend, msg Decoder, peer *Peer) error {
 	if err := msg.Decode(&query); err != nil {
 		return fmt.Errorf("%w: message %v: %v", errDecode, msg, err)
 	}
+	query.GetBlockBodiesPacket = filterBlockHashes(backend, peer, query.GetBlockBodiesPacket)
 	response := ServiceGetBlockBodiesQuery(backend.Chain(), query.GetBlockBodiesPacket)
 	return peer.ReplyBlockBodiesRLP(query.RequestId, response)
 }
@@ -201,6 +236,7 @@ func handleGetReceipts66(backend Backend, msg Decoder, peer *Peer) error {
 	if err := msg.Decode(&query); err != nil {
 		return fmt.Errorf("%w: message %v: %v", errDecode, msg, err)
 	}
+	query.GetReceiptsPacket = filterBlockHashes(backend, peer, query.GetReceiptsPacket)
 	response := ServiceGetReceiptsQuery(backend.Chain(), query.GetReceiptsPacket)
 	return peer.ReplyReceiptsRLP(query.RequestId, response)
 }
@@ -0,0 +1,218 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package pool
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p"
+)
+
+const (
+	// handshakeTimeout is the maximum allowed time for the `pool` handshake to
+	// complete before dropping the connection.
+	handshakeTimeout = 5 * time.Second
+
+	// maxQueuedPackets is the maximum number of blocks and decisions to queue up
+	// before dropping broadcasts.
+	maxQueuedPackets = 64
+)
+
+// Peer is a collection of relevant information we have about a `pool` peer.
+type Peer struct {
+	id string // Unique ID for the peer, cached
+
+	*p2p.Peer                   // The embedded P2P package peer
+	rw        p2p.MsgReadWriter // Input/output streams for pool
+	version   uint              // Protocol version negotiated
+
+	queued chan Packet   // Queue of blocks and decisions to send to the peer, in order
+	term   chan struct{} // Termination channel to stop the broadcaster
+
+	logger log.Logger // Contextual logger with the peer id injected
+}
+
+// NewPeer create a wrapper for a network connection and negotiated  protocol
+// version.
+func NewPeer(version uint, p *p2p.Peer, rw p2p.MsgReadWriter) *Peer {
+	id := p.ID().String()
+	peer := &Peer{
+		id:      id,
+		Peer:    p,
+		rw:      rw,
+		version: version,
+		queued:  make(chan Packet, maxQueuedPackets),
+		term:    make(chan struct{}),
+		logger:  log.New("peer", id[:8]),
+	}
+	go peer.broadcast()
+
+	return peer
+}
+
+// NewFakePeer create a fake pool peer without a backing p2p peer, for testing purposes.
+func NewFakePeer(version uint, id string, rw p2p.MsgReadWriter) *Peer {
+	peer := &Peer{
+		id:      id,
+		rw:      rw,
+		version: version,
+		queued:  make(chan Packet, maxQueuedPackets),
+		term:    make(chan struct{}),
+		logger:  log.New("peer", id[:8]),
+	}
+	go peer.broadcast()
+
+	return peer
+}
+
+// Close signals the broadcast goroutine to terminate. Only ever call this if
+// you created the peer yourself via NewPeer. Otherwise let whoever created it
+// clean it up!
+func (p *Peer) Close() {
+	close(p.term)
+}
+
+// ID retrieves the peer's unique identifier.
+func (p *Peer) ID() string {
+	return p.id
+}
+
+// Version retrieves the peer's negoatiated `pool` protocol version.
+func (p *Peer) Version() uint {
+	return p.version
+}
+
+// Log overrides the P2P logget with the higher level one containing only the id.
+func (p *Peer) Log() log.Logger {
+	return p.logger
+}
+
+// Handshake executes the pool protocol handshake, negotiating the version
+// number and making sure both members mine on the same genesis.
+func (p *Peer) Handshake(genesis common.Hash) error {
+	errc := make(chan error, 2)
+	go func() {
+		errc <- p2p.Send(p.rw, StatusMsg, &StatusPacket{
+			ProtocolVersion: uint32(p.version),
+			Genesis:         genesis,
+		})
+	}()
+	go func() {
+		errc <- p.readStatus(genesis)
+	}()
+	timeout := time.NewTimer(handshakeTimeout)
+	defer timeout.Stop()
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-errc:
+			if err != nil {
+				return err
+			}
+		case <-timeout.C:
+			return p2p.DiscReadTimeout
+		}
+	}
+	return nil
+}
+
+// readStatus reads the remote handshake message.
+func (p *Peer) readStatus(genesis common.Hash) error {
+	msg, err := p.rw.ReadMsg()
+	if err != nil {
+		return err
+	}
+	defer msg.Discard()
+
+	if msg.Code != StatusMsg {
+		return fmt.Errorf("%w: first msg has code %x (!= %x)", errNoStatusMsg, msg.Code, StatusMsg)
+	}
+	if msg.Size > maxMessageSize {
+		return fmt.Errorf("%w: %v > %v", errMsgTooLarge, msg.Size, maxMessageSize)
+	}
+	var status StatusPacket
+	if err := msg.Decode(&status); err != nil {
+		return fmt.Errorf("%w: message %v: %v", errDecode, msg, err)
+	}
+	if uint(status.ProtocolVersion) != p.version {
+		return fmt.Errorf("%w: %d (!= %d)", errProtocolVersionMismatch, status.ProtocolVersion, p.version)
+	}
+	if status.Genesis != genesis {
+		return fmt.Errorf("%w: %x (!= %x)", errGenesisMismatch, status.Genesis, genesis)
+	}
+	return nil
+}
+
+// AsyncSendBlocks queues withheld blocks of the private chain for sending to
+// the peer. If the queue is full, the blocks are dropped.
+func (p *Peer) AsyncSendBlocks(blocks types.Blocks) {
+	packet := BlocksPacket(blocks)
+	p.queue(&packet)
+}
+
+// AsyncSendDecision queues a publish decision for sending to the peer. If the
+// queue is full, the decision is dropped.
+func (p *Peer) AsyncSendDecision(decision *DecisionPacket) {
+	p.queue(decision)
+}
+
+// queue schedules a packet for sending, keeping the order of the packets.
+func (p *Peer) queue(packet Packet) {
+	select {
+	case p.queued <- packet:
+	default:
+		p.logger.Debug("Dropping pool broadcast", "type", packet.Name())
+	}
+}
+
+// broadcast is a write loop that sends the queued packets to the remote peer.
+// The goal is to have an async writer that does not lock up the node's mining
+// logic while the remote peer is slow.
+func (p *Peer) broadcast() {
+	for {
+		select {
+		case packet := <-p.queued:
+			var err error
+			switch packet := packet.(type) {
+			case *BlocksPacket:
+				err = p.SendBlocks(types.Blocks(*packet))
+			case *DecisionPacket:
+				err = p.SendDecision(packet)
+			}
+			if err != nil {
+				return
+			}
+		case <-p.term:
+			return
+		}
+	}
+}
+
+// SendBlocks sends withheld blocks of the private chain to the peer.
+func (p *Peer) SendBlocks(blocks types.Blocks) error {
+	p.logger.Trace("Sending pool blocks", "count", len(blocks))
+	return p2p.Send(p.rw, BlocksMsg, BlocksPacket(blocks))
+}
+
+// SendDecision sends a publish decision to the peer.
+func (p *Peer) SendDecision(decision *DecisionPacket) error {
+	p.logger.Trace("Sending pool decision", "action", decision.Action, "number", decision.Number, "hash", decision.Head)
+	return p2p.Send(p.rw, DecisionMsg, decision)
+}
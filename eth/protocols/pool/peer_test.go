@@ -0,0 +1,109 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package pool
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+// Tests that handshake failures are detected and reported correctly.
+func TestHandshake(t *testing.T) {
+	genesis := common.Hash{1}
+
+	tests := []struct {
+		code uint64
+		data interface{}
+		want error
+	}{
+		{code: DecisionMsg, data: DecisionPacket{}, want: errNoStatusMsg},
+		{code: StatusMsg, data: StatusPacket{10, genesis}, want: errProtocolVersionMismatch},
+		{code: StatusMsg, data: StatusPacket{POOL1, common.Hash{3}}, want: errGenesisMismatch},
+		{code: StatusMsg, data: StatusPacket{POOL1, genesis}, want: nil},
+	}
+	for i, test := range tests {
+		app, net := p2p.MsgPipe()
+		defer app.Close()
+		defer net.Close()
+
+		peer := NewPeer(POOL1, p2p.NewPeer(enode.ID{}, "peer", nil), net)
+		defer peer.Close()
+
+		// Send the test status with one side, drain the local status on the other
+		go p2p.Send(app, test.code, test.data)
+		go func() {
+			if msg, err := app.ReadMsg(); err == nil {
+				msg.Discard()
+			}
+		}()
+		if err := peer.Handshake(genesis); !errors.Is(err, test.want) {
+			t.Errorf("test %d: wrong error: have %v, want %v", i, err, test.want)
+		}
+	}
+}
+
+// testBackend records the packets delivered by the message handler.
+type testBackend struct {
+	packets []Packet
+}
+
+func (b *testBackend) Chain() *core.BlockChain                   { return nil }
+func (b *testBackend) RunPeer(peer *Peer, handler Handler) error { return handler(peer) }
+func (b *testBackend) PeerInfo(id enode.ID) interface{}          { return nil }
+
+func (b *testBackend) Handle(peer *Peer, packet Packet) error {
+	b.packets = append(b.packets, packet)
+	return nil
+}
+
+// Tests that queued blocks and decisions are delivered to the remote backend in
+// the order they were queued.
+func TestBroadcast(t *testing.T) {
+	app, net := p2p.MsgPipe()
+	defer app.Close()
+	defer net.Close()
+
+	sender := NewFakePeer(POOL1, "sender00", app)
+	defer sender.Close()
+	receiver := NewFakePeer(POOL1, "receiver", net)
+	defer receiver.Close()
+
+	block := types.NewBlockWithHeader(&types.Header{Number: common.Big1})
+	decision := &DecisionPacket{Action: 2, Head: block.Hash(), Number: 1, Published: 1}
+	sender.AsyncSendBlocks(types.Blocks{block})
+	sender.AsyncSendDecision(decision)
+
+	backend := new(testBackend)
+	for i := 0; i < 2; i++ {
+		if err := HandleMessage(backend, receiver); err != nil {
+			t.Fatalf("message %d: handling failed: %v", i, err)
+		}
+	}
+	blocks, ok := backend.packets[0].(*BlocksPacket)
+	if !ok || len(*blocks) != 1 || (*blocks)[0].Hash() != block.Hash() {
+		t.Errorf("first packet mismatch: have %v, want block %x", backend.packets[0], block.Hash())
+	}
+	if have, ok := backend.packets[1].(*DecisionPacket); !ok || *have != *decision {
+		t.Errorf("second packet mismatch: have %v, want %v", backend.packets[1], decision)
+	}
+}
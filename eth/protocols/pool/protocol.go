@@ -0,0 +1,94 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package pool implements the `pool` protocol, which lets the nodes of a
+// colluding selfish mining pool share their withheld blocks and publish
+// decisions, so that they mine on a single private chain.
+package pool
+
+import (
+	"errors"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Constants to match up protocol versions and messages
+const (
+	POOL1 = 1
+)
+
+// ProtocolName is the official short name of the `pool` protocol used during
+// devp2p capability negotiation.
+const ProtocolName = "pool"
+
+// ProtocolVersions are the supported versions of the `pool` protocol (first
+// is primary).
+var ProtocolVersions = []uint{POOL1}
+
+// protocolLengths are the number of implemented message corresponding to
+// different protocol versions.
+var protocolLengths = map[uint]uint64{POOL1: 3}
+
+// maxMessageSize is the maximum cap on the size of a protocol message.
+const maxMessageSize = 10 * 1024 * 1024
+
+const (
+	StatusMsg   = 0x00
+	BlocksMsg   = 0x01
+	DecisionMsg = 0x02
+)
+
+var (
+	errNoStatusMsg             = errors.New("no status message")
+	errMsgTooLarge             = errors.New("message too long")
+	errDecode                  = errors.New("invalid message")
+	errInvalidMsgCode          = errors.New("invalid message code")
+	errProtocolVersionMismatch = errors.New("protocol version mismatch")
+	errGenesisMismatch         = errors.New("genesis mismatch")
+)
+
+// Packet represents a p2p message in the `pool` protocol.
+type Packet interface {
+	Name() string // Name returns a string corresponding to the message type.
+	Kind() byte   // Kind returns the message type.
+}
+
+// StatusPacket is the handshake of the `pool` protocol.
+type StatusPacket struct {
+	ProtocolVersion uint32
+	Genesis         common.Hash
+}
+
+// BlocksPacket carries withheld blocks of the private chain, parents first.
+type BlocksPacket []*types.Block
+
+// DecisionPacket carries a publish decision of a pool member.
+type DecisionPacket struct {
+	Action    uint64      // Publish decision, see logic.Action
+	Head      common.Hash // Private head the decision was taken on, the adopted public head for adoptions
+	Number    uint64      // Number of the head
+	Published uint64      // Number of the last published private block
+}
+
+func (*StatusPacket) Name() string { return "Status" }
+func (*StatusPacket) Kind() byte   { return StatusMsg }
+
+func (*BlocksPacket) Name() string { return "Blocks" }
+func (*BlocksPacket) Kind() byte   { return BlocksMsg }
+
+func (*DecisionPacket) Name() string { return "Decision" }
+func (*DecisionPacket) Kind() byte   { return DecisionMsg }
@@ -27,6 +27,7 @@ import (
 	"github.com/ethereum/go-ethereum/eth/downloader"
 	"github.com/ethereum/go-ethereum/eth/protocols/eth"
 	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/miner/logic"
 )
 
 const (
@@ -42,6 +43,10 @@ func (h *handler) syncTransactions(p *eth.Peer) {
 	// order, insertions could overflow the non-executable queues and get dropped.
 	//
 	// TODO(karalabe): Figure out if we could get away with random order somehow
+	policy, eclipsed := h.eclipse.policy(p.Node().ID())
+	if eclipsed && policy.TxMode() == logic.EclipseWithhold {
+		return
+	}
 	var txs types.Transactions
 	pending := h.txpool.Pending(false)
 	for _, batch := range pending {
@@ -57,6 +62,12 @@ func (h *handler) syncTransactions(p *eth.Peer) {
 	for i, tx := range txs {
 		hashes[i] = tx.Hash()
 	}
+	if eclipsed && policy.TxMode() == logic.EclipseDelay {
+		h.delays.after(policy.TxDelay, func() {
+			p.AsyncSendPooledTransactionHashes(hashes)
+		})
+		return
+	}
 	p.AsyncSendPooledTransactionHashes(hashes)
 }
 
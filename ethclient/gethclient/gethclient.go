@@ -22,12 +22,14 @@ import (
 	"math/big"
 	"runtime"
 	"runtime/debug"
+	"time"
 
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
 	"github.com/ethereum/go-ethereum/rpc"
 )
 
@@ -179,6 +181,238 @@ func (ec *Client) SubscribePendingTransactions(ctx context.Context, ch chan<- co
 	return ec.c.EthSubscribe(ctx, ch, "newPendingTransactions")
 }
 
+// PrivateHead returns the head of the selfish miner's private chain.
+func (ec *Client) PrivateHead(ctx context.Context) (*types.Header, error) {
+	var head *types.Header
+	err := ec.c.CallContext(ctx, &head, "selfish_privateHead")
+	return head, err
+}
+
+// PublicHead returns the head of the public chain as seen by the miner.
+func (ec *Client) PublicHead(ctx context.Context) (*types.Header, error) {
+	var head *types.Header
+	err := ec.c.CallContext(ctx, &head, "selfish_publicHead")
+	return head, err
+}
+
+// PrivateBranchLength returns the number of blocks mined on the private branch.
+func (ec *Client) PrivateBranchLength(ctx context.Context) (int, error) {
+	var length int
+	err := ec.c.CallContext(ctx, &length, "selfish_privateBranchLength")
+	return length, err
+}
+
+// NextToPublish returns the number of the first unpublished private block.
+func (ec *Client) NextToPublish(ctx context.Context) (int, error) {
+	var number int
+	err := ec.c.CallContext(ctx, &number, "selfish_nextToPublish")
+	return number, err
+}
+
+// MiningState is the view of the private and public chain the mining strategy
+// bases its decisions on.
+type MiningState struct {
+	PrivateLength       int  `json:"privateLength"`       // Number of the private chain head
+	PublicLength        int  `json:"publicLength"`        // Number of the public chain head
+	PrivateBranchLength int  `json:"privateBranchLength"` // Blocks mined on the private branch since it was last published or adopted
+	NextToPublish       int  `json:"nextToPublish"`       // Number of the first unpublished private block
+	CommonAncestor      int  `json:"commonAncestor"`      // Number of the latest block shared by the private and the public chain
+	TDLead              int  `json:"tdLead"`              // Lead of the private chain in blocks of total difficulty
+	CompareTD           bool `json:"compareTD"`           // Whether the lead is measured by total difficulty instead of length
+}
+
+// MiningStatus returns the view of the private and public chain the mining
+// strategy bases its decisions on.
+func (ec *Client) MiningStatus(ctx context.Context) (*MiningState, error) {
+	var state MiningState
+	if err := ec.c.CallContext(ctx, &state, "selfish_status"); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// UnpublishedBlocks returns the headers of the withheld private blocks.
+func (ec *Client) UnpublishedBlocks(ctx context.Context) ([]*types.Header, error) {
+	var headers []*types.Header
+	err := ec.c.CallContext(ctx, &headers, "selfish_unpublishedBlocks")
+	return headers, err
+}
+
+// BranchesToImport returns the headers of the public branches the private chain
+// imports the next time it adopts the public chain.
+func (ec *Client) BranchesToImport(ctx context.Context) ([][]*types.Header, error) {
+	var branches [][]*types.Header
+	err := ec.c.CallContext(ctx, &branches, "selfish_branchesToImport")
+	return branches, err
+}
+
+// ForkBlock is a block of the fork tree of the public chain.
+type ForkBlock struct {
+	Number    uint64         `json:"number"`
+	Hash      common.Hash    `json:"hash"`
+	Coinbase  common.Address `json:"coinbase"`
+	Td        *hexutil.Big   `json:"td"`
+	FirstSeen time.Time      `json:"firstSeen"` // Zero if the block was loaded from the database
+	Origin    string         `json:"origin"`    // Whether the block was mined locally or received from a peer
+	Peer      string         `json:"peer,omitempty"`
+}
+
+// SideBranch is a branch of the public chain that lost against the branch of
+// the head.
+type SideBranch struct {
+	Ancestor ForkBlock   `json:"ancestor"` // Last block shared with the heaviest branch
+	Blocks   []ForkBlock `json:"blocks"`   // Blocks of the side branch, in ascending order
+}
+
+// SideBranches returns the recent branches of the public chain that lost against
+// the branch of the head.
+func (ec *Client) SideBranches(ctx context.Context) ([]*SideBranch, error) {
+	var branches []*SideBranch
+	err := ec.c.CallContext(ctx, &branches, "selfish_sideBranches")
+	return branches, err
+}
+
+// MiningStrategy returns the name of the mining strategy.
+func (ec *Client) MiningStrategy(ctx context.Context) (string, error) {
+	var name string
+	err := ec.c.CallContext(ctx, &name, "selfish_strategy")
+	return name, err
+}
+
+// SetMiningStrategy switches the mining strategy of the node, publishing the
+// blocks withheld by a selfish strategy if publish is set and discarding them
+// otherwise.
+func (ec *Client) SetMiningStrategy(ctx context.Context, name string, publish bool) error {
+	return ec.c.CallContext(ctx, nil, "miner_setStrategy", name, publish)
+}
+
+// MinerRevenue is the revenue of a miner over a range of blocks.
+type MinerRevenue struct {
+	Coinbase        common.Address `json:"coinbase"`
+	Blocks          uint64         `json:"blocks"`        // Canonical blocks mined
+	Uncles          uint64         `json:"uncles"`        // Blocks mined that were included as uncles
+	Orphans         uint64         `json:"orphans"`       // Blocks mined that are neither canonical nor included as uncles
+	BlockRewards    *hexutil.Big   `json:"blockRewards"`  // Static rewards of the canonical blocks
+	UncleRewards    *hexutil.Big   `json:"uncleRewards"`  // Rewards of the blocks included as uncles
+	NephewRewards   *hexutil.Big   `json:"nephewRewards"` // Rewards for including uncles
+	Fees            *hexutil.Big   `json:"fees"`          // Transaction fees paid to the miner
+	Total           *hexutil.Big   `json:"total"`
+	RelativeRevenue float64        `json:"relativeRevenue"` // Share of the total revenue of all miners
+	BlockShare      float64        `json:"blockShare"`      // Share of the canonical blocks
+	RevenueRate     float64        `json:"revenueRate"`     // Revenue in wei per second of the window
+}
+
+// RevenueWindow is the revenue of the miners over a range of blocks.
+type RevenueWindow struct {
+	From          uint64          `json:"from"`
+	To            uint64          `json:"to"`
+	Blocks        uint64          `json:"blocks"`        // Canonical blocks in the window
+	Orphans       uint64          `json:"orphans"`       // Orphaned blocks in the window
+	Duration      uint64          `json:"duration"`      // Time covered by the window, in seconds
+	Timed         bool            `json:"timed"`         // Whether the duration was measured by first-seen times
+	Difficulty    *hexutil.Big    `json:"difficulty"`    // Mean difficulty of the canonical blocks
+	Total         *hexutil.Big    `json:"total"`         // Revenue of all miners
+	AttackerShare float64         `json:"attackerShare"` // Relative revenue of the attacker
+	AttackerRate  float64         `json:"attackerRate"`  // Revenue of the attacker in wei per second of the window
+	Miners        []*MinerRevenue `json:"miners"`        // Sorted by revenue, highest first
+}
+
+// RevenueReport is the revenue of the miners over the whole block range and
+// optionally over windows of it.
+type RevenueReport struct {
+	RevenueWindow
+	Attacker common.Address   `json:"attacker"`
+	Windows  []*RevenueWindow `json:"windows,omitempty"`
+}
+
+// Revenue returns the revenue of the miners over the canonical blocks from..to,
+// split into windows of the given number of blocks if window is non-zero.
+func (ec *Client) Revenue(ctx context.Context, from, to, window uint64) (*RevenueReport, error) {
+	var report *RevenueReport
+	err := ec.c.CallContext(ctx, &report, "selfish_revenue", from, to, window)
+	return report, err
+}
+
+// BlockProvenance is the first sight of a block by a node.
+type BlockProvenance struct {
+	Number      uint64     `json:"number"`
+	AnnouncedAt *time.Time `json:"announcedAt"`
+	AnnouncedBy string     `json:"announcedBy"`
+	ReceivedAt  *time.Time `json:"receivedAt"`
+	ReceivedBy  string     `json:"receivedBy"`
+	Source      string     `json:"source"`
+}
+
+// BlockProvenance returns when the node first heard of a block and from which
+// peers, or nil if no peer ever announced or delivered it.
+func (ec *Client) BlockProvenance(ctx context.Context, hash common.Hash) (*BlockProvenance, error) {
+	var provenance *BlockProvenance
+	err := ec.c.CallContext(ctx, &provenance, "selfish_blockProvenance", hash)
+	return provenance, err
+}
+
+// EclipsePolicy controls what an eclipsed peer learns from the miner. The modes
+// are withhold, delay or relay, the delays are in the notation of
+// time.ParseDuration.
+type EclipsePolicy struct {
+	Blocks      string `json:"blocks,omitempty"`      // Relay of the blocks of others (default = withhold)
+	BlockDelay  string `json:"blockDelay,omitempty"`  // Delay of the blocks of others in the delay mode
+	Txs         string `json:"txs,omitempty"`         // Transaction gossip (default = relay)
+	TxDelay     string `json:"txDelay,omitempty"`     // Delay of the transactions in the delay mode
+	FilterChain bool   `json:"filterChain,omitempty"` // Whether the chain data served to the peer is restricted
+}
+
+// EclipsedPeer is a peer eclipsed by the miner and its treatment.
+type EclipsedPeer struct {
+	ID     enode.ID      `json:"id"`
+	Enode  string        `json:"enode,omitempty"` // Enode URL of the peer if connected
+	Since  uint64        `json:"since"`           // Head number when the peer was eclipsed
+	Policy EclipsePolicy `json:"policy"`
+}
+
+// EclipsePeers returns the peers eclipsed by the miner and their treatment.
+func (ec *Client) EclipsePeers(ctx context.Context) ([]EclipsedPeer, error) {
+	var peers []EclipsedPeer
+	err := ec.c.CallContext(ctx, &peers, "admin_eclipsePeers")
+	return peers, err
+}
+
+// SetEclipsePolicy eclipses a peer, given by its enode URL or node ID, with the
+// given policy, or changes the policy of a peer that is already eclipsed.
+func (ec *Client) SetEclipsePolicy(ctx context.Context, node string, policy EclipsePolicy) error {
+	return ec.c.CallContext(ctx, nil, "admin_setEclipsePolicy", node, policy)
+}
+
+// AddEclipsePeer eclipses a peer, given by its enode URL or node ID, with the
+// default eclipse policy of the miner. It returns false if the peer is already
+// eclipsed.
+func (ec *Client) AddEclipsePeer(ctx context.Context, node string) (bool, error) {
+	var added bool
+	err := ec.c.CallContext(ctx, &added, "admin_addEclipsePeer", node)
+	return added, err
+}
+
+// RemoveEclipsePeer ends the eclipse of a peer, given by its enode URL or node
+// ID, returning whether it was eclipsed.
+func (ec *Client) RemoveEclipsePeer(ctx context.Context, node string) (bool, error) {
+	var removed bool
+	err := ec.c.CallContext(ctx, &removed, "admin_removeEclipsePeer", node)
+	return removed, err
+}
+
+// Decision is a publish decision of the mining strategy.
+type Decision struct {
+	Strategy string      `json:"strategy"`
+	Action   string      `json:"action"`   // One of adopt, match, override or publish-one
+	OwnBlock bool        `json:"ownBlock"` // Whether the decision follows a block found by the miner
+	State    MiningState `json:"state"`    // State the decision was based on
+}
+
+// SubscribeDecisions subscribes to the publish decisions of the mining strategy.
+func (ec *Client) SubscribeDecisions(ctx context.Context, ch chan<- Decision) (*rpc.ClientSubscription, error) {
+	return ec.c.Subscribe(ctx, "selfish", ch, "decisions")
+}
+
 func toBlockNumArg(number *big.Int) string {
 	if number == nil {
 		return "latest"
@@ -21,6 +21,7 @@ import (
 	"context"
 	"math/big"
 	"testing"
+	"time"
 
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
@@ -32,6 +33,7 @@ import (
 	"github.com/ethereum/go-ethereum/eth"
 	"github.com/ethereum/go-ethereum/eth/ethconfig"
 	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/miner/logic"
 	"github.com/ethereum/go-ethereum/node"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rpc"
@@ -117,6 +119,12 @@ func TestGethClient(t *testing.T) {
 		}, {
 			"TestGetNodeInfo",
 			func(t *testing.T) { testGetNodeInfo(t, client) },
+		}, {
+			"TestMiningStatus",
+			func(t *testing.T) { testMiningStatus(t, client) },
+		}, {
+			"TestRevenue",
+			func(t *testing.T) { testRevenue(t, client) },
 		}, {
 			"TestSetHead",
 			func(t *testing.T) { testSetHead(t, client) },
@@ -241,6 +249,52 @@ func testGetNodeInfo(t *testing.T, client *rpc.Client) {
 	}
 }
 
+func testRevenue(t *testing.T, client *rpc.Client) {
+	ec := New(client)
+	report, err := ec.Revenue(context.Background(), 0, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Blocks != 1 || len(report.Miners) != 1 {
+		t.Fatalf("unexpected report: %d blocks, %d miners", report.Blocks, len(report.Miners))
+	}
+	if report.Miners[0].RelativeRevenue != 1 {
+		t.Fatalf("unexpected relative revenue: %v", report.Miners[0].RelativeRevenue)
+	}
+	if reward := ethash.ConstantinopleBlockReward; report.Total.ToInt().Cmp(reward) != 0 {
+		t.Fatalf("unexpected revenue: have %v, want %v", report.Total.ToInt(), reward)
+	}
+}
+
+func testMiningStatus(t *testing.T, client *rpc.Client) {
+	ec := New(client)
+	strategy, err := ec.MiningStrategy(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strategy != "honest" {
+		t.Fatalf("unexpected strategy: %v", strategy)
+	}
+	head, err := ec.PublicHead(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, err := ec.MiningStatus(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uint64(status.PublicLength) != head.Number.Uint64() {
+		t.Fatalf("public length mismatch: have %d, want %d", status.PublicLength, head.Number)
+	}
+	unpublished, err := ec.UnpublishedBlocks(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(unpublished) != 0 {
+		t.Fatalf("honest miner withholds %d blocks", len(unpublished))
+	}
+}
+
 func testSetHead(t *testing.T, client *rpc.Client) {
 	ec := New(client)
 	err := ec.SetHead(context.Background(), big.NewInt(0))
@@ -306,3 +360,82 @@ func testCallContract(t *testing.T, client *rpc.Client) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 }
+
+// Tests that a subscriber receives the decision of a selfish miner matching a
+// block of the honest network with its withheld block.
+func TestSubscribeDecisions(t *testing.T) {
+	genesis, blocks := generateTestChain()
+
+	n, err := node.New(&node.Config{})
+	if err != nil {
+		t.Fatalf("can't create new node: %v", err)
+	}
+	defer n.Close()
+
+	config := &ethconfig.Config{Genesis: genesis}
+	config.Ethash.PowMode = ethash.ModeFake
+	config.Miner.MinerStrategy = logic.SelfishAllUncles
+	ethservice, err := eth.New(n, config)
+	if err != nil {
+		t.Fatalf("can't create new ethereum service: %v", err)
+	}
+	if err := n.Start(); err != nil {
+		t.Fatalf("can't start test node: %v", err)
+	}
+	client, err := n.Attach()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	// The miner withholds one block, the honest network finds one of the same
+	// height next.
+	var (
+		db     = rawdb.NewMemoryDatabase()
+		gblock = genesis.MustCommit(db)
+		fork   = func(coinbase common.Address) *types.Block {
+			chain, _ := core.GenerateChain(genesis.Config, gblock, ethash.NewFaker(), db, 1, func(i int, g *core.BlockGen) {
+				g.OffsetTime(5)
+				g.SetCoinbase(coinbase)
+			})
+			return chain[0]
+		}
+		private = fork(common.Address{0x01})
+		honest  = fork(common.Address{0x02})
+		data    = ethservice.MiningData()
+	)
+	if gblock.Hash() != blocks[0].Hash() {
+		t.Fatalf("genesis mismatch: have %x, want %x", gblock.Hash(), blocks[0].Hash())
+	}
+	if _, err := data.PrivateChain.InsertChain(types.Blocks{private}); err != nil {
+		t.Fatalf("can't import private block: %v", err)
+	}
+	*data.PrivateBranchLength = 1
+
+	ch := make(chan Decision, 1)
+	sub, err := New(client).SubscribeDecisions(context.Background(), ch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe()
+
+	if _, err := logic.OnOthersFoundBlocks(types.Blocks{honest}, "peer", data); err != nil {
+		t.Fatalf("can't import honest block: %v", err)
+	}
+	select {
+	case decision := <-ch:
+		if decision.Action != "match" || decision.OwnBlock || decision.Strategy != logic.SelfishAllUncles {
+			t.Fatalf("unexpected decision: %+v", decision)
+		}
+		if decision.State.PrivateLength != 1 || decision.State.PublicLength != 1 || decision.State.PrivateBranchLength != 1 {
+			t.Fatalf("unexpected decision state: %+v", decision.State)
+		}
+	case err := <-sub.Err():
+		t.Fatalf("subscription failed: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no decision received")
+	}
+	if block := ethservice.BlockChain().GetBlockByHash(private.Hash()); block == nil {
+		t.Fatal("matching block not published")
+	}
+}
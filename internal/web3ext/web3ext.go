@@ -27,6 +27,7 @@ var Modules = map[string]string{
 	"net":      NetJs,
 	"personal": PersonalJs,
 	"rpc":      RpcJs,
+	"selfish":  SelfishJs,
 	"txpool":   TxpoolJs,
 	"les":      LESJs,
 	"vflux":    VfluxJs,
@@ -141,6 +142,21 @@ web3._extend({
 			call: 'admin_removeTrustedPeer',
 			params: 1
 		}),
+		new web3._extend.Method({
+			name: 'addEclipsePeer',
+			call: 'admin_addEclipsePeer',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'removeEclipsePeer',
+			call: 'admin_removeEclipsePeer',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'setEclipsePolicy',
+			call: 'admin_setEclipsePolicy',
+			params: 2
+		}),
 		new web3._extend.Method({
 			name: 'exportChain',
 			call: 'admin_exportChain',
@@ -199,6 +215,10 @@ web3._extend({
 			name: 'peers',
 			getter: 'admin_peers'
 		}),
+		new web3._extend.Property({
+			name: 'eclipsePeers',
+			getter: 'admin_eclipsePeers'
+		}),
 		new web3._extend.Property({
 			name: 'datadir',
 			getter: 'admin_datadir'
@@ -641,6 +661,11 @@ web3._extend({
 			call: 'miner_setRecommitInterval',
 			params: 1,
 		}),
+		new web3._extend.Method({
+			name: 'setStrategy',
+			call: 'miner_setStrategy',
+			params: 2,
+		}),
 		new web3._extend.Method({
 			name: 'getHashrate',
 			call: 'miner_getHashrate'
@@ -650,6 +675,69 @@ web3._extend({
 });
 `
 
+const SelfishJs = `
+web3._extend({
+	property: 'selfish',
+	methods: [
+		new web3._extend.Method({
+			name: 'unpublishedBlocks',
+			call: 'selfish_unpublishedBlocks'
+		}),
+		new web3._extend.Method({
+			name: 'branchesToImport',
+			call: 'selfish_branchesToImport'
+		}),
+		new web3._extend.Method({
+			name: 'sideBranches',
+			call: 'selfish_sideBranches'
+		}),
+		new web3._extend.Method({
+			name: 'revenue',
+			call: 'selfish_revenue',
+			params: 3,
+			inputFormatter: [null, null, null]
+		}),
+		new web3._extend.Method({
+			name: 'detect',
+			call: 'selfish_detect',
+			params: 2,
+			inputFormatter: [null, null]
+		}),
+		new web3._extend.Method({
+			name: 'getBlockProvenance',
+			call: 'selfish_blockProvenance',
+			params: 1
+		}),
+	],
+	properties: [
+		new web3._extend.Property({
+			name: 'privateHead',
+			getter: 'selfish_privateHead'
+		}),
+		new web3._extend.Property({
+			name: 'publicHead',
+			getter: 'selfish_publicHead'
+		}),
+		new web3._extend.Property({
+			name: 'privateBranchLength',
+			getter: 'selfish_privateBranchLength'
+		}),
+		new web3._extend.Property({
+			name: 'nextToPublish',
+			getter: 'selfish_nextToPublish'
+		}),
+		new web3._extend.Property({
+			name: 'status',
+			getter: 'selfish_status'
+		}),
+		new web3._extend.Property({
+			name: 'strategy',
+			getter: 'selfish_strategy'
+		}),
+	]
+});
+`
+
 const NetJs = `
 web3._extend({
 	property: 'net',
@@ -7,6 +7,7 @@ import (
 	"os"
 	"reflect"
 	"sync"
+	"time"
 
 	"github.com/go-stack/stack"
 )
@@ -70,6 +71,104 @@ func FileHandler(path string, fmtr Format) (Handler, error) {
 	return closingHandler{f, StreamHandler(f, fmtr)}, nil
 }
 
+// RotatingFileHandler returns a handler which writes log records to the given
+// file using the given format, like FileHandler. Once the file reaches the size
+// limit in bytes, it is renamed by appending the time of the rotation and a new
+// file is started. A zero limit disables rotation. The returned handler is an
+// io.Closer closing the file.
+func RotatingFileHandler(path string, limit uint, fmtr Format) (Handler, error) {
+	h := &rotatingHandler{path: path, limit: limit, fmtr: fmtr}
+	if err := h.open(); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+type rotatingHandler struct {
+	path  string
+	limit uint
+	fmtr  Format
+
+	lock sync.Mutex
+	file *os.File // nil if the file couldn't be reopened
+	size uint     // bytes written to the current file since it was opened or failed to rotate
+}
+
+func (h *rotatingHandler) open() error {
+	f, err := os.OpenFile(h.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	stat, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	h.file, h.size = f, uint(stat.Size())
+	return nil
+}
+
+// rotate moves the current file aside and starts a new one. If the file can't
+// be renamed, it is reopened and written on until it grew by the size limit
+// again, when the rotation is retried.
+func (h *rotatingHandler) rotate() error {
+	h.file.Close()
+	h.file = nil
+
+	stamp := h.path + "." + time.Now().Format("2006-01-02T15-04-05.000")
+	name := stamp
+	for i := 1; ; i++ {
+		if _, err := os.Stat(name); os.IsNotExist(err) {
+			break
+		}
+		name = fmt.Sprintf("%s-%d", stamp, i)
+	}
+	renameErr := os.Rename(h.path, name)
+	if err := h.open(); err != nil {
+		return err
+	}
+	if renameErr != nil {
+		h.size = 0
+	}
+	return renameErr
+}
+
+// Log writes the record, rotating the file first if it reached the size limit.
+// A failed rotation doesn't drop the record, its error is returned after the
+// record was written.
+func (h *rotatingHandler) Log(r *Record) error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	var rotateErr error
+	if h.file == nil {
+		if err := h.open(); err != nil {
+			return err
+		}
+	} else if h.limit > 0 && h.size >= h.limit {
+		rotateErr = h.rotate()
+		if h.file == nil {
+			return rotateErr
+		}
+	}
+	n, err := h.file.Write(h.fmtr.Format(r))
+	h.size += uint(n)
+	if err != nil {
+		return err
+	}
+	return rotateErr
+}
+
+func (h *rotatingHandler) Close() error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if h.file == nil {
+		return nil
+	}
+	return h.file.Close()
+}
+
 // NetHandler opens a socket to the given address and writes records
 // over the connection.
 func NetHandler(network, addr string, fmtr Format) (Handler, error) {
@@ -0,0 +1,58 @@
+package log
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Tests that the rotating handler moves full files aside, and that a failed
+// rotation neither drops the record nor retries on every record.
+func TestRotatingFileHandler(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rotating")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "events.log")
+	h, err := RotatingFileHandler(path, 8, LogfmtFormat())
+	if err != nil {
+		t.Fatalf("failed to create handler: %v", err)
+	}
+	defer h.(*rotatingHandler).Close()
+
+	record := func(msg string) *Record {
+		return &Record{Msg: msg, Lvl: LvlInfo, KeyNames: RecordKeyNames{Time: timeKey, Msg: msgKey, Lvl: lvlKey}}
+	}
+	if err := h.Log(record("first")); err != nil {
+		t.Fatalf("failed to log first record: %v", err)
+	}
+	if err := h.Log(record("second")); err != nil {
+		t.Fatalf("failed to log second record: %v", err)
+	}
+	if files, _ := filepath.Glob(path + ".*"); len(files) != 1 {
+		t.Errorf("rotated files mismatch: have %v, want 1", files)
+	}
+	// The file vanishes, so it can't be renamed for the next rotation
+	os.Remove(path)
+	if err := h.Log(record("third")); err == nil {
+		t.Errorf("failed rotation not reported")
+	}
+	blob, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read log file: %v", err)
+	}
+	if !strings.Contains(string(blob), "msg=third") {
+		t.Errorf("record dropped after failed rotation: %q", blob)
+	}
+	// The reopened file rotates once it grew by the limit again
+	if err := h.Log(record("fourth")); err != nil {
+		t.Errorf("failed to rotate reopened file: %v", err)
+	}
+	if files, _ := filepath.Glob(path + ".*"); len(files) != 2 {
+		t.Errorf("rotated files mismatch: have %v, want 2", files)
+	}
+}
@@ -0,0 +1,147 @@
+package logic
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// Modes of comparing the private and the public chain.
+const (
+	CompareLength = "length" // by the number of the head blocks
+	CompareTD     = "td"     // by the total difficulty of the head blocks, like the fork choice of honest nodes
+)
+
+// ComparisonConfig controls how the lead of the private chain over the public
+// chain is measured for the decisions of the strategies.
+//
+// Honest nodes choose their head by total difficulty, so a private branch that
+// is longer than the public one is not adopted if it is lighter. In the td mode
+// the lead is the total difficulty difference in blocks of the difficulty of
+// the public head, rounded away from zero, and matches and overrides publish
+// the private blocks up to the total difficulty they need rather than up to a
+// height. Differences up to the tie threshold count as a tie.
+type ComparisonConfig struct {
+	Mode          string   // Mode of comparing the chains, by length if empty
+	TieDifficulty *big.Int // Total difficulty difference up to which the chains are tied in the td mode
+	TieBlocks     float64  // Tie threshold in blocks of the difficulty of the public head, if TieDifficulty is nil
+}
+
+// Validate checks that the comparison mode is known and the tie threshold is
+// not negative.
+func (c *ComparisonConfig) Validate() error {
+	switch c.Mode {
+	case "", CompareLength, CompareTD:
+	default:
+		return fmt.Errorf("unknown chain comparison %q", c.Mode)
+	}
+	if c.TieBlocks < 0 || (c.TieDifficulty != nil && c.TieDifficulty.Sign() < 0) {
+		return errors.New("negative tie threshold")
+	}
+	return nil
+}
+
+// byTD reports whether the chains are compared by total difficulty.
+func (c *ComparisonConfig) byTD() bool {
+	return c.Mode == CompareTD
+}
+
+// tieThreshold returns the total difficulty difference up to which the chains
+// are tied, given the difficulty of a block.
+func (c *ComparisonConfig) tieThreshold(unit *big.Int) *big.Int {
+	if c.TieDifficulty != nil {
+		return c.TieDifficulty
+	}
+	threshold, _ := new(big.Float).Mul(new(big.Float).SetInt(unit), big.NewFloat(c.TieBlocks)).Int(nil)
+	return threshold
+}
+
+// tdLead returns how many blocks of total difficulty the private chain is ahead
+// of the public chain, see ComparisonConfig.
+func (data *MiningData) tdLead() int {
+	var (
+		private = data.PrivateChain.CurrentBlock()
+		public  = data.PublicChain.CurrentBlock()
+		unit    = public.Difficulty()
+	)
+	privateTd := data.PrivateChain.GetTd(private.Hash(), private.NumberU64())
+	publicTd := data.PublicChain.GetTd(public.Hash(), public.NumberU64())
+	if privateTd == nil || publicTd == nil || unit.Sign() == 0 {
+		return data.PrivateChain.Length() - data.PublicChain.Length()
+	}
+	diff := new(big.Int).Sub(privateTd, publicTd)
+	if new(big.Int).Abs(diff).Cmp(data.Comparison.tieThreshold(unit)) <= 0 {
+		return 0
+	}
+	// Round away from zero: any excess decides the race for honest nodes
+	blocks, rem := new(big.Int).QuoRem(diff, unit, new(big.Int))
+	lead := int(blocks.Int64())
+	switch {
+	case rem.Sign() > 0:
+		lead++
+	case rem.Sign() < 0:
+		lead--
+	}
+	return lead
+}
+
+// publishTarget returns the number of the private block up to which a match or
+// an override publishes the private chain. By length, a match publishes up to
+// the height of the public head and an override one block further. By total
+// difficulty, they publish up to the first private block that ties with the
+// public head, respectively outweighs it beyond the tie threshold.
+func (data *MiningData) publishTarget(action Action) int {
+	number := data.PublicChain.Length()
+	if !data.Comparison.byTD() {
+		if action == Override {
+			number++
+		}
+		return number
+	}
+	head := data.PublicChain.CurrentBlock()
+	publicTd := data.PublicChain.GetTd(head.Hash(), head.NumberU64())
+	if publicTd == nil {
+		return data.PrivateChain.Length()
+	}
+	threshold := data.Comparison.tieThreshold(head.Difficulty())
+	for n := *data.NextToPublish; n <= data.PrivateChain.Length(); n++ {
+		td := data.PrivateChain.GetTd(data.PrivateChain.GetCanonicalHash(uint64(n)), uint64(n))
+		if td == nil {
+			break
+		}
+		diff := new(big.Int).Sub(td, publicTd)
+		if action == Match && new(big.Int).Neg(diff).Cmp(threshold) <= 0 {
+			return n
+		}
+		if action == Override && diff.Cmp(threshold) > 0 {
+			return n
+		}
+	}
+	return data.PrivateChain.Length()
+}
+
+// checkDivergence reports if the strategy would have decided differently on the
+// given block had the chains been compared the other way, by length instead of
+// total difficulty or vice versa.
+func (data *MiningData) checkDivergence(state State, action Action, decide func(State) Action, block *types.Block) {
+	other := state
+	other.CompareTD = !state.CompareTD
+	alternative := decide(other)
+	if alternative == action {
+		return
+	}
+	divergenceCounter.Inc(1)
+
+	byLength, byTD := action, alternative
+	if state.CompareTD {
+		byLength, byTD = alternative, action
+	}
+	log.Debug("Length and total difficulty decisions diverge", "number", block.Number(), "hash", block.Hash(), "length", byLength, "td", byTD)
+	if data.EventLog != nil {
+		data.EventLog.Info(EventDivergence, "number", block.NumberU64(), "hash", block.Hash(),
+			"length", byLength.String(), "td", byTD.String(), "lengthLead", state.LengthLead(), "tdLead", state.TDLead)
+	}
+}
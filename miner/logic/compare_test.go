@@ -0,0 +1,98 @@
+package logic
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Tests that a selfish miner comparing by total difficulty abandons a private
+// block of the same height as a public one but of lower difficulty, instead of
+// racing with it, and that the diverging decisions are reported.
+func TestCompareTD(t *testing.T) {
+	var (
+		gspec   = &core.Genesis{Config: params.TestChainConfig, Difficulty: big.NewInt(10000000)}
+		genesis = gspec.MustCommit(rawdb.NewMemoryDatabase())
+		gendb   = rawdb.NewMemoryDatabase()
+	)
+	gspec.MustCommit(gendb)
+
+	// The private block took long to find, which lowers its difficulty
+	private, _ := core.GenerateChain(gspec.Config, genesis, ethash.NewFaker(), gendb, 1, func(i int, b *core.BlockGen) {
+		b.SetCoinbase(common.Address{0x01})
+		b.OffsetTime(100)
+	})
+	public, _ := core.GenerateChain(gspec.Config, genesis, ethash.NewFaker(), gendb, 1, func(i int, b *core.BlockGen) {
+		b.SetCoinbase(common.Address{0x02})
+	})
+	tests := []struct {
+		comparison ComparisonConfig
+		action     Action
+		diverge    bool
+	}{
+		{ComparisonConfig{}, Match, true},
+		{ComparisonConfig{Mode: CompareTD}, Adopt, true},
+		{ComparisonConfig{Mode: CompareTD, TieBlocks: 1}, Match, false},
+	}
+	for i, tt := range tests {
+		privateChain, privateDb := newTestChain(t, gspec, private)
+		publicChain, _ := newTestChain(t, gspec)
+		defer privateChain.Stop()
+		defer publicChain.Stop()
+
+		forks, err := publicChain.NewForkTree(0)
+		if err != nil {
+			t.Fatalf("failed to create fork tree: %v", err)
+		}
+		var divergences []*log.Record
+		eventLog := log.New()
+		eventLog.SetHandler(log.FuncHandler(func(r *log.Record) error {
+			if r.Msg == EventDivergence {
+				divergences = append(divergences, r)
+			}
+			return nil
+		}))
+		selfish, _ := New(SelfishAllUncles, nil)
+		branchLength, next := 1, 1
+		data := &MiningData{
+			PublicChain:         publicChain,
+			PrivateChain:        privateChain,
+			PrivateBranchLength: &branchLength,
+			NextToPublish:       &next,
+			MinerStrategy:       selfish,
+			Comparison:          tt.comparison,
+			EventMux:            new(event.TypeMux),
+			EventLog:            eventLog,
+			PrivateChainDb:      privateDb,
+			ForkTree:            forks,
+		}
+		decisions := make(chan Decision, 1)
+		sub := data.SubscribeDecisions(decisions)
+		defer sub.Unsubscribe()
+
+		if _, err := OnOthersFoundBlocks(public, "", data); err != nil {
+			t.Fatalf("test %d: failed to insert public block: %v", i, err)
+		}
+		select {
+		case decision := <-decisions:
+			if decision.Action != tt.action {
+				t.Errorf("test %d: action mismatch: have %v, want %v", i, decision.Action, tt.action)
+			}
+		default:
+			t.Errorf("test %d: no decision", i)
+		}
+		if (len(divergences) > 0) != tt.diverge {
+			t.Errorf("test %d: divergence mismatch: have %d, want %t", i, len(divergences), tt.diverge)
+		}
+		if published := publicChain.HasBlock(private[0].Hash(), 1); published != (tt.action == Match) {
+			t.Errorf("test %d: private block published %t", i, published)
+		}
+	}
+}
@@ -0,0 +1,285 @@
+package logic
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+// DetectConfig holds the thresholds above which the selfish mining detector
+// flags a coinbase.
+type DetectConfig struct {
+	BurstWindow   time.Duration // Blocks first seen within this time after their parent of the same coinbase were released together
+	BurstRatio    float64       // Ratio of released pairs to the pairs expected by chance above which a coinbase is flagged
+	MinBursts     uint64        // Released pairs a coinbase must have before its bursts are judged
+	LateDelay     time.Duration // Blocks first seen this long after their timestamp were withheld
+	LateShare     float64       // Share of late blocks above which a coinbase is flagged
+	MinRaces      uint64        // Races a coinbase must have taken part in before its win rate is judged
+	RaceWinRate   float64       // Share of won races above which a coinbase is flagged
+	MinStale      uint64        // Stale blocks the other miners must have before the fork rates are compared
+	ForkRateRatio float64       // Ratio to the fork rate of the other miners below which a coinbase is flagged
+}
+
+// DefaultDetectConfig contains the default thresholds of the detector.
+var DefaultDetectConfig = DetectConfig{
+	BurstWindow:   500 * time.Millisecond,
+	BurstRatio:    3,
+	MinBursts:     3,
+	LateDelay:     5 * time.Second,
+	LateShare:     0.1,
+	MinRaces:      3,
+	RaceWinRate:   0.8,
+	MinStale:      3,
+	ForkRateRatio: 0.5,
+}
+
+// MinerSignals are the signals of selfish mining observed for a coinbase.
+type MinerSignals struct {
+	Coinbase common.Address `json:"coinbase"`
+	Blocks   uint64         `json:"blocks"`   // canonical blocks mined
+	Uncles   uint64         `json:"uncles"`   // blocks mined that were included as uncles
+	Orphans  uint64         `json:"orphans"`  // blocks mined that are neither canonical nor included as uncles
+	ForkRate float64        `json:"forkRate"` // share of the blocks mined that are not canonical
+
+	LongestRun  uint64  `json:"longestRun"`  // longest run of consecutive canonical blocks
+	ExpectedRun float64 `json:"expectedRun"` // longest run expected from the block share of the coinbase
+
+	Races    uint64 `json:"races"`    // heights at which a block of the coinbase competed with others
+	RacesWon uint64 `json:"racesWon"` // races the block of the coinbase became canonical in
+
+	Timed          uint64  `json:"timed"`          // blocks whose first-seen time is known
+	LateBlocks     uint64  `json:"lateBlocks"`     // blocks first seen long after their timestamp
+	Pairs          uint64  `json:"pairs"`          // timed blocks whose timed parent has the same coinbase
+	BurstBlocks    uint64  `json:"burstBlocks"`    // blocks first seen right after their parent of the same coinbase
+	ExpectedBursts float64 `json:"expectedBursts"` // bursts expected by chance from exponential block times
+	MeanDelay      float64 `json:"meanDelay"`      // mean time between the timestamp and the first sight of a block, in seconds
+
+	Suspicious bool     `json:"suspicious"`
+	Reasons    []string `json:"reasons,omitempty"`
+
+	delay time.Duration
+}
+
+// DetectionReport is the outcome of the selfish mining detector over a range of
+// blocks.
+type DetectionReport struct {
+	From     uint64          `json:"from"`
+	To       uint64          `json:"to"`
+	Blocks   uint64          `json:"blocks"`   // canonical blocks in the range
+	Stale    uint64          `json:"stale"`    // non-canonical blocks in the range
+	Races    uint64          `json:"races"`    // heights with competing blocks
+	ForkRate float64         `json:"forkRate"` // share of the blocks in the range that are not canonical
+	Interval float64         `json:"interval"` // mean time between the canonical blocks, in seconds
+	Timed    bool            `json:"timed"`    // whether first-seen times were available
+	Miners   []*MinerSignals `json:"miners"`   // suspicious coinbases first, then by canonical blocks
+}
+
+// Detect looks for signals of selfish mining in the blocks from..to of the
+// chain, including the side branches found in the database. The first-seen
+// times of the blocks are looked up with firstSeen if not nil, which enables the
+// signals of withheld and bursty releases.
+func Detect(chain *core.BlockChain, db ethdb.Iteratee, from, to uint64, firstSeen func(common.Hash) (time.Time, bool), config DetectConfig) (*DetectionReport, error) {
+	head := chain.CurrentBlock().NumberU64()
+	if to > head {
+		to = head
+	}
+	if from == 0 {
+		from = 1 // the genesis block has no miner
+	}
+	if from > to {
+		return nil, errors.New("empty block range")
+	}
+	var (
+		report   = &DetectionReport{From: from, To: to, Timed: firstSeen != nil}
+		miners   = make(map[common.Address]*MinerSignals)
+		included = make(map[common.Hash]bool)
+	)
+	miner := func(coinbase common.Address) *MinerSignals {
+		signals, ok := miners[coinbase]
+		if !ok {
+			signals = &MinerSignals{Coinbase: coinbase}
+			miners[coinbase] = signals
+		}
+		return signals
+	}
+	// Canonical blocks and runs of blocks of the same coinbase
+	var (
+		last common.Address
+		run  uint64
+	)
+	for number := from; number <= to; number++ {
+		block := chain.GetBlockByNumber(number)
+		if block == nil {
+			return nil, errors.New("missing canonical block")
+		}
+		report.Blocks++
+		signals := miner(block.Coinbase())
+		signals.Blocks++
+
+		if number > from && block.Coinbase() == last {
+			run++
+		} else {
+			run = 1
+		}
+		last = block.Coinbase()
+		if run > signals.LongestRun {
+			signals.LongestRun = run
+		}
+		for _, uncle := range block.Uncles() {
+			included[uncle.Hash()] = true
+		}
+	}
+	// Blocks near the end of the range may still be included by later blocks
+	for number := to + 1; number <= to+maxUncleDepth && number <= head; number++ {
+		if block := chain.GetBlockByNumber(number); block != nil {
+			for _, uncle := range block.Uncles() {
+				included[uncle.Hash()] = true
+			}
+		}
+	}
+	// Stale blocks and first-seen times of all blocks in the range
+	type contender struct {
+		coinbase  common.Address
+		canonical bool
+	}
+	heights := make(map[uint64][]contender)
+	for _, entry := range rawdb.ReadAllHashesInRange(db, from, to) {
+		header := chain.GetHeader(entry.Hash, entry.Number)
+		if header == nil {
+			continue
+		}
+		signals := miner(header.Coinbase)
+		canonical := chain.GetCanonicalHash(entry.Number) == entry.Hash
+		if !canonical {
+			report.Stale++
+			if included[entry.Hash] {
+				signals.Uncles++
+			} else {
+				signals.Orphans++
+			}
+		}
+		heights[entry.Number] = append(heights[entry.Number], contender{header.Coinbase, canonical})
+
+		if firstSeen == nil {
+			continue
+		}
+		seen, ok := firstSeen(entry.Hash)
+		if !ok {
+			continue
+		}
+		signals.Timed++
+		delay := seen.Sub(time.Unix(int64(header.Time), 0))
+		signals.delay += delay
+		if delay > config.LateDelay {
+			signals.LateBlocks++
+		}
+		// Consecutive blocks of a miner arrive a block interval apart, unless
+		// they were withheld and released together
+		parent := chain.GetHeader(header.ParentHash, entry.Number-1)
+		if parent == nil || parent.Coinbase != header.Coinbase {
+			continue
+		}
+		parentSeen, ok := firstSeen(parent.Hash())
+		if !ok {
+			continue
+		}
+		signals.Pairs++
+		if seen.Sub(parentSeen) < config.BurstWindow {
+			signals.BurstBlocks++
+		}
+	}
+	// The mean block interval, which the chance of a burst follows from. A single
+	// miner can't shift it much by choosing its timestamps.
+	if to > from {
+		first, last := chain.GetHeaderByNumber(from), chain.GetHeaderByNumber(to)
+		if last.Time > first.Time {
+			report.Interval = float64(last.Time-first.Time) / float64(to-from)
+		}
+	}
+	// Races are the heights with competing blocks, won by the canonical one
+	for _, contenders := range heights {
+		if len(contenders) < 2 {
+			continue
+		}
+		report.Races++
+		for _, c := range contenders {
+			signals := miner(c.coinbase)
+			signals.Races++
+			if c.canonical {
+				signals.RacesWon++
+			}
+		}
+	}
+	report.finalize(miners, config)
+	return report, nil
+}
+
+// finalize computes the rates of the miners and flags the suspicious ones.
+func (r *DetectionReport) finalize(miners map[common.Address]*MinerSignals, config DetectConfig) {
+	if total := r.Blocks + r.Stale; total > 0 {
+		r.ForkRate = float64(r.Stale) / float64(total)
+	}
+	r.Miners = make([]*MinerSignals, 0, len(miners))
+	for _, signals := range miners {
+		mined := signals.Blocks + signals.Uncles + signals.Orphans
+		stale := signals.Uncles + signals.Orphans
+		if mined > 0 {
+			signals.ForkRate = float64(stale) / float64(mined)
+		}
+		if signals.Timed > 0 {
+			signals.MeanDelay = (signals.delay / time.Duration(signals.Timed)).Seconds()
+		}
+		// The longest run of a miner with block share p among n blocks is about
+		// log(n(1-p)) / log(1/p) blocks long
+		if share := float64(signals.Blocks) / float64(r.Blocks); share > 0 && share < 1 {
+			signals.ExpectedRun = math.Max(1, math.Log(float64(r.Blocks)*(1-share))/math.Log(1/share))
+		}
+		if signals.Races >= config.MinRaces && float64(signals.RacesWon) > config.RaceWinRate*float64(signals.Races) {
+			signals.Reasons = append(signals.Reasons, fmt.Sprintf("won %d of %d races", signals.RacesWon, signals.Races))
+		}
+		if others, otherStale := r.Blocks+r.Stale-mined, r.Stale-stale; otherStale >= config.MinStale && others > 0 {
+			if otherRate := float64(otherStale) / float64(others); signals.ForkRate < config.ForkRateRatio*otherRate {
+				signals.Reasons = append(signals.Reasons, fmt.Sprintf("fork rate %.3f while the other miners have %.3f", signals.ForkRate, otherRate))
+			}
+		}
+		if signals.LongestRun >= 3 && float64(signals.LongestRun) > 2*signals.ExpectedRun && signals.ExpectedRun > 0 {
+			signals.Reasons = append(signals.Reasons, fmt.Sprintf("run of %d consecutive blocks, %.1f expected", signals.LongestRun, signals.ExpectedRun))
+		}
+		if signals.Timed > 0 && float64(signals.LateBlocks) > config.LateShare*float64(signals.Timed) {
+			signals.Reasons = append(signals.Reasons, fmt.Sprintf("%d of %d blocks first seen more than %v after their timestamp", signals.LateBlocks, signals.Timed, config.LateDelay))
+		}
+		// Block times are exponential, so a block follows its parent within the
+		// window with probability 1 - exp(-window/interval) even if nothing was
+		// withheld. Large miners have many such pairs by chance.
+		if signals.Pairs > 0 {
+			chance := 1.0
+			if r.Interval > 0 {
+				chance = -math.Expm1(-config.BurstWindow.Seconds() / r.Interval)
+			}
+			signals.ExpectedBursts = chance * float64(signals.Pairs)
+		}
+		if signals.BurstBlocks >= config.MinBursts && float64(signals.BurstBlocks) > config.BurstRatio*signals.ExpectedBursts {
+			signals.Reasons = append(signals.Reasons, fmt.Sprintf("%d of %d blocks released within %v of their parent, %.1f expected", signals.BurstBlocks, signals.Pairs, config.BurstWindow, signals.ExpectedBursts))
+		}
+		signals.Suspicious = len(signals.Reasons) > 0
+		r.Miners = append(r.Miners, signals)
+	}
+	sort.Slice(r.Miners, func(i, j int) bool {
+		a, b := r.Miners[i], r.Miners[j]
+		if a.Suspicious != b.Suspicious {
+			return a.Suspicious
+		}
+		if a.Blocks != b.Blocks {
+			return a.Blocks > b.Blocks
+		}
+		return bytes.Compare(a.Coinbase[:], b.Coinbase[:]) < 0
+	})
+}
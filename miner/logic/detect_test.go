@@ -0,0 +1,149 @@
+package logic
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Tests that a coinbase which wins all races, forks less than the others and
+// releases its blocks late and in bursts is flagged, and the others are not.
+func TestDetect(t *testing.T) {
+	var (
+		gspec   = &core.Genesis{Config: params.TestChainConfig}
+		genesis = gspec.MustCommit(rawdb.NewMemoryDatabase())
+		gendb   = rawdb.NewMemoryDatabase()
+
+		attacker = common.Address{0x01}
+		honest   = common.Address{0x02}
+	)
+	gspec.MustCommit(gendb)
+
+	coinbases := []common.Address{attacker, attacker, honest, attacker, attacker, honest}
+	canonical, _ := core.GenerateChain(gspec.Config, genesis, ethash.NewFaker(), gendb, len(coinbases), func(i int, b *core.BlockGen) {
+		b.SetCoinbase(coinbases[i])
+	})
+	// The honest blocks at heights 1 and 4 lost their races
+	lost1, _ := core.GenerateChain(gspec.Config, genesis, ethash.NewFaker(), gendb, 1, func(i int, b *core.BlockGen) {
+		b.SetCoinbase(honest)
+	})
+	lost4, _ := core.GenerateChain(gspec.Config, canonical[2], ethash.NewFaker(), gendb, 1, func(i int, b *core.BlockGen) {
+		b.SetCoinbase(honest)
+	})
+	chain, db := newTestChain(t, gspec, canonical, lost1, lost4)
+	defer chain.Stop()
+
+	// Blocks 4 and 5 were withheld and released together
+	seen := make(map[common.Hash]time.Time)
+	for _, block := range canonical {
+		seen[block.Hash()] = time.Unix(int64(block.Time()), 0).Add(100 * time.Millisecond)
+	}
+	release := time.Unix(int64(canonical[4].Time()), 0).Add(time.Minute)
+	seen[canonical[3].Hash()] = release
+	seen[canonical[4].Hash()] = release.Add(10 * time.Millisecond)
+
+	firstSeen := func(hash common.Hash) (time.Time, bool) {
+		t, ok := seen[hash]
+		return t, ok
+	}
+	config := DefaultDetectConfig
+	config.MinRaces = 2
+	config.MinStale = 2
+	config.MinBursts = 1
+
+	report, err := Detect(chain, db, 0, 100, firstSeen, config)
+	if err != nil {
+		t.Fatalf("detection failed: %v", err)
+	}
+	if report.From != 1 || report.To != 6 || report.Blocks != 6 || report.Stale != 2 || report.Races != 2 {
+		t.Errorf("report mismatch: from %d, to %d, blocks %d, stale %d, races %d", report.From, report.To, report.Blocks, report.Stale, report.Races)
+	}
+	if len(report.Miners) != 2 {
+		t.Fatalf("miner count mismatch: have %d, want 2", len(report.Miners))
+	}
+	suspect, victim := report.Miners[0], report.Miners[1]
+	if suspect.Coinbase != attacker || !suspect.Suspicious {
+		t.Fatalf("attacker not flagged: %+v", suspect)
+	}
+	if suspect.RacesWon != 2 || suspect.Races != 2 || suspect.ForkRate != 0 {
+		t.Errorf("attacker races mismatch: won %d of %d, fork rate %f", suspect.RacesWon, suspect.Races, suspect.ForkRate)
+	}
+	if suspect.LateBlocks != 2 || suspect.BurstBlocks != 1 || suspect.Timed != 4 {
+		t.Errorf("attacker arrivals mismatch: late %d, burst %d, timed %d", suspect.LateBlocks, suspect.BurstBlocks, suspect.Timed)
+	}
+	if len(suspect.Reasons) != 4 {
+		t.Errorf("attacker reasons mismatch: %q", suspect.Reasons)
+	}
+	if victim.Suspicious || victim.Orphans != 2 || victim.ForkRate != 0.5 {
+		t.Errorf("honest miner mismatch: %+v", victim)
+	}
+}
+
+// Tests that a large miner is only flagged for its bursts if it has more of them
+// than exponential block times explain.
+func TestDetectBursts(t *testing.T) {
+	var (
+		gspec   = &core.Genesis{Config: params.TestChainConfig}
+		genesis = gspec.MustCommit(rawdb.NewMemoryDatabase())
+		gendb   = rawdb.NewMemoryDatabase()
+
+		large = common.Address{0x01}
+		small = common.Address{0x02}
+	)
+	gspec.MustCommit(gendb)
+
+	// The large miner has three in four blocks, 30 of which follow its own
+	blocks, _ := core.GenerateChain(gspec.Config, genesis, ethash.NewFaker(), gendb, 60, func(i int, b *core.BlockGen) {
+		if i%4 == 3 {
+			b.SetCoinbase(small)
+		} else {
+			b.SetCoinbase(large)
+		}
+	})
+	chain, db := newTestChain(t, gspec, blocks)
+	defer chain.Stop()
+
+	for _, tt := range []struct {
+		bursts     int
+		suspicious bool
+	}{
+		{2, false}, // about 1.5 expected from 10s block times and a 500ms window
+		{8, true},
+	} {
+		seen := make(map[common.Hash]time.Time)
+		for _, block := range blocks {
+			seen[block.Hash()] = time.Unix(int64(block.Time()), 0).Add(100 * time.Millisecond)
+		}
+		for i, bursts := 1, 0; i < len(blocks) && bursts < tt.bursts; i++ {
+			if blocks[i].Coinbase() == large && blocks[i-1].Coinbase() == large {
+				seen[blocks[i].Hash()] = seen[blocks[i-1].Hash()].Add(200 * time.Millisecond)
+				bursts++
+			}
+		}
+		firstSeen := func(hash common.Hash) (time.Time, bool) {
+			t, ok := seen[hash]
+			return t, ok
+		}
+		report, err := Detect(chain, db, 0, 100, firstSeen, DefaultDetectConfig)
+		if err != nil {
+			t.Fatalf("detection failed: %v", err)
+		}
+		var signals *MinerSignals
+		for _, miner := range report.Miners {
+			if miner.Coinbase == large {
+				signals = miner
+			}
+		}
+		if signals.Pairs != 30 || signals.BurstBlocks != uint64(tt.bursts) {
+			t.Errorf("%d bursts: pairs %d, bursts %d", tt.bursts, signals.Pairs, signals.BurstBlocks)
+		}
+		if signals.Suspicious != tt.suspicious {
+			t.Errorf("%d bursts: suspicious mismatch: have %t, want %t (%q, %.2f expected)", tt.bursts, signals.Suspicious, tt.suspicious, signals.Reasons, signals.ExpectedBursts)
+		}
+	}
+}
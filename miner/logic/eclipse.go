@@ -0,0 +1,216 @@
+package logic
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+// Modes of passing data on to an eclipsed peer.
+const (
+	EclipseRelay    = "relay"    // like to any other peer
+	EclipseDelay    = "delay"    // after the delay of the policy
+	EclipseWithhold = "withhold" // never
+)
+
+// EclipsePolicy controls what an eclipsed peer learns from this node. The blocks
+// mined or published by this node always reach the eclipsed peers, the policy
+// applies to the blocks of others this node would relay, to the transaction
+// gossip and to the chain data the peers request.
+type EclipsePolicy struct {
+	Blocks     string        // Relay of the blocks of others: withhold, delay or relay (default = withhold)
+	BlockDelay time.Duration // Delay of the blocks of others in the delay mode
+	Txs        string        // Transaction gossip: withhold, delay or relay (default = relay)
+	TxDelay    time.Duration // Delay of the transactions in the delay mode
+
+	// FilterChain restricts the headers, bodies and receipts served to the
+	// peer to the blocks it already knows, the blocks this node published and
+	// the chain as it was when the peer was eclipsed. The peer can't sync the
+	// withheld blocks of others from this node then.
+	FilterChain bool
+}
+
+// Validate checks that the modes are known and the delays are set where needed.
+func (p *EclipsePolicy) Validate() error {
+	for _, check := range []struct {
+		kind  string
+		mode  string
+		delay time.Duration
+	}{
+		{"block", p.Blocks, p.BlockDelay},
+		{"transaction", p.Txs, p.TxDelay},
+	} {
+		switch check.mode {
+		case "", EclipseRelay, EclipseWithhold:
+		case EclipseDelay:
+			if check.delay <= 0 {
+				return fmt.Errorf("%s delay mode requires a positive delay", check.kind)
+			}
+		default:
+			return fmt.Errorf("unknown %s eclipse mode %q", check.kind, check.mode)
+		}
+		if check.delay < 0 {
+			return errors.New("negative eclipse delay")
+		}
+	}
+	return nil
+}
+
+// BlockMode returns the mode of relaying the blocks of others.
+func (p *EclipsePolicy) BlockMode() string {
+	if p.Blocks == "" {
+		return EclipseWithhold
+	}
+	return p.Blocks
+}
+
+// TxMode returns the mode of the transaction gossip.
+func (p *EclipsePolicy) TxMode() string {
+	if p.Txs == "" {
+		return EclipseRelay
+	}
+	return p.Txs
+}
+
+// eclipsePolicyJSON is the JSON form of an eclipse policy, with the delays in
+// the notation of time.ParseDuration.
+type eclipsePolicyJSON struct {
+	Blocks      string `json:"blocks,omitempty"`
+	BlockDelay  string `json:"blockDelay,omitempty"`
+	Txs         string `json:"txs,omitempty"`
+	TxDelay     string `json:"txDelay,omitempty"`
+	FilterChain bool   `json:"filterChain,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler.
+func (p EclipsePolicy) MarshalJSON() ([]byte, error) {
+	enc := eclipsePolicyJSON{Blocks: p.BlockMode(), Txs: p.TxMode(), FilterChain: p.FilterChain}
+	if p.BlockDelay != 0 {
+		enc.BlockDelay = p.BlockDelay.String()
+	}
+	if p.TxDelay != 0 {
+		enc.TxDelay = p.TxDelay.String()
+	}
+	return json.Marshal(enc)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (p *EclipsePolicy) UnmarshalJSON(input []byte) error {
+	var dec eclipsePolicyJSON
+	if err := json.Unmarshal(input, &dec); err != nil {
+		return err
+	}
+	policy := EclipsePolicy{Blocks: dec.Blocks, Txs: dec.Txs, FilterChain: dec.FilterChain}
+	var err error
+	if dec.BlockDelay != "" {
+		if policy.BlockDelay, err = time.ParseDuration(dec.BlockDelay); err != nil {
+			return fmt.Errorf("invalid block delay: %v", err)
+		}
+	}
+	if dec.TxDelay != "" {
+		if policy.TxDelay, err = time.ParseDuration(dec.TxDelay); err != nil {
+			return fmt.Errorf("invalid transaction delay: %v", err)
+		}
+	}
+	*p = policy
+	return nil
+}
+
+// eclipseEntry is the treatment of an eclipsed peer.
+type eclipseEntry struct {
+	policy EclipsePolicy
+	since  uint64 // Head number when the peer was eclipsed, the chain up to it is served in full
+}
+
+// EclipseSet is the set of eclipsed peers. Peers are keyed by node ID so that a
+// peer is recognized whichever address it advertises or connects from. The set
+// is safe for concurrent use, it changes at runtime while blocks and
+// transactions are being broadcast.
+type EclipseSet struct {
+	peers map[enode.ID]*eclipseEntry
+	lock  sync.RWMutex
+}
+
+// NewEclipseSet creates an empty set of eclipsed peers.
+func NewEclipseSet() *EclipseSet {
+	return &EclipseSet{peers: make(map[enode.ID]*eclipseEntry)}
+}
+
+// Add eclipses a peer with the given policy, returning false if the peer is
+// already eclipsed, in which case its policy is left unchanged. The head is the
+// number of the current head block.
+func (s *EclipseSet) Add(id enode.ID, policy EclipsePolicy, head uint64) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, ok := s.peers[id]; ok {
+		return false
+	}
+	s.peers[id] = &eclipseEntry{policy: policy, since: head}
+	return true
+}
+
+// Set eclipses a peer with the given policy, or changes the policy of a peer
+// that is already eclipsed.
+func (s *EclipseSet) Set(id enode.ID, policy EclipsePolicy, head uint64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if peer, ok := s.peers[id]; ok {
+		peer.policy = policy
+		return
+	}
+	s.peers[id] = &eclipseEntry{policy: policy, since: head}
+}
+
+// Remove ends the eclipse of a peer, returning whether it was eclipsed.
+func (s *EclipseSet) Remove(id enode.ID) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	_, ok := s.peers[id]
+	delete(s.peers, id)
+	return ok
+}
+
+// Get returns the policy of a peer and the head number when it was eclipsed,
+// and false if the peer isn't eclipsed.
+func (s *EclipseSet) Get(id enode.ID) (EclipsePolicy, uint64, bool) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if peer, ok := s.peers[id]; ok {
+		return peer.policy, peer.since, true
+	}
+	return EclipsePolicy{}, 0, false
+}
+
+// Contains reports whether a peer is eclipsed.
+func (s *EclipseSet) Contains(id enode.ID) bool {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	_, ok := s.peers[id]
+	return ok
+}
+
+// IDs returns the IDs of the eclipsed peers in ascending order.
+func (s *EclipseSet) IDs() []enode.ID {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	ids := make([]enode.ID, 0, len(s.peers))
+	for id := range s.peers {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return bytes.Compare(ids[i][:], ids[j][:]) < 0
+	})
+	return ids
+}
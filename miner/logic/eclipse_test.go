@@ -0,0 +1,115 @@
+package logic
+
+import (
+	"encoding/json"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+// Tests that eclipse policies with unknown modes or missing delays are rejected.
+func TestEclipsePolicyValidate(t *testing.T) {
+	tests := []struct {
+		policy EclipsePolicy
+		valid  bool
+	}{
+		{EclipsePolicy{}, true},
+		{EclipsePolicy{Blocks: EclipseRelay, Txs: EclipseWithhold}, true},
+		{EclipsePolicy{Blocks: EclipseDelay, BlockDelay: time.Second}, true},
+		{EclipsePolicy{Blocks: EclipseDelay}, false},
+		{EclipsePolicy{Txs: EclipseDelay}, false},
+		{EclipsePolicy{Txs: EclipseRelay, TxDelay: -time.Second}, false},
+		{EclipsePolicy{Blocks: "drop"}, false},
+	}
+	for i, tt := range tests {
+		if err := tt.policy.Validate(); (err == nil) != tt.valid {
+			t.Errorf("test %d: validity mismatch: have %v, want valid %v", i, err, tt.valid)
+		}
+	}
+}
+
+// Tests that eclipse policies survive a JSON round trip, with the default modes
+// filled in and the delays written as durations.
+func TestEclipsePolicyJSON(t *testing.T) {
+	policy := EclipsePolicy{Txs: EclipseDelay, TxDelay: 1500 * time.Millisecond, FilterChain: true}
+	blob, err := json.Marshal(policy)
+	if err != nil {
+		t.Fatalf("failed to encode policy: %v", err)
+	}
+	if want := `{"blocks":"withhold","txs":"delay","txDelay":"1.5s","filterChain":true}`; string(blob) != want {
+		t.Errorf("encoding mismatch: have %s, want %s", blob, want)
+	}
+	var decoded EclipsePolicy
+	if err := json.Unmarshal(blob, &decoded); err != nil {
+		t.Fatalf("failed to decode policy: %v", err)
+	}
+	policy.Blocks = EclipseWithhold
+	if decoded != policy {
+		t.Errorf("decoded policy mismatch: have %+v, want %+v", decoded, policy)
+	}
+	if err := json.Unmarshal([]byte(`{"blockDelay":"soon"}`), &decoded); err == nil {
+		t.Errorf("invalid delay accepted")
+	}
+}
+
+// Tests that peers are added to and removed from the eclipse set by node ID, and
+// that adding keeps the policy of an eclipsed peer while setting changes it.
+func TestEclipseSet(t *testing.T) {
+	var (
+		set    = NewEclipseSet()
+		first  = enode.ID{1}
+		second = enode.ID{2}
+		relay  = EclipsePolicy{Blocks: EclipseRelay}
+		delay  = EclipsePolicy{Blocks: EclipseDelay, BlockDelay: time.Second}
+	)
+	if !set.Add(second, relay, 5) {
+		t.Fatalf("failed to add peer")
+	}
+	if set.Add(second, delay, 7) {
+		t.Errorf("added eclipsed peer twice")
+	}
+	set.Set(first, delay, 6)
+	if ids := set.IDs(); !reflect.DeepEqual(ids, []enode.ID{first, second}) {
+		t.Errorf("peer IDs mismatch: have %v", ids)
+	}
+	if policy, since, ok := set.Get(second); !ok || policy != relay || since != 5 {
+		t.Errorf("added peer mismatch: have %+v since %d", policy, since)
+	}
+	set.Set(second, delay, 8)
+	if policy, since, ok := set.Get(second); !ok || policy != delay || since != 5 {
+		t.Errorf("updated peer mismatch: have %+v since %d", policy, since)
+	}
+	if !set.Remove(second) || set.Remove(second) {
+		t.Errorf("removal mismatch")
+	}
+	if set.Contains(second) || !set.Contains(first) {
+		t.Errorf("membership mismatch after removal")
+	}
+}
+
+// Tests that the eclipse set can be changed while it is being read.
+func TestEclipseSetConcurrency(t *testing.T) {
+	set := NewEclipseSet()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(id enode.ID) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				set.Add(id, EclipsePolicy{}, uint64(j))
+				set.Get(id)
+				set.IDs()
+				set.Remove(id)
+			}
+		}(enode.ID{byte(i)})
+	}
+	wg.Wait()
+
+	if ids := set.IDs(); len(ids) != 0 {
+		t.Errorf("peers left: %v", ids)
+	}
+}
@@ -0,0 +1,85 @@
+package logic
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// Types of the events written to the event log, besides the publish decisions
+// of the strategy which are logged under the name of the action.
+const (
+	EventOwnBlock     = "own-block"     // a block was mined by this node
+	EventForeignBlock = "foreign-block" // a block mined by others was received
+	EventPublish      = "publish"       // a private block was published
+	EventStrategy     = "strategy"      // the mining strategy was switched
+	EventDivergence   = "divergence"    // comparing the chains by length and by total difficulty leads to different decisions
+	EventPoolBlock    = "pool-block"    // a withheld block was received from a member of the mining pool
+	EventPoolDecision = "pool-decision" // a publish decision of a member of the mining pool was followed
+)
+
+// EventFormat formats the records of the event log as JSON objects separated by
+// newlines. Every object carries the time and the type of the event followed
+// by the context of the record, the level of the record is omitted.
+func EventFormat() log.Format {
+	return log.FormatFunc(func(r *log.Record) []byte {
+		props := map[string]interface{}{
+			"time":  r.Time.Format(time.RFC3339Nano),
+			"event": r.Msg,
+		}
+		for i := 0; i+1 < len(r.Ctx); i += 2 {
+			props[fmt.Sprint(r.Ctx[i])] = r.Ctx[i+1]
+		}
+		blob, err := json.Marshal(props)
+		if err != nil {
+			blob, _ = json.Marshal(map[string]string{"event": r.Msg, "error": err.Error()})
+		}
+		return append(blob, '\n')
+	})
+}
+
+// NewEventLog creates a logger writing mining events to the given file, which
+// is rotated once it reaches the size limit in bytes. The returned closer closes
+// the file.
+func NewEventLog(path string, limit uint) (log.Logger, io.Closer, error) {
+	handler, err := log.RotatingFileHandler(path, limit, EventFormat())
+	if err != nil {
+		return nil, nil, err
+	}
+	logger := log.New()
+	logger.SetHandler(handler)
+	return logger, handler.(io.Closer), nil
+}
+
+// lead returns how many blocks the private chain is ahead of the public chain,
+// or zero for honest strategies which don't maintain a private chain.
+func (data *MiningData) lead() int {
+	if data.MinerStrategy.IsHonest() {
+		return 0
+	}
+	return data.PrivateChain.Length() - data.PublicChain.Length()
+}
+
+// logEvent writes an event concerning the given block to the event log. The
+// peer is the one the block was received from, if it was mined by others. Any
+// extra context is appended to the record.
+func (data *MiningData) logEvent(event string, block *types.Block, leadBefore, leadAfter int, peer string, extra ...interface{}) {
+	if data.EventLog == nil {
+		return
+	}
+	ctx := []interface{}{
+		"number", block.NumberU64(),
+		"hash", block.Hash(),
+		"coinbase", block.Coinbase(),
+		"leadBefore", leadBefore,
+		"leadAfter", leadAfter,
+	}
+	if peer != "" {
+		ctx = append(ctx, "peer", peer)
+	}
+	data.EventLog.Info(event, append(ctx, extra...)...)
+}
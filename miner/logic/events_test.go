@@ -0,0 +1,74 @@
+package logic
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestEventLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "eventlog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "events.jsonl")
+	logger, closer, err := NewEventLog(path, 512)
+	if err != nil {
+		t.Fatalf("failed to create event log: %v", err)
+	}
+	defer closer.Close()
+	strategy, _ := New(SelfishAllUncles, nil)
+	data := &MiningData{MinerStrategy: strategy, EventLog: logger}
+
+	block := types.NewBlockWithHeader(&types.Header{Number: common.Big1, Coinbase: common.Address{0x01}})
+	for i := 0; i < 10; i++ {
+		data.logEvent(EventForeignBlock, block, 1, 0, "peer")
+	}
+	files, err := filepath.Glob(path + "*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) < 2 {
+		t.Fatalf("event log not rotated: %v", files)
+	}
+	var count int
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			var event struct {
+				Time       string         `json:"time"`
+				Event      string         `json:"event"`
+				Number     uint64         `json:"number"`
+				Hash       common.Hash    `json:"hash"`
+				Coinbase   common.Address `json:"coinbase"`
+				LeadBefore int            `json:"leadBefore"`
+				LeadAfter  int            `json:"leadAfter"`
+				Peer       string         `json:"peer"`
+			}
+			if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+				t.Fatalf("invalid event %q: %v", scanner.Text(), err)
+			}
+			if event.Time == "" || event.Event != EventForeignBlock || event.Number != 1 || event.Hash != block.Hash() ||
+				event.Coinbase != block.Coinbase() || event.LeadBefore != 1 || event.LeadAfter != 0 || event.Peer != "peer" {
+				t.Errorf("event mismatch: %s", scanner.Text())
+			}
+			count++
+		}
+		f.Close()
+	}
+	if count != 10 {
+		t.Errorf("event count mismatch: have %d, want %d", count, 10)
+	}
+}
@@ -0,0 +1,86 @@
+package logic
+
+import (
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+)
+
+// ForkBlock is a block of a fork of the public chain.
+type ForkBlock struct {
+	Number    uint64           `json:"number"`
+	Hash      common.Hash      `json:"hash"`
+	Coinbase  common.Address   `json:"coinbase"`
+	Td        *hexutil.Big     `json:"td"`
+	FirstSeen time.Time        `json:"firstSeen"` // zero if the block was loaded from the database
+	Origin    core.BlockOrigin `json:"origin"`
+	Peer      string           `json:"peer,omitempty"`
+}
+
+func newForkBlock(node *core.ForkNode) ForkBlock {
+	return ForkBlock{
+		Number:    node.Number(),
+		Hash:      node.Hash(),
+		Coinbase:  node.Block.Coinbase(),
+		Td:        (*hexutil.Big)(node.Td),
+		FirstSeen: node.FirstSeen,
+		Origin:    node.Origin,
+		Peer:      node.Peer,
+	}
+}
+
+// FirstSeen returns the time this node first saw a recent block of the public
+// chain. Blocks that left the fork tree or were loaded from the database are
+// not known.
+func (data *MiningData) FirstSeen(hash common.Hash) (time.Time, bool) {
+	data.lock.Lock()
+	defer data.lock.Unlock()
+
+	node := data.ForkTree.Get(hash)
+	if node == nil || node.FirstSeen.IsZero() {
+		return time.Time{}, false
+	}
+	return node.FirstSeen, true
+}
+
+// SideBranch is a branch of the public chain which lost against the canonical
+// branch.
+type SideBranch struct {
+	Ancestor ForkBlock   `json:"ancestor"` // last block shared with the canonical branch
+	Blocks   []ForkBlock `json:"blocks"`   // blocks of the side branch, in ascending order
+}
+
+// SideBranches returns the side branches of the public chain known to the fork
+// tree, that is the recent blocks the public chain did not build on. The
+// canonical branch is the one of the public head, as picked by the fork choice
+// of the public chain.
+func (data *MiningData) SideBranches() []*SideBranch {
+	data.lock.Lock()
+	defer data.lock.Unlock()
+
+	var (
+		tree     = data.ForkTree
+		head     = tree.Get(data.PublicChain.CurrentBlock().Hash())
+		branches []*SideBranch
+	)
+	if head == nil {
+		return nil
+	}
+	for _, leaf := range tree.Leaves() {
+		if leaf == head {
+			continue
+		}
+		ancestor := tree.CommonAncestor(leaf.Hash(), head.Hash())
+		if ancestor == nil {
+			continue
+		}
+		branch := &SideBranch{Ancestor: newForkBlock(ancestor)}
+		for node := leaf; node != nil && node != ancestor; node = tree.Parent(node.Hash()) {
+			branch.Blocks = append([]ForkBlock{newForkBlock(node)}, branch.Blocks...)
+		}
+		branches = append(branches, branch)
+	}
+	return branches
+}
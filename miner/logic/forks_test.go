@@ -0,0 +1,72 @@
+package logic
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Tests that the side branches and the pruning of the fork tree follow the head
+// of the public chain when its fork choice prefers the own block of a tie over
+// the honest block seen first.
+func TestSideBranchesPreservedHead(t *testing.T) {
+	var (
+		gspec   = &core.Genesis{Config: params.TestChainConfig}
+		genesis = gspec.MustCommit(rawdb.NewMemoryDatabase())
+		gendb   = rawdb.NewMemoryDatabase()
+		own     = common.Address{0x01}
+	)
+	gspec.MustCommit(gendb)
+
+	shared, _ := core.GenerateChain(gspec.Config, genesis, ethash.NewFaker(), gendb, 1, nil)
+	honest, _ := core.GenerateChain(gspec.Config, shared[0], ethash.NewFaker(), gendb, 1, func(i int, b *core.BlockGen) {
+		b.SetCoinbase(common.Address{0x02})
+	})
+	private, _ := core.GenerateChain(gspec.Config, shared[0], ethash.NewFaker(), gendb, 1, func(i int, b *core.BlockGen) {
+		b.SetCoinbase(own)
+	})
+	db := rawdb.NewMemoryDatabase()
+	gspec.MustCommit(db)
+	preserve := func(header *types.Header) bool { return header.Coinbase == own }
+	publicChain, err := core.NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{}, preserve, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer publicChain.Stop()
+
+	forks := core.NewForkTree(publicChain.Genesis(), publicChain.Genesis().Difficulty())
+	data := &MiningData{PublicChain: publicChain, ForkTree: forks}
+	for _, blocks := range []types.Blocks{shared, honest, private} {
+		if _, err := publicChain.InsertChain(blocks); err != nil {
+			t.Fatalf("failed to insert blocks: %v", err)
+		}
+		data.addForks(blocks, core.OriginPeer, "peer")
+	}
+	// The tie is broken in favour of the own block, which was added last
+	if head := publicChain.CurrentBlock(); head.Hash() != private[0].Hash() {
+		t.Fatalf("public head mismatch: have %x, want %x", head.Hash(), private[0].Hash())
+	}
+	if leaf := forks.HeaviestLeaf(); leaf.Hash() != honest[0].Hash() {
+		t.Fatalf("heaviest leaf mismatch: have %x, want %x", leaf.Hash(), honest[0].Hash())
+	}
+	sides := data.SideBranches()
+	if len(sides) != 1 || len(sides[0].Blocks) != 1 || sides[0].Blocks[0].Hash != honest[0].Hash() {
+		t.Fatalf("side branches mismatch: have %v", sides)
+	}
+	if sides[0].Ancestor.Hash != shared[0].Hash() {
+		t.Errorf("side branch ancestor mismatch: have %x, want %x", sides[0].Ancestor.Hash, shared[0].Hash())
+	}
+	// Pruning right below the head keeps the own block and drops the honest one
+	if dropped := forks.Prune(publicChain.CurrentBlock().Hash(), 0); dropped != 3 {
+		t.Errorf("dropped block count mismatch: have %d, want %d", dropped, 3)
+	}
+	if !forks.Has(private[0].Hash()) || forks.Has(honest[0].Hash()) {
+		t.Errorf("pruned tree mismatch: head kept %t, honest block kept %t", forks.Has(private[0].Hash()), forks.Has(honest[0].Hash()))
+	}
+}
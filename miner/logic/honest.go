@@ -0,0 +1,22 @@
+package logic
+
+import "github.com/ethereum/go-ethereum/core/types"
+
+func init() {
+	Register(HONEST, func(*Config) (Strategy, error) { return honest{}, nil })
+}
+
+// honest mines on the public chain and publishes every block right away.
+type honest struct{}
+
+func (honest) Name() string { return HONEST }
+
+func (honest) IsHonest() bool { return true }
+
+func (honest) OnFoundBlock(State) Action { return Wait }
+
+func (honest) OnOthersFoundBlocks(State) Action { return Wait }
+
+func (honest) FilterUncles(uncles []*types.Header, isLocal func(header *types.Header) bool) []*types.Header {
+	return allUncles.filter(uncles, isLocal)
+}
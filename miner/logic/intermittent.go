@@ -0,0 +1,141 @@
+package logic
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Schedules of intermittent selfish mining.
+const (
+	ScheduleBlocks     = "blocks"     // phases last a number of public blocks
+	ScheduleTime       = "time"       // phases last a wall time
+	ScheduleDifficulty = "difficulty" // phases change when the public difficulty crosses a threshold
+)
+
+// IntermittentConfig configures intermittent selfish mining, which alternates
+// between selfish phases following the strategy of the miner and honest phases.
+// The orphans of the selfish phases slow down the public chain, which lowers the
+// difficulty the honest phases are mined at.
+type IntermittentConfig struct {
+	Schedule      string        // Schedule of the phases, intermittent mining is disabled if empty
+	SelfishBlocks uint64        // Number of public blocks a selfish phase lasts with the blocks schedule
+	HonestBlocks  uint64        // Number of public blocks an honest phase lasts with the blocks schedule
+	SelfishTime   time.Duration // Time a selfish phase lasts with the time schedule
+	HonestTime    time.Duration // Time an honest phase lasts with the time schedule
+	SelfishAbove  *big.Int      // Difficulty of the public head at which a selfish phase starts with the difficulty schedule
+	HonestBelow   *big.Int      // Difficulty of the public head below which an honest phase starts, SelfishAbove if nil
+	Publish       bool          // Whether the withheld blocks are published when an honest phase starts, instead of discarded
+}
+
+// Enabled reports whether intermittent mining is configured.
+func (c *IntermittentConfig) Enabled() bool {
+	return c.Schedule != ""
+}
+
+// Validate checks that the phases of the schedule are well defined.
+func (c *IntermittentConfig) Validate() error {
+	switch c.Schedule {
+	case "":
+		return nil
+	case ScheduleBlocks:
+		if c.SelfishBlocks == 0 || c.HonestBlocks == 0 {
+			return errors.New("intermittent phases must last at least one block")
+		}
+	case ScheduleTime:
+		if c.SelfishTime <= 0 || c.HonestTime <= 0 {
+			return errors.New("intermittent phases must last a positive time")
+		}
+	case ScheduleDifficulty:
+		if c.SelfishAbove == nil || c.SelfishAbove.Sign() <= 0 {
+			return errors.New("intermittent difficulty threshold must be positive")
+		}
+		if c.HonestBelow != nil && c.HonestBelow.Cmp(c.SelfishAbove) > 0 {
+			return errors.New("intermittent honest threshold exceeds the selfish threshold")
+		}
+	default:
+		return fmt.Errorf("unknown intermittent schedule %q", c.Schedule)
+	}
+	return nil
+}
+
+// honestBelow returns the difficulty below which an honest phase starts.
+func (c *IntermittentConfig) honestBelow() *big.Int {
+	if c.HonestBelow != nil {
+		return c.HonestBelow
+	}
+	return c.SelfishAbove
+}
+
+// Intermittent tracks the phases of intermittent selfish mining. It starts in a
+// selfish phase, unless the difficulty schedule calls for an honest one.
+type Intermittent struct {
+	config  *IntermittentConfig
+	selfish bool
+	number  uint64    // number of the public head when the phase started
+	start   time.Time // time the phase started
+}
+
+// NewIntermittent creates the phase tracker of the given schedule, starting at
+// the given public head and time.
+func NewIntermittent(config *IntermittentConfig, head *types.Header, now time.Time) *Intermittent {
+	i := &Intermittent{
+		config:  config,
+		selfish: true,
+		number:  head.Number.Uint64(),
+		start:   now,
+	}
+	if config.Schedule == ScheduleDifficulty {
+		i.selfish = head.Difficulty.Cmp(config.SelfishAbove) >= 0
+	}
+	return i
+}
+
+// Selfish reports whether the current phase is a selfish one.
+func (i *Intermittent) Selfish() bool {
+	return i.selfish
+}
+
+// Deadline returns the time the current phase ends with the time schedule, or
+// the zero time with the other schedules.
+func (i *Intermittent) Deadline() time.Time {
+	if i.config.Schedule != ScheduleTime {
+		return time.Time{}
+	}
+	if i.selfish {
+		return i.start.Add(i.config.SelfishTime)
+	}
+	return i.start.Add(i.config.HonestTime)
+}
+
+// Update moves on to the next phase if the current one is over at the given
+// public head and time. It reports whether the phase changed.
+func (i *Intermittent) Update(head *types.Header, now time.Time) bool {
+	var over bool
+	switch i.config.Schedule {
+	case ScheduleBlocks:
+		length := i.config.HonestBlocks
+		if i.selfish {
+			length = i.config.SelfishBlocks
+		}
+		over = head.Number.Uint64() >= i.number+length
+	case ScheduleTime:
+		over = !now.Before(i.Deadline())
+	case ScheduleDifficulty:
+		if i.selfish {
+			over = head.Difficulty.Cmp(i.config.honestBelow()) < 0
+		} else {
+			over = head.Difficulty.Cmp(i.config.SelfishAbove) >= 0
+		}
+	}
+	if !over {
+		return false
+	}
+	i.selfish = !i.selfish
+	i.number = head.Number.Uint64()
+	i.start = now
+	return true
+}
@@ -0,0 +1,75 @@
+package logic
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestIntermittentConfigValidate(t *testing.T) {
+	tests := []struct {
+		config IntermittentConfig
+		valid  bool
+	}{
+		{IntermittentConfig{}, true},
+		{IntermittentConfig{Schedule: ScheduleBlocks, SelfishBlocks: 2, HonestBlocks: 1}, true},
+		{IntermittentConfig{Schedule: ScheduleBlocks, SelfishBlocks: 2}, false},
+		{IntermittentConfig{Schedule: ScheduleTime, SelfishTime: time.Minute, HonestTime: time.Second}, true},
+		{IntermittentConfig{Schedule: ScheduleTime, HonestTime: time.Second}, false},
+		{IntermittentConfig{Schedule: ScheduleDifficulty, SelfishAbove: big.NewInt(100)}, true},
+		{IntermittentConfig{Schedule: ScheduleDifficulty, SelfishAbove: big.NewInt(100), HonestBelow: big.NewInt(200)}, false},
+		{IntermittentConfig{Schedule: ScheduleDifficulty}, false},
+		{IntermittentConfig{Schedule: "weekly"}, false},
+	}
+	for i, tt := range tests {
+		if err := tt.config.Validate(); (err == nil) != tt.valid {
+			t.Errorf("test %d: validity mismatch: have %v, want valid %t", i, err, tt.valid)
+		}
+	}
+}
+
+func TestIntermittentPhases(t *testing.T) {
+	header := func(number, difficulty int64) *types.Header {
+		return &types.Header{Number: big.NewInt(number), Difficulty: big.NewInt(difficulty)}
+	}
+	start := time.Unix(0, 0)
+
+	// Two selfish blocks, then one honest block
+	blocks := NewIntermittent(&IntermittentConfig{Schedule: ScheduleBlocks, SelfishBlocks: 2, HonestBlocks: 1}, header(10, 1), start)
+	for i, want := range []bool{true, false, true, true, false, true} {
+		prev := blocks.Selfish()
+		if changed := blocks.Update(header(int64(11+i), 1), start); blocks.Selfish() != want || changed != (want != prev) {
+			t.Errorf("block %d: phase mismatch: have selfish %t (changed %t), want %t", 11+i, blocks.Selfish(), changed, want)
+		}
+	}
+	// A minute selfish, then a second honest
+	clock := NewIntermittent(&IntermittentConfig{Schedule: ScheduleTime, SelfishTime: time.Minute, HonestTime: time.Second}, header(0, 1), start)
+	if deadline := clock.Deadline(); !deadline.Equal(start.Add(time.Minute)) {
+		t.Errorf("selfish deadline mismatch: have %v", deadline)
+	}
+	if clock.Update(header(0, 1), start.Add(59*time.Second)) || !clock.Selfish() {
+		t.Errorf("selfish phase ended early")
+	}
+	if !clock.Update(header(0, 1), start.Add(time.Minute)) || clock.Selfish() {
+		t.Errorf("selfish phase not ended")
+	}
+	if deadline := clock.Deadline(); !deadline.Equal(start.Add(time.Minute + time.Second)) {
+		t.Errorf("honest deadline mismatch: have %v", deadline)
+	}
+	// Selfish from 100 on, honest below 80
+	difficulty := NewIntermittent(&IntermittentConfig{Schedule: ScheduleDifficulty, SelfishAbove: big.NewInt(100), HonestBelow: big.NewInt(80)}, header(0, 90), start)
+	if difficulty.Selfish() {
+		t.Errorf("started selfish below the threshold")
+	}
+	for i, step := range []struct {
+		difficulty int64
+		selfish    bool
+	}{{99, false}, {100, true}, {80, true}, {79, false}, {90, false}} {
+		difficulty.Update(header(int64(i+1), step.difficulty), start)
+		if difficulty.Selfish() != step.selfish {
+			t.Errorf("difficulty %d: phase mismatch: have selfish %t, want %t", step.difficulty, difficulty.Selfish(), step.selfish)
+		}
+	}
+}
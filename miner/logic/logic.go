@@ -1,179 +1,449 @@
 package logic
 
 import (
+	"sync"
+	"time"
+
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/log"
-	log2 "log"
 )
 
-type Strategy int
+const (
+	// forkTreeDepth is the minimum number of blocks below the public head kept
+	// in the fork tree.
+	forkTreeDepth = 128
+
+	// importBatchSize is the number of public blocks imported into the private
+	// chain at once when it has to catch up with the public chain.
+	importBatchSize = 256
+)
 
-func (s Strategy) IsSelfish() bool {
-	return s != HONEST
+type MiningData struct {
+	PublicChain         *core.BlockChain
+	PrivateChain        *core.BlockChain
+	PrivateBranchLength *int
+	NextToPublish       *int
+	MinerStrategy       Strategy // Set through SetStrategy and read through Strategy once mining started
+	Coinbase            common.Address
+	EclipsePeers        *EclipseSet   // Peers whose view of the network this node controls
+	Eclipse             EclipsePolicy // Default treatment of the peers eclipsed without a policy
+	Race                RaceConfig
+	Comparison          ComparisonConfig
+	Pool                bool // Whether the withheld blocks and publish decisions are shared with a mining pool
+	EventMux            *event.TypeMux
+	EventLog            log.Logger     // logger receiving the structured mining events, see EventFormat
+	PrivateChainDb      ethdb.Database // database of the private chain, where the mining state is persisted
+	ForkTree            *core.ForkTree // blocks of the public chain including side branches, the private chain imports them when adopting
+
+	decisionFeed event.Feed
+	found        map[common.Hash]time.Time // times the unpublished private blocks were found
+	race         *race                     // racing block published by the last match, until the race is decided
+	lock         sync.Mutex                // Protects the mining state against concurrent block events and readers
 }
 
-func (s Strategy) IsHonest() bool {
-	return s == HONEST
+// Decision is sent to subscribers for every publish decision of the strategy
+// other than waiting.
+type Decision struct {
+	Strategy string `json:"strategy"`
+	Action   Action `json:"action"`
+	OwnBlock bool   `json:"ownBlock"` // Whether the decision follows a block found by this node
+	State    State  `json:"state"`    // State the decision was based on
 }
 
-const (
-	HONEST Strategy = iota
-	SelfishNoUncles
-	SelfishOwnUncles
-	SelfishAllUncles
-)
+// RaceConfig controls the propagation of racing blocks, which are published to
+// match a block of the same height on the public chain. It allows to set the
+// share of the honest network that sees the attacker's block first (gamma).
+type RaceConfig struct {
+	Enabled  bool          // Whether racing blocks are propagated according to this config
+	Fraction float64       // Fraction of the peers the racing block is pushed to immediately
+	Peers    []string      // Enode URLs of the peers the racing block is pushed to immediately, overrides Fraction
+	Delay    time.Duration // Delay after which the remaining peers receive the racing block, zero to withhold it
+}
 
-type MiningData struct {
-	PublicChain                          *core.BlockChain
-	PrivateChain                         *core.BlockChain
-	PrivateBranchLength                  *int
-	NextToPublish                        *int
-	MinerStrategy                        Strategy
-	Coinbase                             common.Address
-	EclipsePeers                         []string
-	EventMux                             *event.TypeMux
-	PublicChainBranchesToImportContainer *core.BranchesContainer // container that holds all branches that private chain needs to import next time it has to be set to public chain
-}
-
-func OnFoundBlock(data *MiningData, block *types.Block, receipts []*types.Receipt, logs []*types.Log,
+// state returns the current view of the private and public chain.
+func (data *MiningData) state() State {
+	return State{
+		PrivateLength:       data.PrivateChain.Length(),
+		PublicLength:        data.PublicChain.Length(),
+		PrivateBranchLength: *data.PrivateBranchLength,
+		NextToPublish:       *data.NextToPublish,
+		CommonAncestor:      commonAncestor(data.PrivateChain, data.PublicChain),
+		TDLead:              data.tdLead(),
+		CompareTD:           data.Comparison.byTD(),
+	}
+}
+
+// Status returns the current view of the private and public chain.
+func (data *MiningData) Status() State {
+	data.lock.Lock()
+	defer data.lock.Unlock()
+
+	return data.state()
+}
+
+// UnpublishedBlocks returns the blocks of the private chain that have not been
+// published yet.
+func (data *MiningData) UnpublishedBlocks() types.Blocks {
+	data.lock.Lock()
+	defer data.lock.Unlock()
+
+	var blocks types.Blocks
+	for number := *data.NextToPublish; number <= data.PrivateChain.Length(); number++ {
+		if block := data.PrivateChain.GetBlockByNumber(uint64(number)); block != nil {
+			blocks = append(blocks, block)
+		}
+	}
+	return blocks
+}
+
+// BranchesToImport returns the public branches the private chain imports the
+// next time it adopts the public chain.
+func (data *MiningData) BranchesToImport() []types.Blocks {
+	data.lock.Lock()
+	defer data.lock.Unlock()
+
+	return data.branchesToImport()
+}
+
+// branchesToImport returns the public branches above the common ancestor of the
+// private and public chain.
+func (data *MiningData) branchesToImport() []types.Blocks {
+	ancestor := data.PublicChain.GetCanonicalHash(uint64(commonAncestor(data.PrivateChain, data.PublicChain)))
+	return data.ForkTree.Branches(ancestor)
+}
+
+// adoptPublicChain sets the private chain to the public chain. If the private
+// chain can't read the public head and its state from the public chain, the
+// public branches above the common ancestor are imported into the private chain
+// first. The head of the private chain is then moved to the public head, even
+// if the private branch is heavier.
+func (data *MiningData) adoptPublicChain() {
+	head := data.PublicChain.CurrentBlock()
+	if data.PrivateChain.CurrentBlock().Hash() == head.Hash() {
+		return
+	}
+	// A pruning public chain only keeps the recent state in memory, where the
+	// private chain reads it until the public chain writes it before pruning it
+	data.PublicChain.KeepState(head)
+	if !data.PrivateChain.HasBlockAndState(head.Hash(), head.NumberU64()) {
+		for _, branch := range data.branchesToImport() {
+			if _, err := data.PrivateChain.InsertChain(branch); err != nil {
+				log.Warn("Failed to import public branch into private chain", "err", err)
+			}
+		}
+	}
+	if data.PrivateChain.CurrentBlock().Hash() == head.Hash() {
+		return
+	}
+	if err := data.PrivateChain.SetChainHead(head); err != nil {
+		log.Warn("Failed to move private chain to public head", "number", head.Number(), "hash", head.Hash(), "err", err)
+	}
+}
+
+// importPublicChain imports the canonical blocks of the public chain above the
+// given number into the private chain, for when the common ancestor of both
+// chains was already pruned from the fork tree.
+func (data *MiningData) importPublicChain(number uint64) {
+	head := data.PublicChain.CurrentBlock().NumberU64()
+	for number < head {
+		var blocks types.Blocks
+		for ; number < head && len(blocks) < importBatchSize; number++ {
+			blocks = append(blocks, data.PublicChain.GetBlockByNumber(number+1))
+		}
+		if _, err := data.PrivateChain.InsertChain(blocks); err != nil {
+			log.Warn("Failed to import public chain into private chain", "err", err)
+			return
+		}
+	}
+}
+
+// miningChain returns the chain the current strategy mines on. The caller must
+// hold the lock.
+func (data *MiningData) miningChain() *core.BlockChain {
+	if data.MinerStrategy.IsHonest() {
+		return data.PublicChain
+	}
+	return data.PrivateChain
+}
+
+// Strategy returns the current mining strategy.
+func (data *MiningData) Strategy() Strategy {
+	data.lock.Lock()
+	defer data.lock.Unlock()
+
+	return data.MinerStrategy
+}
+
+// SetStrategy switches the mining strategy. The blocks withheld by a selfish
+// strategy are published first if publish is set, and discarded otherwise.
+// Either way the private chain adopts the public chain afterwards, so that the
+// new strategy starts without a private branch.
+func (data *MiningData) SetStrategy(strategy Strategy, publish bool) {
+	data.lock.Lock()
+	defer data.lock.Unlock()
+
+	var (
+		previous = data.MinerStrategy
+		before   = data.lead()
+		withheld = 0
+	)
+	if !previous.IsHonest() {
+		if n := data.PrivateChain.Length() - *data.NextToPublish + 1; n > 0 {
+			withheld = n
+		}
+		if publish {
+			publishUpTo(data, data.PrivateChain.Length(), false)
+		}
+		data.trackAdopted()
+	}
+	// The private chain is left behind while mining honestly, in which case
+	// the ancestor may not be in the fork tree anymore.
+	if ancestor := commonAncestor(data.PrivateChain, data.PublicChain); !data.ForkTree.Has(data.PublicChain.GetCanonicalHash(uint64(ancestor))) {
+		data.importPublicChain(uint64(ancestor))
+	}
+	data.adoptPublicChain()
+	*data.PrivateBranchLength = 0
+	*data.NextToPublish = data.PublicChain.Length() + 1
+	data.race = nil
+	data.MinerStrategy = strategy
+
+	data.pruneForks()
+	data.persist()
+	leadGauge.Update(int64(data.lead()))
+	branchLengthGauge.Update(0)
+
+	if data.EventLog != nil {
+		data.EventLog.Info(EventStrategy, "from", previous.Name(), "to", strategy.Name(), "publish", publish,
+			"withheld", withheld, "leadBefore", before, "leadAfter", data.lead())
+	}
+	log.Info("Switched mining strategy", "from", previous.Name(), "to", strategy.Name(), "publish", publish, "withheld", withheld)
+}
+
+// addForks adds blocks inserted into the public chain to the fork tree.
+func (data *MiningData) addForks(blocks types.Blocks, origin core.BlockOrigin, peer string) {
+	for _, block := range blocks {
+		if err := data.ForkTree.Add(block, origin, peer); err != nil {
+			log.Debug("Failed to add block to the fork tree", "number", block.Number(), "hash", block.Hash(), "err", err)
+		}
+	}
+}
+
+// pruneForks drops the blocks of the fork tree that neither the private chain
+// nor the recent public blocks fork from, below the head of the public chain.
+func (data *MiningData) pruneForks() {
+	depth := data.PublicChain.Length() - commonAncestor(data.PrivateChain, data.PublicChain)
+	if depth < forkTreeDepth {
+		depth = forkTreeDepth
+	}
+	data.ForkTree.Prune(data.PublicChain.CurrentBlock().Hash(), uint64(depth))
+}
+
+// SubscribeDecisions registers a subscription for the publish decisions of
+// the strategy.
+func (data *MiningData) SubscribeDecisions(ch chan<- Decision) event.Subscription {
+	return data.decisionFeed.Subscribe(ch)
+}
+
+// decide lets the strategy decide on the given state, carries out the decision,
+// persists the resulting state and notifies subscribers. The block is the one
+// that triggered the decision, received from the given peer if it was mined by
+// others. The decision is returned.
+func (data *MiningData) decide(state State, strategy func(State) Action, block *types.Block, peer string, blocks types.Blocks) Action {
+	action := strategy(state)
+	data.checkDivergence(state, action, strategy, block)
+
+	published := *data.NextToPublish
+	apply(data, action, blocks)
+	data.pruneForks()
+	data.persist()
+	data.updateMetrics(action, published)
+
+	if action != Wait {
+		data.logEvent(action.String(), block, state.LengthLead(), data.lead(), peer)
+		data.decisionFeed.Send(Decision{
+			Strategy: data.MinerStrategy.Name(),
+			Action:   action,
+			OwnBlock: blocks == nil,
+			State:    state,
+		})
+	}
+	return action
+}
+
+// commonAncestor returns the number of the latest block that is canonical in
+// both chains.
+func commonAncestor(private, public *core.BlockChain) int {
+	number := private.Length()
+	if public.Length() < number {
+		number = public.Length()
+	}
+	for ; number > 0; number-- {
+		if private.GetCanonicalHash(uint64(number)) == public.GetCanonicalHash(uint64(number)) {
+			break
+		}
+	}
+	return number
+}
+
+// OnFoundBlock writes a block sealed on the given chain, along with its state,
+// into the chain the strategy mines on and lets the strategy react to it. A block
+// sealed before the strategy switched to the other chain is discarded, as its
+// state belongs to the trie cache of the chain it was built on.
+func OnFoundBlock(data *MiningData, chain *core.BlockChain, block *types.Block, receipts []*types.Receipt, logs []*types.Log,
 	state *state.StateDB) {
-	log2.Printf("OnFoundBlock: %d", block.NumberU64())
+	data.lock.Lock()
+	defer data.lock.Unlock()
 
+	if mining := data.miningChain(); chain != mining {
+		log.Debug("Discarding block sealed before strategy switch", "number", block.Number(), "hash", block.Hash())
+		return
+	}
+	before := data.lead()
 	if data.MinerStrategy.IsHonest() {
 		// Commit block and state to database.
 		_, err := data.PublicChain.WriteBlockAndSetHead(block, receipts, logs, state, true)
 		if err != nil {
-			log2.Printf("Failed writing block to public chain: %s", err)
 			log.Error("Failed writing block to chain", "err", err)
 			return
 		}
+		data.addForks(types.Blocks{block}, core.OriginLocal, "")
+		data.pruneForks()
+		data.logEvent(EventOwnBlock, block, before, data.lead(), "")
 
 		// Broadcast the block and announce chain insertion event
 		postMinedEvent(block, data.EventMux)
-		data.PublicChain.Print("public ")
-		data.PublicChain.PrintBalance(data.Coinbase)
 		return
 	}
 
 	// selfish mining
 
 	// Commit block and state to database.
+	previous := data.PrivateChain.CurrentBlock()
 	_, err := data.PrivateChain.WriteBlockAndSetHead(block, receipts, logs, state, true)
 	if err != nil {
-		log2.Printf("Failed writing block to private chain: %s", err)
-		log.Error("Failed writing block to chain", "err", err)
+		log.Error("Failed writing block to private chain", "err", err)
 		return
 	}
-	*data.PrivateBranchLength++
-
-	diff := data.PrivateChain.Length() - data.PublicChain.Length()
+	data.shareBlock(block)
 
-	if diff == 1 && *data.PrivateBranchLength == 2 {
-		// publish all of the private chain
-		log2.Printf("publish all of the private chain")
-		for number := *data.NextToPublish; number <= int(data.PrivateChain.CurrentBlock().NumberU64()); number++ {
-			block := data.PrivateChain.GetBlockByNumber(uint64(number))
-			publishBlock(block, data.PublicChain, data.EventMux)
+	// A block of a pool member may have taken the height first
+	if data.Pool && data.poolPrefers(previous, block) {
+		if err := data.PrivateChain.SetChainHead(previous); err != nil {
+			log.Warn("Failed to keep private chain at pool block", "number", previous.Number(), "hash", previous.Hash(), "err", err)
+		} else {
+			data.logEvent(EventOwnBlock, block, before, data.lead(), "")
+			return
 		}
-		*data.PrivateBranchLength = 0
-		*data.NextToPublish = int(data.PrivateChain.CurrentBlock().NumberU64()) + 1
 	}
+	*data.PrivateBranchLength++
+	data.trackFound(block.Hash())
 
-	data.PrivateChain.Print("private")
-	data.PublicChain.Print("public ")
-	data.PublicChain.PrintBalance(data.Coinbase)
+	current := data.state()
+	data.logEvent(EventOwnBlock, block, before, current.Lead(), "")
+	data.shareDecision(data.decide(current, data.MinerStrategy.OnFoundBlock, block, "", nil))
 }
 
-func OnOthersFoundBlocks(blocks types.Blocks, data *MiningData) (int, error) {
-	if len(blocks) == 1 {
-		log2.Printf("OnOthersFoundBlocks(): %d", blocks[0].NumberU64())
-	} else {
-		log2.Printf("OnOthersFoundBlocks(): %d to %d", blocks[0].NumberU64(), blocks[len(blocks)-1].NumberU64())
-	}
+// OnOthersFoundBlocks inserts blocks mined by others, received from the given
+// peer, into the public chain and lets the strategy react to them.
+func OnOthersFoundBlocks(blocks types.Blocks, peer string, data *MiningData) (int, error) {
+	data.lock.Lock()
+	defer data.lock.Unlock()
+
+	before := data.lead()
 
 	// insert into public chain
 	n, err := data.PublicChain.InsertChain(blocks)
 	if err != nil {
+		data.addForks(blocks[:n], core.OriginPeer, peer)
 		return n, err
 	}
+	data.addForks(blocks, core.OriginPeer, peer)
 
-	data.PublicChainBranchesToImportContainer.AddBranch(blocks)
-
+	after := data.lead()
+	for _, block := range blocks {
+		data.logEvent(EventForeignBlock, block, before, after, peer)
+	}
 	if data.MinerStrategy.IsHonest() {
-		data.PublicChain.Print("public ")
-		data.PublicChain.PrintBalance(data.Coinbase)
+		data.pruneForks()
 		return 0, nil
 	}
 
-	diff := data.PrivateChain.Length() - data.PublicChain.Length()
+	// selfish miner applies its strategy
+	current := data.state()
+	data.shareDecision(data.decide(current, data.MinerStrategy.OnOthersFoundBlocks, blocks[len(blocks)-1], peer, blocks))
 
-	// selfish miner applies selfish mining strategy
-	if diff < 0 { // private chain shorter than public chain
-		log2.Printf("set private chain to public chain")
-		for _, branch := range data.PublicChainBranchesToImportContainer.Branches {
-			_, err = data.PrivateChain.InsertChain(branch)
-			if err != nil {
-				log2.Printf("error inserting branch")
-			}
-		}
-		data.PublicChainBranchesToImportContainer.Clear()
+	return 0, nil
+}
+
+// apply carries out a publish decision of the strategy. The blocks are the ones
+// found by others that triggered the decision, if any.
+func apply(data *MiningData, action Action, blocks types.Blocks) {
+	switch action {
+	case Adopt:
+		// set private chain to public chain
+		data.trackAdopted()
+		data.adoptPublicChain()
 		*data.PrivateBranchLength = 0
-		*data.NextToPublish = int(data.PublicChain.CurrentBlock().NumberU64()) + 1
+		*data.NextToPublish = data.PublicChain.Length() + 1
 		// if these blocks didn't come from an eclipsed peer, publish them to eclipsed peers
 		for _, block := range blocks {
-			publishBlock(block, data.PublicChain, data.EventMux)
+			publishBlock(block, data.PublicChain, data.EventMux, false)
 		}
-	} else if diff == 0 { // private chain and public chain have same length
-		// publish last block of the private chain
-		log2.Printf("publish last block of the private chain")
-		publishBlock(data.PrivateChain.CurrentBlock(), data.PublicChain, data.EventMux)
-		*data.NextToPublish = int(data.PrivateChain.CurrentBlock().NumberU64()) + 1
-	} else if diff == 1 { // private chain is ahead by one
-		// publish all of the private chain
-		log2.Printf("publish all of the private chain")
-		for number := *data.NextToPublish; number <= int(data.PrivateChain.CurrentBlock().NumberU64()); number++ {
-			block := data.PrivateChain.GetBlockByNumber(uint64(number))
-			publishBlock(block, data.PublicChain, data.EventMux)
+	case Match:
+		// publish the private chain until it ties with the public chain
+		publishUpTo(data, data.publishTarget(Match), true)
+	case Override:
+		// publish the private chain until it outweighs the public chain
+		target := data.publishTarget(Override)
+		publishUpTo(data, target, false)
+		if rest := data.PrivateChain.Length() - target; rest > 0 {
+			*data.PrivateBranchLength = rest
+		} else {
+			*data.PrivateBranchLength = 0
 		}
-		*data.PrivateBranchLength = 0
-		*data.NextToPublish = int(data.PrivateChain.CurrentBlock().NumberU64()) + 1
-	} else { // diff > 1
+	case PublishOne:
 		// publish first unpublished block of private chain
-		log2.Printf("publish first unpublished block of private chain")
-		firstUnpublishedBlock := data.PrivateChain.GetBlockByNumber(uint64(*data.NextToPublish))
-		publishBlock(firstUnpublishedBlock, data.PublicChain, data.EventMux)
-		*data.NextToPublish++
+		publishUpTo(data, *data.NextToPublish, false)
 	}
+}
 
-	data.PrivateChain.Print("private")
-	data.PublicChain.Print("public ")
-	data.PublicChain.PrintBalance(data.Coinbase)
-
-	return 0, nil
+// publishUpTo publishes all unpublished blocks of the private chain up to and
+// including the given number. Racing blocks are propagated according to the
+// race config of the handler.
+func publishUpTo(data *MiningData, number int, race bool) {
+	for ; *data.NextToPublish <= number; *data.NextToPublish++ {
+		block := data.PrivateChain.GetBlockByNumber(uint64(*data.NextToPublish))
+		if block == nil {
+			return
+		}
+		before := data.lead()
+		if err := publishBlock(block, data.PublicChain, data.EventMux, race); err != nil {
+			log.Warn("Failed to publish private block", "number", block.Number(), "hash", block.Hash(), "err", err)
+			return
+		}
+		data.addForks(types.Blocks{block}, core.OriginLocal, "")
+		data.trackPublished(block.Hash())
+		data.logEvent(EventPublish, block, before, data.lead(), "")
+	}
 }
 
-func publishBlock(block *types.Block, publicChain *core.BlockChain, eventMux *event.TypeMux) {
-	n, err := publicChain.InsertChain(types.Blocks{block})
-	if err != nil {
-		log2.Printf("error publish block: %d, %s", n, err)
-		return
+func publishBlock(block *types.Block, publicChain *core.BlockChain, eventMux *event.TypeMux, race bool) error {
+	if _, err := publicChain.InsertChain(types.Blocks{block}); err != nil {
+		return err
+	}
+	if race {
+		eventMux.Post(core.NewRacingBlockEvent{Block: block})
+		return nil
 	}
 	postMinedEvent(block, eventMux)
+	return nil
 }
 
 func postMinedEvent(block *types.Block, mux *event.TypeMux) {
 	mux.Post(core.NewMinedBlockEvent{Block: block})
 }
-
-func Contains(array []string, val string) bool {
-	for _, element := range array {
-		if element == val {
-			return true
-		}
-	}
-	return false
-}
@@ -0,0 +1,144 @@
+package logic
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Tests that switching the strategy publishes or discards the withheld blocks
+// and leaves the private chain at the public head.
+func TestSetStrategy(t *testing.T) {
+	var (
+		gspec   = &core.Genesis{Config: params.TestChainConfig}
+		genesis = gspec.MustCommit(rawdb.NewMemoryDatabase())
+		gendb   = rawdb.NewMemoryDatabase()
+	)
+	gspec.MustCommit(gendb)
+
+	// The private branch of two withheld blocks forks off after the shared
+	// block, next to a public branch of one block.
+	shared, _ := core.GenerateChain(gspec.Config, genesis, ethash.NewFaker(), gendb, 1, nil)
+	private, _ := core.GenerateChain(gspec.Config, shared[0], ethash.NewFaker(), gendb, 2, func(i int, b *core.BlockGen) {
+		b.SetCoinbase(common.Address{0x01})
+	})
+	public, _ := core.GenerateChain(gspec.Config, shared[0], ethash.NewFaker(), gendb, 1, func(i int, b *core.BlockGen) {
+		b.SetCoinbase(common.Address{0x02})
+	})
+	for _, publish := range []bool{true, false} {
+		privateChain, privateDb := newTestChain(t, gspec, shared, private)
+		publicChain, _ := newTestChain(t, gspec, shared, public)
+		defer privateChain.Stop()
+		defer publicChain.Stop()
+
+		forks, err := publicChain.NewForkTree(0)
+		if err != nil {
+			t.Fatalf("failed to create fork tree: %v", err)
+		}
+		selfish, _ := New(SelfishAllUncles, nil)
+		honest, _ := New(HONEST, nil)
+		branchLength, next := 2, 2
+		data := &MiningData{
+			PublicChain:         publicChain,
+			PrivateChain:        privateChain,
+			PrivateBranchLength: &branchLength,
+			NextToPublish:       &next,
+			MinerStrategy:       selfish,
+			EventMux:            new(event.TypeMux),
+			PrivateChainDb:      privateDb,
+			ForkTree:            forks,
+		}
+		data.SetStrategy(honest, publish)
+
+		if data.MinerStrategy != honest {
+			t.Errorf("publish %t: strategy not switched", publish)
+		}
+		want := public[0]
+		if publish {
+			want = private[1]
+		}
+		if head := publicChain.CurrentBlock(); head.Hash() != want.Hash() {
+			t.Errorf("publish %t: public head mismatch: have %d, want %d", publish, head.NumberU64(), want.NumberU64())
+		}
+		if head := privateChain.CurrentBlock(); head.Hash() != want.Hash() {
+			t.Errorf("publish %t: private head mismatch: have %d, want %d", publish, head.NumberU64(), want.NumberU64())
+		}
+		if publicChain.HasBlock(private[0].Hash(), 2) != publish {
+			t.Errorf("publish %t: withheld block published %t", publish, !publish)
+		}
+		if branchLength != 0 || next != int(want.NumberU64())+1 {
+			t.Errorf("publish %t: state mismatch: branch %d, next %d", publish, branchLength, next)
+		}
+	}
+}
+
+// Tests that the private chain adopts the head of a pruning public chain from
+// the public database, without importing and executing the public blocks.
+func TestAdoptPrunedPublicChain(t *testing.T) {
+	var (
+		gspec   = &core.Genesis{Config: params.TestChainConfig}
+		genesis = gspec.MustCommit(rawdb.NewMemoryDatabase())
+		gendb   = rawdb.NewMemoryDatabase()
+	)
+	gspec.MustCommit(gendb)
+
+	shared, _ := core.GenerateChain(gspec.Config, genesis, ethash.NewFaker(), gendb, 1, nil)
+	private, _ := core.GenerateChain(gspec.Config, shared[0], ethash.NewFaker(), gendb, 1, func(i int, b *core.BlockGen) {
+		b.SetCoinbase(common.Address{0x01})
+	})
+	public, _ := core.GenerateChain(gspec.Config, shared[0], ethash.NewFaker(), gendb, 3, func(i int, b *core.BlockGen) {
+		b.SetCoinbase(common.Address{0x02})
+	})
+	// The public chain prunes, its recent state is only held in memory
+	publicChain, publicDb := newTestChain(t, gspec, shared, public)
+	defer publicChain.Stop()
+
+	var (
+		ownDb     = rawdb.NewMemoryDatabase()
+		privateDb = rawdb.NewOverlayDatabase(ownDb, publicChain.SharedReader())
+	)
+	gspec.MustCommit(privateDb)
+	privateChain, err := core.NewBlockChain(privateDb, nil, gspec.Config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create private chain: %v", err)
+	}
+	defer privateChain.Stop()
+	for _, chunk := range []types.Blocks{shared, private} {
+		if _, err := privateChain.InsertChain(chunk); err != nil {
+			t.Fatalf("failed to insert private blocks: %v", err)
+		}
+	}
+	forks, err := publicChain.NewForkTree(0)
+	if err != nil {
+		t.Fatalf("failed to create fork tree: %v", err)
+	}
+	data := &MiningData{
+		PublicChain:  publicChain,
+		PrivateChain: privateChain,
+		ForkTree:     forks,
+	}
+	head := public[len(public)-1]
+	data.adoptPublicChain()
+
+	if have := privateChain.CurrentBlock(); have.Hash() != head.Hash() {
+		t.Fatalf("private head mismatch: have %d [%x], want %d [%x]", have.NumberU64(), have.Hash(), head.NumberU64(), head.Hash())
+	}
+	if _, err := privateChain.State(); err != nil {
+		t.Errorf("public head state not readable: %v", err)
+	}
+	if rawdb.ReadTrieNode(publicDb, head.Root()) != nil {
+		t.Errorf("public head state written to disk")
+	}
+	for _, block := range public {
+		if rawdb.HasBody(ownDb, block.Hash(), block.NumberU64()) || rawdb.ReadReceiptsRLP(ownDb, block.Hash(), block.NumberU64()) != nil {
+			t.Errorf("public block %d imported into the private database", block.NumberU64())
+		}
+	}
+}
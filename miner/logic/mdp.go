@@ -0,0 +1,254 @@
+package logic
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Fork describes whether the attacker can match the last block of the public
+// chain, following the model of Sapirshtein et al., "Optimal Selfish Mining
+// Strategies in Bitcoin".
+type Fork int
+
+const (
+	Irrelevant Fork = iota // the last block was found by the attacker
+	Relevant               // the last block was found by others and can still be matched
+	Active                 // the attacker matched the public chain and the network is split
+)
+
+func (f Fork) String() string {
+	switch f {
+	case Irrelevant:
+		return "irrelevant"
+	case Relevant:
+		return "relevant"
+	case Active:
+		return "active"
+	default:
+		return fmt.Sprintf("fork(%d)", int(f))
+	}
+}
+
+// mdpActions are the actions available in the selfish mining MDP.
+var mdpActions = []Action{Adopt, Override, Match, Wait}
+
+// PolicyTable maps the states (a, h, fork) of the selfish mining MDP to the
+// action to take, where a and h are the lengths of the private and the public
+// branch since their common ancestor.
+type PolicyTable struct {
+	Alpha      float64           `json:"alpha"`      // hashrate share of the attacker
+	Gamma      float64           `json:"gamma"`      // share of the honest hashrate mining on the attacker's block in a tie
+	Truncation int               `json:"truncation"` // maximum branch length covered by the table
+	Revenue    float64           `json:"revenue"`    // relative revenue of the attacker following the policy
+	Policy     map[string]Action `json:"policy"`
+}
+
+func policyKey(a, h int, fork Fork) string {
+	return strconv.Itoa(a) + "," + strconv.Itoa(h) + "," + fork.String()
+}
+
+// Lookup returns the action for the given state, if the state is covered by
+// the table.
+func (t *PolicyTable) Lookup(a, h int, fork Fork) (Action, bool) {
+	action, ok := t.Policy[policyKey(a, h, fork)]
+	return action, ok
+}
+
+// LoadPolicyTable reads a policy table from a JSON file.
+func LoadPolicyTable(path string) (*PolicyTable, error) {
+	blob, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	table := new(PolicyTable)
+	if err := json.Unmarshal(blob, table); err != nil {
+		return nil, fmt.Errorf("invalid policy table %s: %v", path, err)
+	}
+	if len(table.Policy) == 0 {
+		return nil, fmt.Errorf("policy table %s is empty", path)
+	}
+	for key := range table.Policy {
+		if len(strings.Split(key, ",")) != 3 {
+			return nil, fmt.Errorf("policy table %s: invalid state %q", path, key)
+		}
+	}
+	return table, nil
+}
+
+// Save writes the policy table to a JSON file.
+func (t *PolicyTable) Save(path string) error {
+	blob, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, blob, 0644)
+}
+
+// transition is a possible outcome of taking an action in an MDP state.
+type transition struct {
+	next     int     // index of the resulting state
+	prob     float64 // probability of the outcome
+	attacker float64 // blocks added to the main chain by the attacker
+	honest   float64 // blocks added to the main chain by others
+}
+
+// mdp is the selfish mining MDP truncated to branches of a maximum length.
+type mdp struct {
+	truncation int
+	actions    [][][]transition // transitions indexed by state and position in mdpActions, nil if infeasible
+}
+
+func (m *mdp) index(a, h int, fork Fork) int {
+	return (a*(m.truncation+1)+h)*3 + int(fork)
+}
+
+func newMDP(alpha, gamma float64, truncation int) *mdp {
+	m := &mdp{truncation: truncation}
+	m.actions = make([][][]transition, (truncation+1)*(truncation+1)*3)
+
+	for a := 0; a <= truncation; a++ {
+		for h := 0; h <= truncation; h++ {
+			for fork := Irrelevant; fork <= Active; fork++ {
+				actions := make([][]transition, len(mdpActions))
+				truncated := a == truncation || h == truncation
+
+				// Adopt: give up the private branch and mine on the public head
+				actions[0] = []transition{
+					{m.index(1, 0, Irrelevant), alpha, 0, float64(h)},
+					{m.index(0, 1, Relevant), 1 - alpha, 0, float64(h)},
+				}
+				// Override: publish h+1 blocks to replace the public branch
+				if a > h {
+					actions[1] = []transition{
+						{m.index(a-h, 0, Irrelevant), alpha, float64(h + 1), 0},
+						{m.index(a-h-1, 1, Relevant), 1 - alpha, float64(h + 1), 0},
+					}
+				}
+				// Match: publish h blocks to split the network
+				race := []transition{
+					{m.index(a+1, h, Active), alpha, 0, 0},
+					{m.index(a-h, 1, Relevant), gamma * (1 - alpha), float64(h), 0},
+					{m.index(a, h+1, Relevant), (1 - gamma) * (1 - alpha), 0, 0},
+				}
+				if !truncated && fork == Relevant && a >= h && h > 0 {
+					actions[2] = race
+				}
+				// Wait: keep mining on the private branch
+				if !truncated {
+					if fork == Active && a >= h && h > 0 {
+						actions[3] = race
+					} else {
+						actions[3] = []transition{
+							{m.index(a+1, h, Irrelevant), alpha, 0, 0},
+							{m.index(a, h+1, Relevant), 1 - alpha, 0, 0},
+						}
+					}
+				}
+				m.actions[m.index(a, h, fork)] = actions
+			}
+		}
+	}
+	return m
+}
+
+// solve runs relative value iteration for the average reward of the MDP, where
+// a block of the attacker is worth 1-rho and a block of others is worth -rho.
+// The values are used as starting point and updated in place.
+func (m *mdp) solve(rho, epsilon float64, values []float64) (float64, []int) {
+	const tau = 0.5 // aperiodicity transformation
+
+	var (
+		next   = make([]float64, len(values))
+		policy = make([]int, len(values))
+		gain   float64
+	)
+	for iter := 0; iter < 100000; iter++ {
+		for s, actions := range m.actions {
+			best := math.Inf(-1)
+			for i, outcomes := range actions {
+				if outcomes == nil {
+					continue
+				}
+				var v float64
+				for _, t := range outcomes {
+					v += t.prob * ((1-rho)*t.attacker - rho*t.honest + values[t.next])
+				}
+				v = tau*v + (1-tau)*values[s]
+				if v > best {
+					best, policy[s] = v, i
+				}
+			}
+			next[s] = best
+		}
+		lo, hi := math.Inf(1), math.Inf(-1)
+		for s := range next {
+			diff := next[s] - values[s]
+			lo, hi = math.Min(lo, diff), math.Max(hi, diff)
+		}
+		gain = (lo + hi) / 2 / tau
+
+		ref := next[0]
+		for s := range next {
+			values[s] = next[s] - ref
+		}
+		if hi-lo < epsilon {
+			break
+		}
+	}
+	return gain, policy
+}
+
+// Solve computes the policy maximising the relative revenue of an attacker
+// with hashrate share alpha, where a share gamma of the honest hashrate mines
+// on the attacker's block in a tie. Branches longer than truncation blocks
+// are resolved by adopting or overriding.
+func Solve(alpha, gamma float64, truncation int, epsilon float64) (*PolicyTable, error) {
+	if alpha <= 0 || alpha >= 0.5 {
+		return nil, errors.New("alpha must be in (0, 0.5)")
+	}
+	if gamma < 0 || gamma > 1 {
+		return nil, errors.New("gamma must be in [0, 1]")
+	}
+	if truncation < 2 {
+		return nil, errors.New("truncation must be at least 2")
+	}
+	m := newMDP(alpha, gamma, truncation)
+
+	// The optimal average reward decreases in rho and is zero at the optimal
+	// relative revenue, which is at least the honest share alpha.
+	var (
+		values = make([]float64, len(m.actions))
+		lo, hi = alpha, 1.0
+	)
+	for hi-lo > epsilon {
+		rho := (lo + hi) / 2
+		gain, _ := m.solve(rho, epsilon, values)
+		if gain > 0 {
+			lo = rho
+		} else {
+			hi = rho
+		}
+	}
+	_, policy := m.solve(lo, epsilon, values)
+
+	table := &PolicyTable{
+		Alpha:      alpha,
+		Gamma:      gamma,
+		Truncation: truncation,
+		Revenue:    lo,
+		Policy:     make(map[string]Action),
+	}
+	for a := 0; a <= truncation; a++ {
+		for h := 0; h <= truncation; h++ {
+			for fork := Irrelevant; fork <= Active; fork++ {
+				table.Policy[policyKey(a, h, fork)] = mdpActions[policy[m.index(a, h, fork)]]
+			}
+		}
+	}
+	return table, nil
+}
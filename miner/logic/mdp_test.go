@@ -0,0 +1,53 @@
+package logic
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+// Tests that the solver reproduces the optimal relative revenues reported by
+// Sapirshtein et al. for gamma = 0, and falls back to honest mining for small
+// attackers.
+func TestSolve(t *testing.T) {
+	tests := []struct {
+		alpha, gamma float64
+		revenue      float64
+	}{
+		{0.1, 0, 0.1},
+		{0.35, 0, 0.37077},
+		{1.0 / 3, 0, 0.33705},
+	}
+	for _, tt := range tests {
+		table, err := Solve(tt.alpha, tt.gamma, 20, 1e-6)
+		if err != nil {
+			t.Fatalf("alpha %v: failed to solve: %v", tt.alpha, err)
+		}
+		if math.Abs(table.Revenue-tt.revenue) > 1e-3 {
+			t.Errorf("alpha %v: revenue mismatch: have %v, want %v", tt.alpha, table.Revenue, tt.revenue)
+		}
+	}
+}
+
+func TestPolicyTableRoundtrip(t *testing.T) {
+	table, err := Solve(0.3, 0.5, 5, 1e-6)
+	if err != nil {
+		t.Fatalf("failed to solve: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "policy.json")
+	if err := table.Save(path); err != nil {
+		t.Fatalf("failed to save table: %v", err)
+	}
+	strategy, err := New(Optimal, &Config{PolicyFile: path})
+	if err != nil {
+		t.Fatalf("failed to create strategy: %v", err)
+	}
+	// Any sane policy adopts when far behind and overrides when ahead by one
+	// after others caught up.
+	if have := strategy.OnOthersFoundBlocks(State{PrivateLength: 10, PublicLength: 14, CommonAncestor: 10}); have != Adopt {
+		t.Errorf("behind: have %v, want %v", have, Adopt)
+	}
+	if have := strategy.OnOthersFoundBlocks(State{PrivateLength: 12, PublicLength: 11, NextToPublish: 11, CommonAncestor: 10}); have != Override {
+		t.Errorf("lead one: have %v, want %v", have, Override)
+	}
+}
@@ -0,0 +1,96 @@
+package logic
+
+import (
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+var (
+	leadGauge         = metrics.NewRegisteredGauge("selfish/lead", nil)
+	branchLengthGauge = metrics.NewRegisteredGauge("selfish/branch", nil)
+
+	decisionCounters = map[Action]metrics.Counter{
+		Wait:       metrics.NewRegisteredCounter("selfish/decisions/wait", nil),
+		Adopt:      metrics.NewRegisteredCounter("selfish/decisions/adopt", nil),
+		Match:      metrics.NewRegisteredCounter("selfish/decisions/match", nil),
+		Override:   metrics.NewRegisteredCounter("selfish/decisions/override", nil),
+		PublishOne: metrics.NewRegisteredCounter("selfish/decisions/publishone", nil),
+	}
+
+	publishedBlockCounter = metrics.NewRegisteredCounter("selfish/blocks/published", nil)
+	orphanedBlockCounter  = metrics.NewRegisteredCounter("selfish/blocks/orphaned", nil)
+	adoptedBlockCounter   = metrics.NewRegisteredCounter("selfish/blocks/adopted", nil)
+
+	divergenceCounter = metrics.NewRegisteredCounter("selfish/divergences", nil)
+
+	withholdTimer = metrics.NewRegisteredTimer("selfish/withhold", nil)
+	raceWonTimer  = metrics.NewRegisteredTimer("selfish/race/won", nil)
+	raceLostTimer = metrics.NewRegisteredTimer("selfish/race/lost", nil)
+)
+
+// race is a private block published to match a public block of the same
+// height, until the next block decides which of them stays canonical.
+type race struct {
+	number uint64
+	hash   common.Hash
+	start  time.Time
+}
+
+// trackFound records when a private block was found, to measure how long it is
+// withheld.
+func (data *MiningData) trackFound(hash common.Hash) {
+	if data.found == nil {
+		data.found = make(map[common.Hash]time.Time)
+	}
+	data.found[hash] = time.Now()
+}
+
+// trackPublished updates the metrics after a private block was published.
+func (data *MiningData) trackPublished(hash common.Hash) {
+	publishedBlockCounter.Inc(1)
+	if found, ok := data.found[hash]; ok {
+		withholdTimer.UpdateSince(found)
+		delete(data.found, hash)
+	}
+}
+
+// trackAdopted updates the metrics before the private chain adopts the public
+// chain, abandoning the private branch.
+func (data *MiningData) trackAdopted() {
+	ancestor := commonAncestor(data.PrivateChain, data.PublicChain)
+	if n := data.PrivateChain.Length() - ancestor; n > 0 {
+		orphanedBlockCounter.Inc(int64(n))
+	}
+	if n := data.PublicChain.Length() - ancestor; n > 0 {
+		adoptedBlockCounter.Inc(int64(n))
+	}
+	data.found = nil
+}
+
+// updateMetrics updates the metrics after a decision of the strategy was
+// carried out. Races are started by a match and resolved as soon as the
+// public chain extends past the racing block.
+func (data *MiningData) updateMetrics(action Action, published int) {
+	decisionCounters[action].Inc(1)
+
+	if data.race != nil && uint64(data.PublicChain.Length()) > data.race.number {
+		if data.PublicChain.GetCanonicalHash(data.race.number) == data.race.hash {
+			raceWonTimer.UpdateSince(data.race.start)
+		} else {
+			raceLostTimer.UpdateSince(data.race.start)
+		}
+		data.race = nil
+	}
+	if action == Match && *data.NextToPublish > published {
+		number := uint64(*data.NextToPublish - 1)
+		data.race = &race{
+			number: number,
+			hash:   data.PrivateChain.GetCanonicalHash(number),
+			start:  time.Now(),
+		}
+	}
+	leadGauge.Update(int64(data.lead()))
+	branchLengthGauge.Update(int64(*data.PrivateBranchLength))
+}
@@ -0,0 +1,132 @@
+package logic
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// enableTestMetrics replaces the metrics, which are no-ops unless metrics were
+// enabled at startup, with working ones. The returned function restores them.
+func enableTestMetrics() func() {
+	var (
+		enabled   = metrics.Enabled
+		lead      = leadGauge
+		branch    = branchLengthGauge
+		decisions = decisionCounters
+		published = publishedBlockCounter
+		orphaned  = orphanedBlockCounter
+		adopted   = adoptedBlockCounter
+		won, lost = raceWonTimer, raceLostTimer
+	)
+	metrics.Enabled = true
+	leadGauge, branchLengthGauge = metrics.NewGauge(), metrics.NewGauge()
+	decisionCounters = make(map[Action]metrics.Counter)
+	for action := range decisions {
+		decisionCounters[action] = metrics.NewCounter()
+	}
+	publishedBlockCounter, orphanedBlockCounter, adoptedBlockCounter = metrics.NewCounter(), metrics.NewCounter(), metrics.NewCounter()
+	raceWonTimer, raceLostTimer = metrics.NewTimer(), metrics.NewTimer()
+	metrics.Enabled = enabled
+
+	return func() {
+		leadGauge, branchLengthGauge = lead, branch
+		decisionCounters = decisions
+		publishedBlockCounter, orphanedBlockCounter, adoptedBlockCounter = published, orphaned, adopted
+		raceWonTimer, raceLostTimer = won, lost
+	}
+}
+
+// Tests that a match, an override and an adoption move the decision counters,
+// the block counters, the race timers and the gauges.
+func TestMetrics(t *testing.T) {
+	defer enableTestMetrics()()
+
+	var (
+		gspec   = &core.Genesis{Config: params.TestChainConfig}
+		genesis = gspec.MustCommit(rawdb.NewMemoryDatabase())
+		gendb   = rawdb.NewMemoryDatabase()
+	)
+	gspec.MustCommit(gendb)
+
+	// A private branch of three withheld blocks forks off after the shared
+	// block, next to a public branch that grows to four blocks.
+	shared, _ := core.GenerateChain(gspec.Config, genesis, ethash.NewFaker(), gendb, 1, nil)
+	private, _ := core.GenerateChain(gspec.Config, shared[0], ethash.NewFaker(), gendb, 3, func(i int, b *core.BlockGen) {
+		b.SetCoinbase(common.Address{0x01})
+	})
+	public, _ := core.GenerateChain(gspec.Config, shared[0], ethash.NewFaker(), gendb, 4, func(i int, b *core.BlockGen) {
+		b.SetCoinbase(common.Address{0x02})
+	})
+	privateChain, _ := newTestChain(t, gspec, shared, private)
+	publicChain, _ := newTestChain(t, gspec, shared, public[:1])
+	defer privateChain.Stop()
+	defer publicChain.Stop()
+
+	forks, err := publicChain.NewForkTree(0)
+	if err != nil {
+		t.Fatalf("failed to create fork tree: %v", err)
+	}
+	strategy, _ := New(SelfishAllUncles, nil)
+	branchLength, next := 3, 2
+	data := &MiningData{
+		PublicChain:         publicChain,
+		PrivateChain:        privateChain,
+		PrivateBranchLength: &branchLength,
+		NextToPublish:       &next,
+		MinerStrategy:       strategy,
+		EventMux:            new(event.TypeMux),
+		ForkTree:            forks,
+	}
+	decide := func(action Action, blocks types.Blocks) {
+		data.decide(data.state(), func(State) Action { return action }, public[0], "peer", blocks)
+	}
+	check := func(stage string, lead, branch, published, orphaned, adopted int64) {
+		t.Helper()
+		if have := leadGauge.Value(); have != lead {
+			t.Errorf("%s: lead mismatch: have %d, want %d", stage, have, lead)
+		}
+		if have := branchLengthGauge.Value(); have != branch {
+			t.Errorf("%s: branch length mismatch: have %d, want %d", stage, have, branch)
+		}
+		if have := publishedBlockCounter.Count(); have != published {
+			t.Errorf("%s: published blocks mismatch: have %d, want %d", stage, have, published)
+		}
+		if have := orphanedBlockCounter.Count(); have != orphaned {
+			t.Errorf("%s: orphaned blocks mismatch: have %d, want %d", stage, have, orphaned)
+		}
+		if have := adoptedBlockCounter.Count(); have != adopted {
+			t.Errorf("%s: adopted blocks mismatch: have %d, want %d", stage, have, adopted)
+		}
+	}
+	// The match publishes the first private block and starts a race
+	decide(Match, public[:1])
+	check("match", 2, 3, 1, 0, 0)
+
+	// The override publishes the second private block, which wins the race
+	decide(Override, public[:1])
+	check("override", 1, 1, 2, 0, 0)
+	if won, lost := raceWonTimer.Count(), raceLostTimer.Count(); won != 1 || lost != 0 {
+		t.Errorf("race mismatch: won %d, lost %d", won, lost)
+	}
+	// The public branch overtakes, the private branch is abandoned
+	if _, err := publicChain.InsertChain(public[1:]); err != nil {
+		t.Fatalf("failed to extend public chain: %v", err)
+	}
+	data.addForks(public[1:], core.OriginPeer, "peer")
+	decide(Adopt, public[1:])
+	check("adopt", 0, 0, 2, 3, 4)
+
+	for action, want := range map[Action]int64{Wait: 0, Match: 1, Override: 1, Adopt: 1, PublishOne: 0} {
+		if have := decisionCounters[action].Count(); have != want {
+			t.Errorf("%v decisions mismatch: have %d, want %d", action, have, want)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package logic
+
+import (
+	"errors"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func init() {
+	Register(Optimal, func(config *Config) (Strategy, error) {
+		if config.PolicyFile == "" {
+			return nil, errors.New("optimal strategy requires a policy table")
+		}
+		table, err := LoadPolicyTable(config.PolicyFile)
+		if err != nil {
+			return nil, err
+		}
+		return &optimal{table: table}, nil
+	})
+}
+
+// optimal follows a policy table computed by Solve for the selfish mining MDP
+// of Sapirshtein et al. It includes all available uncles into its blocks.
+type optimal struct {
+	table *PolicyTable
+}
+
+func (o *optimal) Name() string { return Optimal }
+
+func (o *optimal) IsHonest() bool { return false }
+
+func (o *optimal) OnFoundBlock(state State) Action {
+	return o.decide(state, Irrelevant)
+}
+
+func (o *optimal) OnOthersFoundBlocks(state State) Action {
+	return o.decide(state, Relevant)
+}
+
+// decide looks up the action for the MDP state corresponding to the chains.
+// The fork is active if the private branch has been published up to the height
+// of the public head and the race has not been decided yet.
+func (o *optimal) decide(state State, fork Fork) Action {
+	a := state.PrivateLength - state.CommonAncestor
+	h := state.PublicLength - state.CommonAncestor
+	if h > 0 && state.NextToPublish > state.PublicLength {
+		fork = Active
+	}
+	if action, ok := o.table.Lookup(a, h, fork); ok {
+		return action
+	}
+	// The state is beyond the truncation of the table, resolve the fork
+	if a > h {
+		return Override
+	}
+	return Adopt
+}
+
+func (o *optimal) FilterUncles(uncles []*types.Header, isLocal func(header *types.Header) bool) []*types.Header {
+	return allUncles.filter(uncles, isLocal)
+}
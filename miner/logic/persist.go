@@ -0,0 +1,97 @@
+package logic
+
+import (
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// persistedState is the part of the mining state that is not derivable from the
+// chains alone and is stored in the private chain database.
+type persistedState struct {
+	PrivateBranchLength uint64
+	NextToPublish       uint64
+}
+
+// persist stores the mining state in the private chain database, so that it can
+// be restored after a restart.
+func (data *MiningData) persist() {
+	if data.PrivateChainDb == nil {
+		return
+	}
+	blob, err := rlp.EncodeToBytes(&persistedState{
+		PrivateBranchLength: uint64(*data.PrivateBranchLength),
+		NextToPublish:       uint64(*data.NextToPublish),
+	})
+	if err != nil {
+		log.Error("Failed to encode the selfish mining state", "err", err)
+		return
+	}
+	rawdb.WriteSelfishMiningState(data.PrivateChainDb, blob)
+}
+
+// Restore loads the mining state stored by a previous run and reconciles it
+// with the private and public chain, which may have progressed past the stored
+// state if the node crashed. The fork tree is rebuilt from the public blocks
+// above the common ancestor, which are imported on the next adoption.
+func (data *MiningData) Restore() {
+	data.lock.Lock()
+	defer data.lock.Unlock()
+
+	if data.PrivateChainDb == nil || data.MinerStrategy.IsHonest() {
+		return
+	}
+	var (
+		stored persistedState
+		found  bool
+	)
+	if blob := rawdb.ReadSelfishMiningState(data.PrivateChainDb); len(blob) != 0 {
+		if err := rlp.DecodeBytes(blob, &stored); err != nil {
+			log.Warn("Failed to decode the selfish mining state", "err", err)
+		} else {
+			found = true
+		}
+	}
+	var (
+		private  = data.PrivateChain.Length()
+		public   = data.PublicChain.Length()
+		ancestor = commonAncestor(data.PrivateChain, data.PublicChain)
+	)
+	// The first private block the public chain doesn't know is the next one to
+	// publish, blocks are inserted into the public chain when they are published.
+	next := private + 1
+	if public >= next {
+		next = public + 1
+	}
+	for number := ancestor + 1; number <= private; number++ {
+		block := data.PrivateChain.GetBlockByNumber(uint64(number))
+		if block == nil || !data.PublicChain.HasBlock(block.Hash(), block.NumberU64()) {
+			next = number
+			break
+		}
+	}
+	if found && int(stored.NextToPublish) != next {
+		log.Warn("Reconciled next block to publish", "stored", stored.NextToPublish, "restored", next)
+	}
+	// The private branch can't be longer than the blocks mined since the fork.
+	branch := private - ancestor
+	if branch < 0 {
+		branch = 0
+	}
+	if found && int(stored.PrivateBranchLength) < branch {
+		branch = int(stored.PrivateBranchLength)
+	}
+	*data.NextToPublish = next
+	*data.PrivateBranchLength = branch
+
+	// Every public block above the common ancestor still has to be imported.
+	if forks, err := data.PublicChain.NewForkTree(uint64(ancestor)); err != nil {
+		log.Warn("Failed to rebuild the fork tree", "ancestor", ancestor, "err", err)
+	} else {
+		data.ForkTree = forks
+	}
+	data.persist()
+
+	log.Info("Restored selfish mining state", "private", private, "public", public, "ancestor", ancestor,
+		"branch", branch, "next", next, "forks", data.ForkTree.Len())
+}
@@ -0,0 +1,184 @@
+package logic
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// newTestChain creates a blockchain on top of the given genesis and inserts
+// the blocks.
+func newTestChain(t *testing.T, gspec *core.Genesis, blocks ...types.Blocks) (*core.BlockChain, ethdb.Database) {
+	db := rawdb.NewMemoryDatabase()
+	gspec.MustCommit(db)
+
+	chain, err := core.NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	for _, chunk := range blocks {
+		if _, err := chain.InsertChain(chunk); err != nil {
+			t.Fatalf("failed to insert blocks: %v", err)
+		}
+	}
+	return chain, db
+}
+
+func TestRestore(t *testing.T) {
+	var (
+		gspec   = &core.Genesis{Config: params.TestChainConfig}
+		genesis = gspec.MustCommit(rawdb.NewMemoryDatabase())
+		gendb   = rawdb.NewMemoryDatabase()
+	)
+	gspec.MustCommit(gendb)
+
+	// Both chains share two blocks. The private branch is three blocks long,
+	// the first of which has been published. The public branch is two blocks
+	// long and heavier than the published private block.
+	shared, _ := core.GenerateChain(gspec.Config, genesis, ethash.NewFaker(), gendb, 2, nil)
+	private, _ := core.GenerateChain(gspec.Config, shared[1], ethash.NewFaker(), gendb, 3, func(i int, b *core.BlockGen) {
+		b.SetCoinbase(common.Address{0x01})
+	})
+	public, _ := core.GenerateChain(gspec.Config, shared[1], ethash.NewFaker(), gendb, 2, func(i int, b *core.BlockGen) {
+		b.SetCoinbase(common.Address{0x02})
+	})
+	privateChain, privateDb := newTestChain(t, gspec, shared, private)
+	publicChain, _ := newTestChain(t, gspec, shared, public, private[:1])
+	defer privateChain.Stop()
+	defer publicChain.Stop()
+
+	strategy, _ := New(SelfishAllUncles, nil)
+	newMiningData := func() *MiningData {
+		branchLength, next := 0, 1
+		return &MiningData{
+			PublicChain:         publicChain,
+			PrivateChain:        privateChain,
+			PrivateBranchLength: &branchLength,
+			NextToPublish:       &next,
+			MinerStrategy:       strategy,
+			PrivateChainDb:      privateDb,
+			ForkTree:            core.NewForkTree(publicChain.Genesis(), publicChain.Genesis().Difficulty()),
+		}
+	}
+	// Without a stored state everything is derived from the chains
+	data := newMiningData()
+	data.Restore()
+	if *data.NextToPublish != 4 {
+		t.Errorf("next to publish mismatch: have %d, want %d", *data.NextToPublish, 4)
+	}
+	if *data.PrivateBranchLength != 3 {
+		t.Errorf("private branch length mismatch: have %d, want %d", *data.PrivateBranchLength, 3)
+	}
+	branches := data.BranchesToImport()
+	if have := len(branches); have != 2 {
+		t.Fatalf("branch count mismatch: have %d, want %d", have, 2)
+	}
+	for _, branch := range branches {
+		if branch[0].NumberU64() != 3 {
+			t.Errorf("branch starts at block %d, want %d", branch[0].NumberU64(), 3)
+		}
+	}
+	// The published private block lost against the public branch
+	if sides := data.SideBranches(); len(sides) != 1 || len(sides[0].Blocks) != 1 || sides[0].Blocks[0].Hash != private[0].Hash() {
+		t.Errorf("side branches mismatch: have %v", sides)
+	}
+	// A stored branch length is kept, a stale next block to publish is reconciled
+	blob, _ := rlp.EncodeToBytes(&persistedState{PrivateBranchLength: 2, NextToPublish: 3})
+	rawdb.WriteSelfishMiningState(privateDb, blob)
+
+	data = newMiningData()
+	data.Restore()
+	if *data.NextToPublish != 4 {
+		t.Errorf("next to publish mismatch: have %d, want %d", *data.NextToPublish, 4)
+	}
+	if *data.PrivateBranchLength != 2 {
+		t.Errorf("private branch length mismatch: have %d, want %d", *data.PrivateBranchLength, 2)
+	}
+	var stored persistedState
+	if err := rlp.DecodeBytes(rawdb.ReadSelfishMiningState(privateDb), &stored); err != nil {
+		t.Fatalf("failed to decode stored state: %v", err)
+	}
+	if stored.NextToPublish != 4 || stored.PrivateBranchLength != 2 {
+		t.Errorf("stored state mismatch: have %+v", stored)
+	}
+}
+
+// Tests that the withheld blocks and their state survive a restart of the
+// private chain.
+func TestRestoreAfterRestart(t *testing.T) {
+	var (
+		gspec   = &core.Genesis{Config: params.TestChainConfig}
+		genesis = gspec.MustCommit(rawdb.NewMemoryDatabase())
+		gendb   = rawdb.NewMemoryDatabase()
+	)
+	gspec.MustCommit(gendb)
+
+	shared, _ := core.GenerateChain(gspec.Config, genesis, ethash.NewFaker(), gendb, 1, nil)
+	private, _ := core.GenerateChain(gspec.Config, shared[0], ethash.NewFaker(), gendb, 3, func(i int, b *core.BlockGen) {
+		b.SetCoinbase(common.Address{0x01})
+	})
+	publicChain, _ := newTestChain(t, gspec, shared)
+	defer publicChain.Stop()
+
+	// The private chain prunes, the state of its head is only held in memory
+	// until the chain is stopped
+	privateDb := rawdb.NewOverlayDatabase(rawdb.NewMemoryDatabase(), publicChain.SharedReader())
+	gspec.MustCommit(privateDb)
+	newPrivateChain := func() *core.BlockChain {
+		chain, err := core.NewBlockChain(privateDb, nil, gspec.Config, ethash.NewFaker(), vm.Config{}, nil, nil)
+		if err != nil {
+			t.Fatalf("failed to create private chain: %v", err)
+		}
+		return chain
+	}
+	strategy, _ := New(SelfishAllUncles, nil)
+	newMiningData := func(chain *core.BlockChain) *MiningData {
+		branchLength, next := 0, 1
+		return &MiningData{
+			PublicChain:         publicChain,
+			PrivateChain:        chain,
+			PrivateBranchLength: &branchLength,
+			NextToPublish:       &next,
+			MinerStrategy:       strategy,
+			PrivateChainDb:      privateDb,
+			ForkTree:            core.NewForkTree(publicChain.Genesis(), publicChain.Genesis().Difficulty()),
+		}
+	}
+	privateChain := newPrivateChain()
+	for _, chunk := range []types.Blocks{shared, private} {
+		if _, err := privateChain.InsertChain(chunk); err != nil {
+			t.Fatalf("failed to insert private blocks: %v", err)
+		}
+	}
+	data := newMiningData(privateChain)
+	*data.PrivateBranchLength, *data.NextToPublish = 3, 2
+	data.persist()
+	privateChain.Stop()
+
+	privateChain = newPrivateChain()
+	defer privateChain.Stop()
+
+	head := private[len(private)-1]
+	if have := privateChain.CurrentBlock(); have.Hash() != head.Hash() {
+		t.Fatalf("private head mismatch after restart: have %d, want %d", have.NumberU64(), head.NumberU64())
+	}
+	if !privateChain.HasState(head.Root()) {
+		t.Errorf("state of the private head missing after restart")
+	}
+	data = newMiningData(privateChain)
+	data.Restore()
+	if *data.PrivateBranchLength != 3 {
+		t.Errorf("private branch length mismatch: have %d, want %d", *data.PrivateBranchLength, 3)
+	}
+	if *data.NextToPublish != 2 {
+		t.Errorf("next to publish mismatch: have %d, want %d", *data.NextToPublish, 2)
+	}
+}
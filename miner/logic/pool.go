@@ -0,0 +1,156 @@
+package logic
+
+import (
+	"bytes"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// PoolBlockEvent is posted when a selfish miner of a pool has written a block
+// it withholds to its private chain, to share it with the other members.
+type PoolBlockEvent struct{ Block *types.Block }
+
+// PoolDecisionEvent is posted when a selfish miner of a pool has carried out a
+// publish decision, so that the other members follow it.
+type PoolDecisionEvent struct {
+	Action    Action
+	Head      common.Hash // private head the decision was taken on, the adopted public head for adoptions
+	Number    uint64      // number of the head
+	Published uint64      // number of the last published private block
+}
+
+// shareBlock hands a withheld block to the pool members.
+func (data *MiningData) shareBlock(block *types.Block) {
+	if data.Pool {
+		data.EventMux.Post(PoolBlockEvent{Block: block})
+	}
+}
+
+// shareDecision hands a decision that was just carried out to the pool members.
+func (data *MiningData) shareDecision(action Action) {
+	if !data.Pool || action == Wait {
+		return
+	}
+	head := data.PrivateChain.CurrentBlock()
+	data.EventMux.Post(PoolDecisionEvent{
+		Action:    action,
+		Head:      head.Hash(),
+		Number:    head.NumberU64(),
+		Published: uint64(*data.NextToPublish - 1),
+	})
+}
+
+// poolPrefers reports whether the pool members mine on the first block rather
+// than on the second one. Blocks of the same height and total difficulty, found
+// by different members at about the same time, would otherwise split the pool:
+// the members agree on the block with the lower hash.
+func (data *MiningData) poolPrefers(block, other *types.Block) bool {
+	if block.NumberU64() != other.NumberU64() || bytes.Compare(block.Hash().Bytes(), other.Hash().Bytes()) >= 0 {
+		return false
+	}
+	td := data.PrivateChain.GetTd(block.Hash(), block.NumberU64())
+	otherTd := data.PrivateChain.GetTd(other.Hash(), other.NumberU64())
+	return td != nil && otherTd != nil && td.Cmp(otherTd) == 0
+}
+
+// poolPreferred returns the block among the head and the given candidates the
+// pool members mine on.
+func (data *MiningData) poolPreferred(head *types.Block, candidates ...*types.Block) *types.Block {
+	preferred := head
+	for _, block := range candidates {
+		if data.poolPrefers(block, preferred) {
+			preferred = block
+		}
+	}
+	return preferred
+}
+
+// OnPoolBlocks inserts withheld blocks shared by a member of the mining pool,
+// received from the given peer, into the private chain. If they extend the
+// private chain, the strategy reacts to them like to a block found by this node.
+func OnPoolBlocks(blocks types.Blocks, peer string, data *MiningData) (int, error) {
+	data.lock.Lock()
+	defer data.lock.Unlock()
+
+	// Honest miners learn about the blocks once they are published
+	if data.MinerStrategy.IsHonest() {
+		return 0, nil
+	}
+	before := data.lead()
+	previous := data.PrivateChain.CurrentBlock()
+	if n, err := data.PrivateChain.InsertChain(blocks); err != nil {
+		return n, err
+	}
+	last := blocks[len(blocks)-1]
+	// Siblings of equal difficulty are reorged to at random, enforce the
+	// choice all members agree on
+	head := data.PrivateChain.CurrentBlock()
+	if preferred := data.poolPreferred(head, last, previous); preferred != head {
+		if err := data.PrivateChain.SetChainHead(preferred); err != nil {
+			log.Warn("Failed to move private chain to pool block", "number", preferred.Number(), "hash", preferred.Hash(), "err", err)
+		}
+		head = data.PrivateChain.CurrentBlock()
+	}
+	after := data.lead()
+	for _, block := range blocks {
+		data.logEvent(EventPoolBlock, block, before, after, peer)
+	}
+	if head.Hash() != last.Hash() {
+		return 0, nil
+	}
+	if grown := int(head.NumberU64()) - int(previous.NumberU64()); grown > 0 {
+		*data.PrivateBranchLength += grown
+	}
+	for _, block := range blocks {
+		data.trackFound(block.Hash())
+	}
+	data.decide(data.state(), data.MinerStrategy.OnFoundBlock, last, peer, nil)
+	return 0, nil
+}
+
+// OnPoolDecision follows a publish decision carried out by a member of the
+// mining pool, received from the given peer. Publications are followed if the
+// private chain contains the head they were decided on, adoptions if the
+// private chain is not ahead of the adopted public head.
+func OnPoolDecision(decision PoolDecisionEvent, peer string, data *MiningData) {
+	data.lock.Lock()
+	defer data.lock.Unlock()
+
+	if data.MinerStrategy.IsHonest() {
+		return
+	}
+	var (
+		state     = data.state()
+		published = *data.NextToPublish
+	)
+	switch decision.Action {
+	case Adopt:
+		if data.PublicChain.CurrentBlock().Hash() != decision.Head || state.Lead() > 0 {
+			return
+		}
+		apply(data, Adopt, nil)
+
+	case Match, Override, PublishOne:
+		if data.PrivateChain.GetCanonicalHash(decision.Number) != decision.Head || int(decision.Published) < published {
+			return
+		}
+		publishUpTo(data, int(decision.Published), decision.Action == Match)
+		if decision.Action == Override {
+			if rest := data.PrivateChain.Length() - int(decision.Published); rest > 0 {
+				*data.PrivateBranchLength = rest
+			} else {
+				*data.PrivateBranchLength = 0
+			}
+		}
+	default:
+		return
+	}
+	data.pruneForks()
+	data.persist()
+	data.updateMetrics(decision.Action, published)
+
+	head := data.PrivateChain.CurrentBlock()
+	data.logEvent(EventPoolDecision, head, state.LengthLead(), data.lead(), peer, "action", decision.Action.String())
+}
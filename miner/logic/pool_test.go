@@ -0,0 +1,94 @@
+package logic
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Tests that a withheld block shared by a pool member extends the private chain
+// of the other members, that siblings of the same difficulty are resolved the
+// same way by all members, and that the publish decisions of a member are
+// followed.
+func TestPoolSharing(t *testing.T) {
+	var (
+		gspec   = &core.Genesis{Config: params.TestChainConfig}
+		genesis = gspec.MustCommit(rawdb.NewMemoryDatabase())
+		gendb   = rawdb.NewMemoryDatabase()
+	)
+	gspec.MustCommit(gendb)
+
+	shared, _ := core.GenerateChain(gspec.Config, genesis, ethash.NewFaker(), gendb, 1, func(i int, b *core.BlockGen) {
+		b.SetCoinbase(common.Address{0x01})
+	})
+	own, _ := core.GenerateChain(gspec.Config, genesis, ethash.NewFaker(), gendb, 1, func(i int, b *core.BlockGen) {
+		b.SetCoinbase(common.Address{0x02})
+	})
+	newData := func(private ...*types.Block) *MiningData {
+		privateChain, privateDb := newTestChain(t, gspec, private)
+		publicChain, _ := newTestChain(t, gspec)
+		t.Cleanup(privateChain.Stop)
+		t.Cleanup(publicChain.Stop)
+
+		forks, err := publicChain.NewForkTree(0)
+		if err != nil {
+			t.Fatalf("failed to create fork tree: %v", err)
+		}
+		selfish, _ := New(SelfishAllUncles, nil)
+		branchLength, next := len(private), 1
+		return &MiningData{
+			PublicChain:         publicChain,
+			PrivateChain:        privateChain,
+			PrivateBranchLength: &branchLength,
+			NextToPublish:       &next,
+			MinerStrategy:       selfish,
+			Pool:                true,
+			EventMux:            new(event.TypeMux),
+			EventLog:            log.New(),
+			PrivateChainDb:      privateDb,
+			ForkTree:            forks,
+		}
+	}
+	// A shared block on top of the private chain is adopted as its head
+	data := newData()
+	if _, err := OnPoolBlocks(shared, "peer", data); err != nil {
+		t.Fatalf("failed to insert pool block: %v", err)
+	}
+	if head := data.PrivateChain.CurrentBlock(); head.Hash() != shared[0].Hash() {
+		t.Fatalf("private head mismatch: have %x, want %x", head.Hash(), shared[0].Hash())
+	}
+	if *data.PrivateBranchLength != 1 {
+		t.Errorf("private branch length mismatch: have %d, want 1", *data.PrivateBranchLength)
+	}
+	// The publication of the shared block by its finder is followed
+	OnPoolDecision(PoolDecisionEvent{Action: Override, Head: shared[0].Hash(), Number: 1, Published: 1}, "peer", data)
+	if !data.PublicChain.HasBlock(shared[0].Hash(), 1) {
+		t.Errorf("shared block not published")
+	}
+	if *data.NextToPublish != 2 || *data.PrivateBranchLength != 0 {
+		t.Errorf("state mismatch: next to publish %d, branch length %d, want 2, 0", *data.NextToPublish, *data.PrivateBranchLength)
+	}
+	// Siblings of the same difficulty are resolved by the lower hash
+	data = newData(own...)
+	if _, err := OnPoolBlocks(shared, "peer", data); err != nil {
+		t.Fatalf("failed to insert pool block: %v", err)
+	}
+	want := shared[0]
+	if bytes.Compare(own[0].Hash().Bytes(), want.Hash().Bytes()) < 0 {
+		want = own[0]
+	}
+	if head := data.PrivateChain.CurrentBlock(); head.Hash() != want.Hash() {
+		t.Errorf("private head mismatch: have %x, want %x", head.Hash(), want.Hash())
+	}
+	if *data.PrivateBranchLength != 1 {
+		t.Errorf("private branch length mismatch: have %d, want 1", *data.PrivateBranchLength)
+	}
+}
@@ -0,0 +1,294 @@
+package logic
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+// maxUncleDepth is the maximum distance between an uncle and the block
+// including it.
+const maxUncleDepth = 7
+
+// MinerRevenue is the revenue a coinbase earned on the canonical chain.
+type MinerRevenue struct {
+	Coinbase      common.Address `json:"coinbase"`
+	Blocks        uint64         `json:"blocks"`        // canonical blocks mined
+	Uncles        uint64         `json:"uncles"`        // blocks mined that were included as uncles
+	Orphans       uint64         `json:"orphans"`       // blocks mined that are neither canonical nor included as uncles
+	BlockRewards  *hexutil.Big   `json:"blockRewards"`  // static rewards of the canonical blocks
+	UncleRewards  *hexutil.Big   `json:"uncleRewards"`  // rewards of the blocks included as uncles
+	NephewRewards *hexutil.Big   `json:"nephewRewards"` // rewards for including uncles
+	Fees          *hexutil.Big   `json:"fees"`          // transaction fees paid to the miner
+	Total         *hexutil.Big   `json:"total"`
+
+	RelativeRevenue float64 `json:"relativeRevenue"` // share of the total revenue of all miners
+	BlockShare      float64 `json:"blockShare"`      // share of the canonical blocks
+	RevenueRate     float64 `json:"revenueRate"`     // revenue in wei per second of the window
+}
+
+func newMinerRevenue(coinbase common.Address) *MinerRevenue {
+	return &MinerRevenue{
+		Coinbase:      coinbase,
+		BlockRewards:  new(hexutil.Big),
+		UncleRewards:  new(hexutil.Big),
+		NephewRewards: new(hexutil.Big),
+		Fees:          new(hexutil.Big),
+		Total:         new(hexutil.Big),
+	}
+}
+
+// RevenueWindow is the revenue of the miners over a range of canonical blocks.
+// Rates are given per second of the window, which is the time between the
+// block preceding the window and its last block, leaving out the time between
+// the genesis and the first block. The times are when the node first saw the
+// blocks if it saw both, otherwise their timestamps. Timestamps are chosen by
+// the miners, and withheld blocks are usually backdated, so a window ending in
+// withheld blocks looks shorter than it was and its rates come out too high.
+type RevenueWindow struct {
+	From          uint64          `json:"from"`
+	To            uint64          `json:"to"`
+	Blocks        uint64          `json:"blocks"`        // canonical blocks in the window
+	Orphans       uint64          `json:"orphans"`       // orphaned blocks in the window
+	Duration      uint64          `json:"duration"`      // time covered by the window, in seconds
+	Timed         bool            `json:"timed"`         // whether the duration was measured by first-seen times
+	Difficulty    *hexutil.Big    `json:"difficulty"`    // mean difficulty of the canonical blocks
+	Total         *hexutil.Big    `json:"total"`         // revenue of all miners
+	AttackerShare float64         `json:"attackerShare"` // relative revenue of the attacker
+	AttackerRate  float64         `json:"attackerRate"`  // revenue of the attacker in wei per second of the window
+	Miners        []*MinerRevenue `json:"miners"`        // sorted by revenue, highest first
+
+	miners  map[common.Address]*MinerRevenue
+	elapsed time.Duration // exact time covered by the window
+}
+
+func newRevenueWindow(from, to uint64) *RevenueWindow {
+	return &RevenueWindow{
+		From:       from,
+		To:         to,
+		Difficulty: new(hexutil.Big),
+		Total:      new(hexutil.Big),
+		miners:     make(map[common.Address]*MinerRevenue),
+	}
+}
+
+func (w *RevenueWindow) miner(coinbase common.Address) *MinerRevenue {
+	miner, ok := w.miners[coinbase]
+	if !ok {
+		miner = newMinerRevenue(coinbase)
+		w.miners[coinbase] = miner
+	}
+	return miner
+}
+
+// finalize computes the totals and shares once all blocks have been accounted.
+func (w *RevenueWindow) finalize(attacker common.Address) {
+	w.Miners = make([]*MinerRevenue, 0, len(w.miners))
+	for _, miner := range w.miners {
+		total := w.Total.ToInt()
+		for _, amount := range []*hexutil.Big{miner.BlockRewards, miner.UncleRewards, miner.NephewRewards, miner.Fees} {
+			miner.Total.ToInt().Add(miner.Total.ToInt(), amount.ToInt())
+			total.Add(total, amount.ToInt())
+		}
+		w.Miners = append(w.Miners, miner)
+	}
+	if w.Blocks > 0 {
+		// The difficulties were summed up while accounting the blocks
+		difficulty := w.Difficulty.ToInt()
+		difficulty.Div(difficulty, new(big.Int).SetUint64(w.Blocks))
+	}
+	for _, miner := range w.Miners {
+		miner.RelativeRevenue = ratio(miner.Total.ToInt(), w.Total.ToInt())
+		if w.elapsed > 0 {
+			total, _ := new(big.Float).SetInt(miner.Total.ToInt()).Float64()
+			miner.RevenueRate = total / w.elapsed.Seconds()
+		}
+		if w.Blocks > 0 {
+			miner.BlockShare = float64(miner.Blocks) / float64(w.Blocks)
+		}
+		if miner.Coinbase == attacker {
+			w.AttackerShare = miner.RelativeRevenue
+			w.AttackerRate = miner.RevenueRate
+		}
+	}
+	sort.Slice(w.Miners, func(i, j int) bool {
+		if cmp := w.Miners[i].Total.ToInt().Cmp(w.Miners[j].Total.ToInt()); cmp != 0 {
+			return cmp > 0
+		}
+		return bytes.Compare(w.Miners[i].Coinbase[:], w.Miners[j].Coinbase[:]) < 0
+	})
+}
+
+func ratio(x, y *big.Int) float64 {
+	if y.Sign() == 0 {
+		return 0
+	}
+	r, _ := new(big.Float).Quo(new(big.Float).SetInt(x), new(big.Float).SetInt(y)).Float64()
+	return r
+}
+
+// RevenueReport is the revenue of the miners over a range of canonical blocks,
+// optionally split into windows of equal size.
+type RevenueReport struct {
+	RevenueWindow
+	Attacker common.Address   `json:"attacker"`
+	Windows  []*RevenueWindow `json:"windows,omitempty"`
+}
+
+// AccountRevenue attributes the block rewards, uncle and nephew rewards and the
+// transaction fees of the canonical blocks from..to to the coinbases, following
+// the rewards credited by ethash. Blocks of the range that are neither canonical
+// nor included as uncles are counted as orphans. If window is non-zero, the
+// range is additionally split into windows of the given number of blocks. The
+// database is used to find the non-canonical blocks of the chain. The times the
+// windows cover are measured by the first-seen times of the blocks looked up
+// with firstSeen if not nil, falling back to the block timestamps.
+func AccountRevenue(chain *core.BlockChain, db ethdb.Iteratee, from, to, window uint64, attacker common.Address, firstSeen func(common.Hash) (time.Time, bool)) (*RevenueReport, error) {
+	head := chain.CurrentBlock().NumberU64()
+	if to > head {
+		to = head
+	}
+	if from == 0 {
+		from = 1 // the genesis block has no miner
+	}
+	if from > to {
+		return nil, errors.New("empty block range")
+	}
+	report := &RevenueReport{
+		RevenueWindow: *newRevenueWindow(from, to),
+		Attacker:      attacker,
+	}
+	windows := []*RevenueWindow{&report.RevenueWindow}
+	if window > 0 {
+		for start := from; start <= to; start += window {
+			end := start + window - 1
+			if end > to {
+				end = to
+			}
+			report.Windows = append(report.Windows, newRevenueWindow(start, end))
+		}
+	}
+	// accountTo returns the accounting of the block with the given number
+	accountTo := func(number uint64) []*RevenueWindow {
+		if window == 0 {
+			return windows
+		}
+		return []*RevenueWindow{windows[0], report.Windows[(number-from)/window]}
+	}
+	var (
+		config   = chain.Config()
+		included = make(map[common.Hash]bool)
+	)
+	for number := from; number <= to; number++ {
+		block := chain.GetBlockByNumber(number)
+		if block == nil {
+			return nil, errors.New("missing canonical block")
+		}
+		var (
+			header      = block.Header()
+			blockReward = ethash.BlockReward(config, header.Number)
+			fees        = new(big.Int)
+		)
+		receipts := chain.GetReceiptsByHash(block.Hash())
+		if len(receipts) != len(block.Transactions()) {
+			return nil, errors.New("missing receipts")
+		}
+		for i, tx := range block.Transactions() {
+			tip := tx.EffectiveGasTipValue(header.BaseFee)
+			fees.Add(fees, tip.Mul(tip, new(big.Int).SetUint64(receipts[i].GasUsed)))
+		}
+		for _, w := range accountTo(number) {
+			w.Blocks++
+			w.Difficulty.ToInt().Add(w.Difficulty.ToInt(), header.Difficulty)
+			miner := w.miner(header.Coinbase)
+			miner.Blocks++
+			miner.BlockRewards.ToInt().Add(miner.BlockRewards.ToInt(), blockReward)
+			miner.Fees.ToInt().Add(miner.Fees.ToInt(), fees)
+		}
+		// Uncle rewards as in ethash.accumulateRewards
+		for _, uncle := range block.Uncles() {
+			included[uncle.Hash()] = true
+
+			reward := new(big.Int).Add(uncle.Number, big.NewInt(8))
+			reward.Sub(reward, header.Number)
+			reward.Mul(reward, blockReward)
+			reward.Div(reward, big.NewInt(8))
+			nephew := new(big.Int).Div(blockReward, big.NewInt(32))
+
+			for _, w := range accountTo(number) {
+				miner := w.miner(uncle.Coinbase)
+				miner.Uncles++
+				miner.UncleRewards.ToInt().Add(miner.UncleRewards.ToInt(), reward)
+
+				miner = w.miner(header.Coinbase)
+				miner.NephewRewards.ToInt().Add(miner.NephewRewards.ToInt(), nephew)
+			}
+		}
+	}
+	// Blocks near the end of the range may still be included by later blocks
+	for number := to + 1; number <= to+maxUncleDepth && number <= head; number++ {
+		if block := chain.GetBlockByNumber(number); block != nil {
+			for _, uncle := range block.Uncles() {
+				included[uncle.Hash()] = true
+			}
+		}
+	}
+	for _, entry := range rawdb.ReadAllHashesInRange(db, from, to) {
+		if included[entry.Hash] || chain.GetCanonicalHash(entry.Number) == entry.Hash {
+			continue
+		}
+		header := chain.GetHeader(entry.Hash, entry.Number)
+		if header == nil {
+			continue
+		}
+		for _, w := range accountTo(entry.Number) {
+			w.Orphans++
+			w.miner(header.Coinbase).Orphans++
+		}
+	}
+	for _, w := range append(windows, report.Windows...) {
+		// The genesis timestamp is arbitrary, so the time to the first block
+		// doesn't count
+		start := w.From - 1
+		if start == 0 {
+			start = 1
+		}
+		first, last := chain.GetHeaderByNumber(start), chain.GetHeaderByNumber(w.To)
+		if first == nil || last == nil {
+			return nil, errors.New("missing canonical header")
+		}
+		w.elapsed, w.Timed = elapsed(first, last, firstSeen)
+		w.Duration = uint64(w.elapsed / time.Second)
+		w.finalize(attacker)
+	}
+	return report, nil
+}
+
+// elapsed returns the time between two blocks, measured by the times they were
+// first seen if both are known, otherwise by their timestamps.
+func elapsed(first, last *types.Header, firstSeen func(common.Hash) (time.Time, bool)) (time.Duration, bool) {
+	if firstSeen != nil {
+		from, fromOk := firstSeen(first.Hash())
+		to, toOk := firstSeen(last.Hash())
+		if fromOk && toOk {
+			if to.Before(from) {
+				return 0, true
+			}
+			return to.Sub(from), true
+		}
+	}
+	if last.Time < first.Time {
+		return 0, false
+	}
+	return time.Duration(last.Time-first.Time) * time.Second, false
+}
@@ -0,0 +1,141 @@
+package logic
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func TestAccountRevenue(t *testing.T) {
+	var (
+		gspec   = &core.Genesis{Config: params.TestChainConfig}
+		genesis = gspec.MustCommit(rawdb.NewMemoryDatabase())
+		gendb   = rawdb.NewMemoryDatabase()
+
+		attacker = common.Address{0x01}
+		honest   = common.Address{0x02}
+		uncler   = common.Address{0x03}
+	)
+	gspec.MustCommit(gendb)
+
+	// The third block includes an uncle, another side block is orphaned
+	blocks, _ := core.GenerateChain(gspec.Config, genesis, ethash.NewFaker(), gendb, 4, func(i int, b *core.BlockGen) {
+		if i != 2 {
+			b.SetCoinbase(attacker)
+			return
+		}
+		b.SetCoinbase(honest)
+		b.AddUncle(&types.Header{ParentHash: b.PrevBlock(0).Hash(), Number: big.NewInt(2), Coinbase: uncler})
+	})
+	orphan, _ := core.GenerateChain(gspec.Config, blocks[0], ethash.NewFaker(), gendb, 1, func(i int, b *core.BlockGen) {
+		b.SetCoinbase(uncler)
+	})
+	chain, db := newTestChain(t, gspec, blocks, orphan)
+	defer chain.Stop()
+
+	report, err := AccountRevenue(chain, db, 0, 100, 3, attacker, nil)
+	if err != nil {
+		t.Fatalf("failed to account revenue: %v", err)
+	}
+	if report.From != 1 || report.To != 4 || report.Blocks != 4 || report.Orphans != 1 {
+		t.Errorf("range mismatch: have %d..%d with %d blocks and %d orphans", report.From, report.To, report.Blocks, report.Orphans)
+	}
+	ether := func(n float64) *big.Int {
+		v, _ := new(big.Float).Mul(big.NewFloat(n), big.NewFloat(params.Ether)).Int(nil)
+		return v
+	}
+	want := map[common.Address]struct {
+		blocks, uncles, orphans uint64
+		total                   *big.Int
+	}{
+		attacker: {3, 0, 0, ether(6)},
+		honest:   {1, 0, 0, ether(2 + 2.0/32)},
+		uncler:   {0, 1, 1, ether(2 * 7.0 / 8)},
+	}
+	if len(report.Miners) != len(want) {
+		t.Fatalf("miner count mismatch: have %d, want %d", len(report.Miners), len(want))
+	}
+	for _, miner := range report.Miners {
+		w := want[miner.Coinbase]
+		if miner.Blocks != w.blocks || miner.Uncles != w.uncles || miner.Orphans != w.orphans {
+			t.Errorf("%x: block counts mismatch: have %d/%d/%d, want %d/%d/%d", miner.Coinbase,
+				miner.Blocks, miner.Uncles, miner.Orphans, w.blocks, w.uncles, w.orphans)
+		}
+		if miner.Total.ToInt().Cmp(w.total) != 0 {
+			t.Errorf("%x: revenue mismatch: have %v, want %v", miner.Coinbase, miner.Total.ToInt(), w.total)
+		}
+	}
+	if report.Miners[0].Coinbase != attacker || report.AttackerShare != 6/9.8125 {
+		t.Errorf("attacker share mismatch: have %v, want %v", report.AttackerShare, 6/9.8125)
+	}
+	// The generated blocks are ten seconds apart, the genesis doesn't count
+	if report.Duration != 30 || report.Difficulty.ToInt().Sign() <= 0 {
+		t.Errorf("trajectory mismatch: have %d seconds at difficulty %v", report.Duration, report.Difficulty)
+	}
+	if rate := ratio(ether(6), big.NewInt(30)); report.AttackerRate != rate {
+		t.Errorf("attacker rate mismatch: have %v, want %v", report.AttackerRate, rate)
+	}
+	if len(report.Windows) != 2 {
+		t.Fatalf("window count mismatch: have %d, want %d", len(report.Windows), 2)
+	}
+	if w := report.Windows[1]; w.From != 4 || w.To != 4 || w.AttackerShare != 1 {
+		t.Errorf("last window mismatch: have %d..%d with attacker share %v", w.From, w.To, w.AttackerShare)
+	}
+	if w := report.Windows[1]; w.Duration != 10 || w.Difficulty.ToInt().Cmp(blocks[3].Difficulty()) != 0 {
+		t.Errorf("last window trajectory mismatch: have %d seconds at difficulty %v", w.Duration, w.Difficulty)
+	}
+}
+
+func TestAccountRevenueFirstSeen(t *testing.T) {
+	var (
+		gspec   = &core.Genesis{Config: params.TestChainConfig}
+		genesis = gspec.MustCommit(rawdb.NewMemoryDatabase())
+		gendb   = rawdb.NewMemoryDatabase()
+	)
+	gspec.MustCommit(gendb)
+
+	// The last two blocks are backdated as if they were withheld
+	blocks, _ := core.GenerateChain(gspec.Config, genesis, ethash.NewFaker(), gendb, 4, func(i int, b *core.BlockGen) {
+		if i >= 2 {
+			b.OffsetTime(-9)
+		}
+	})
+	chain, db := newTestChain(t, gspec, blocks)
+	defer chain.Stop()
+
+	start := time.Unix(1000, 0)
+	seen := map[common.Hash]time.Time{
+		blocks[0].Hash(): start,
+		blocks[1].Hash(): start.Add(10 * time.Second),
+		blocks[3].Hash(): start.Add(40 * time.Second),
+	}
+	firstSeen := func(hash common.Hash) (time.Time, bool) {
+		t, ok := seen[hash]
+		return t, ok
+	}
+	report, err := AccountRevenue(chain, db, 1, 4, 2, common.Address{}, firstSeen)
+	if err != nil {
+		t.Fatalf("failed to account revenue: %v", err)
+	}
+	if report.Duration != 40 || !report.Timed {
+		t.Errorf("duration mismatch: have %d seconds, timed %v, want 40 seconds timed", report.Duration, report.Timed)
+	}
+	if w := report.Windows[1]; w.Duration != 30 || !w.Timed {
+		t.Errorf("last window duration mismatch: have %d seconds, timed %v, want 30 seconds timed", w.Duration, w.Timed)
+	}
+	// Without first-seen times the backdated timestamps shorten the range
+	report, err = AccountRevenue(chain, db, 1, 4, 0, common.Address{}, nil)
+	if err != nil {
+		t.Fatalf("failed to account revenue: %v", err)
+	}
+	if report.Duration != 12 || report.Timed {
+		t.Errorf("timestamp duration mismatch: have %d seconds, timed %v, want 12 seconds untimed", report.Duration, report.Timed)
+	}
+}
@@ -0,0 +1,47 @@
+package logic
+
+import "github.com/ethereum/go-ethereum/core/types"
+
+func init() {
+	Register(SelfishNoUncles, func(*Config) (Strategy, error) { return &selfish{name: SelfishNoUncles, uncles: noUncles}, nil })
+	Register(SelfishOwnUncles, func(*Config) (Strategy, error) { return &selfish{name: SelfishOwnUncles, uncles: ownUncles}, nil })
+	Register(SelfishAllUncles, func(*Config) (Strategy, error) { return &selfish{name: SelfishAllUncles, uncles: allUncles}, nil })
+}
+
+// selfish implements the selfish mining strategy of Eyal and Sirer, "Majority
+// is not Enough: Bitcoin Mining is Vulnerable". The variants only differ in the
+// uncles they include into their blocks.
+type selfish struct {
+	name   string
+	uncles unclePolicy
+}
+
+func (s *selfish) Name() string { return s.name }
+
+func (s *selfish) IsHonest() bool { return false }
+
+func (s *selfish) OnFoundBlock(state State) Action {
+	// A tie was just won by extending the private branch: publish it all
+	// before the public chain catches up again.
+	if state.Lead() == 1 && state.PrivateBranchLength == 2 {
+		return Override
+	}
+	return Wait
+}
+
+func (s *selfish) OnOthersFoundBlocks(state State) Action {
+	switch lead := state.Lead(); {
+	case lead < 0:
+		return Adopt
+	case lead == 0:
+		return Match
+	case lead == 1:
+		return Override
+	default:
+		return PublishOne
+	}
+}
+
+func (s *selfish) FilterUncles(uncles []*types.Header, isLocal func(header *types.Header) bool) []*types.Header {
+	return s.uncles.filter(uncles, isLocal)
+}
@@ -0,0 +1,350 @@
+// Package simulation runs the mining strategies of miner/logic against a
+// simulated honest network, without p2p networking and proof-of-work.
+//
+// The attacker runs the same OnFoundBlock and OnOthersFoundBlocks code as a
+// real node, on top of in-memory blockchains. Blocks are found according to a
+// Poisson process, each by the attacker with probability alpha. The honest
+// network is modelled as a single chain, whose miners prefer the attacker's
+// block in a tie with probability gamma.
+package simulation
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/miner/logic"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+var (
+	// Attacker is the coinbase of the blocks mined by the attacker.
+	Attacker = common.Address{0x01}
+
+	// Honest is the coinbase of the blocks mined by the honest network.
+	Honest = common.Address{0x02}
+)
+
+// Config contains the parameters of a simulation run.
+type Config struct {
+	Strategy       string       // Name of the strategy of the attacker
+	StrategyConfig logic.Config // Parameters of the strategy
+	Alpha          float64      // Hashrate share of the attacker
+	Gamma          float64      // Share of the honest hashrate mining on the attacker's block in a tie
+	Delay          float64      // Propagation delay between the attacker and the network, in mean block intervals
+	Blocks         int          // Number of blocks mined in the run
+	Seed           int64        // Seed of the block arrivals and tie breaking
+}
+
+// Result is the outcome of a simulation run.
+type Result struct {
+	Config  Config
+	Revenue float64              // Relative revenue of the attacker on the final chain
+	Report  *logic.RevenueReport // Revenue accounting of the final chain of the network
+}
+
+// SelfishRevenue returns the relative revenue of the selfish mining strategy
+// in the closed form of Eyal and Sirer, "Majority is not Enough: Bitcoin Mining
+// is Vulnerable".
+func SelfishRevenue(alpha, gamma float64) float64 {
+	return (alpha*(1-alpha)*(1-alpha)*(4*alpha+gamma*(1-2*alpha)) - alpha*alpha*alpha) /
+		(1 - alpha*(1+(2-alpha)*alpha))
+}
+
+// Run simulates the mining of the configured number of blocks by the attacker
+// and the honest network.
+func Run(config Config) (*Result, error) {
+	if config.Alpha < 0 || config.Alpha > 1 {
+		return nil, errors.New("alpha must be in [0, 1]")
+	}
+	if config.Gamma < 0 || config.Gamma > 1 {
+		return nil, errors.New("gamma must be in [0, 1]")
+	}
+	if config.Delay < 0 {
+		return nil, errors.New("delay must not be negative")
+	}
+	if config.Blocks <= 0 {
+		return nil, errors.New("no blocks to mine")
+	}
+	strategy, err := logic.New(config.Strategy, &config.StrategyConfig)
+	if err != nil {
+		return nil, err
+	}
+	s, err := newSimulator(config, strategy)
+	if err != nil {
+		return nil, err
+	}
+	defer s.stop()
+
+	if err := s.run(); err != nil {
+		return nil, err
+	}
+	head := s.network.CurrentBlock().NumberU64()
+	report, err := logic.AccountRevenue(s.network, s.networkDb, 1, head, 0, Attacker, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &Result{Config: config, Revenue: report.AttackerShare, Report: report}, nil
+}
+
+// Kinds of simulation events.
+const (
+	mineEvent       = iota // the next block is found
+	toAttackerEvent        // an honest block reaches the attacker
+	toNetworkEvent         // a block published by the attacker reaches the network
+)
+
+type simEvent struct {
+	time  float64
+	seq   int // tie breaker keeping events of the same time in order
+	kind  int
+	block *types.Block
+}
+
+// eventQueue is a priority queue of events ordered by time.
+type eventQueue []*simEvent
+
+func (q eventQueue) Len() int { return len(q) }
+func (q eventQueue) Less(i, j int) bool {
+	if q[i].time != q[j].time {
+		return q[i].time < q[j].time
+	}
+	return q[i].seq < q[j].seq
+}
+func (q eventQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *eventQueue) Push(x interface{}) { *q = append(*q, x.(*simEvent)) }
+func (q *eventQueue) Pop() interface{} {
+	old := *q
+	ev := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return ev
+}
+
+type simulator struct {
+	config Config
+	rand   *rand.Rand
+	engine consensus.Engine
+	gendb  ethdb.Database // database holding the state of every generated block
+
+	data      *logic.MiningData
+	network   *core.BlockChain
+	networkDb ethdb.Database
+
+	withheld  map[common.Hash]*types.Block // blocks of the attacker not published yet
+	published map[uint64]*types.Block      // latest block published by the attacker per number
+
+	queue eventQueue
+	now   float64
+	seq   int
+}
+
+func newSimulator(config Config, strategy logic.Strategy) (*simulator, error) {
+	var (
+		gspec  = &core.Genesis{Config: params.TestChainConfig}
+		engine = ethash.NewFaker()
+		gendb  = rawdb.NewMemoryDatabase()
+	)
+	gspec.MustCommit(gendb)
+
+	// Every chain breaks the ties of its fork choice with its own source seeded
+	// from the simulation seed, so that runs are reproducible. A chain with a
+	// shared database reads the blocks and state it lacks from it.
+	newChain := func(seed int64, shared ethdb.Database, preserve func(header *types.Header) bool) (*core.BlockChain, ethdb.Database, error) {
+		db := rawdb.NewMemoryDatabase()
+		if shared != nil {
+			db = rawdb.NewOverlayDatabase(db, shared)
+		}
+		gspec.MustCommit(db)
+
+		// Keep all state, adopting a branch needs the state of the fork point
+		cacheConfig := &core.CacheConfig{
+			TrieCleanLimit:    16,
+			TrieDirtyLimit:    16,
+			TrieDirtyDisabled: true,
+			TrieTimeLimit:     5 * time.Minute,
+		}
+		chain, err := core.NewBlockChain(db, cacheConfig, gspec.Config, engine, vm.Config{}, preserve, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		chain.SetForkChoiceSeed(seed)
+		return chain, db, nil
+	}
+	public, publicDb, err := newChain(config.Seed+1, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	private, _, err := newChain(config.Seed+2, publicDb, nil)
+	if err != nil {
+		public.Stop()
+		return nil, err
+	}
+	// Honest miners keep their own block in a tie, the choice of the attacker's
+	// block is made explicitly according to gamma.
+	network, networkDb, err := newChain(config.Seed+3, nil, func(header *types.Header) bool { return header.Coinbase == Honest })
+	if err != nil {
+		public.Stop()
+		private.Stop()
+		return nil, err
+	}
+	branchLength, next := 0, 1
+	s := &simulator{
+		config: config,
+		rand:   rand.New(rand.NewSource(config.Seed)),
+		engine: engine,
+		gendb:  gendb,
+		data: &logic.MiningData{
+			PublicChain:         public,
+			PrivateChain:        private,
+			PrivateBranchLength: &branchLength,
+			NextToPublish:       &next,
+			MinerStrategy:       strategy,
+			Coinbase:            Attacker,
+			EventMux:            new(event.TypeMux),
+			ForkTree:            core.NewForkTree(public.Genesis(), public.Genesis().Difficulty()),
+		},
+		network:   network,
+		networkDb: networkDb,
+		withheld:  make(map[common.Hash]*types.Block),
+		published: make(map[uint64]*types.Block),
+	}
+	return s, nil
+}
+
+func (s *simulator) stop() {
+	s.data.PublicChain.Stop()
+	s.data.PrivateChain.Stop()
+	s.network.Stop()
+	s.engine.Close()
+}
+
+// schedule adds an event after the given delay.
+func (s *simulator) schedule(delay float64, kind int, block *types.Block) {
+	s.seq++
+	heap.Push(&s.queue, &simEvent{time: s.now + delay, seq: s.seq, kind: kind, block: block})
+}
+
+func (s *simulator) run() error {
+	s.schedule(s.rand.ExpFloat64(), mineEvent, nil)
+
+	for mined := 0; s.queue.Len() > 0; {
+		ev := heap.Pop(&s.queue).(*simEvent)
+		s.now = ev.time
+
+		switch ev.kind {
+		case mineEvent:
+			var err error
+			if s.rand.Float64() < s.config.Alpha {
+				err = s.attackerMines()
+			} else {
+				err = s.honestMines()
+			}
+			if err != nil {
+				return err
+			}
+			if mined++; mined < s.config.Blocks {
+				s.schedule(s.rand.ExpFloat64(), mineEvent, nil)
+			}
+		case toAttackerEvent:
+			if _, err := logic.OnOthersFoundBlocks(types.Blocks{ev.block}, "network", s.data); err != nil {
+				log.Debug("Attacker rejected honest block", "number", ev.block.Number(), "err", err)
+			}
+			s.publish()
+		case toNetworkEvent:
+			if _, err := s.network.InsertChain(types.Blocks{ev.block}); err != nil {
+				return fmt.Errorf("network rejected block %d: %v", ev.block.NumberU64(), err)
+			}
+			s.published[ev.block.NumberU64()] = ev.block
+		}
+	}
+	return nil
+}
+
+// generate mines a block with the given coinbase on top of the parent.
+func (s *simulator) generate(parent *types.Block, coinbase common.Address) *types.Block {
+	blocks, _ := core.GenerateChain(s.data.PublicChain.Config(), parent, s.engine, s.gendb, 1, func(i int, b *core.BlockGen) {
+		b.SetCoinbase(coinbase)
+	})
+	return blocks[0]
+}
+
+// attackerMines lets the attacker find a block on top of the chain its strategy
+// mines on.
+func (s *simulator) attackerMines() error {
+	chain := s.data.PrivateChain
+	if s.data.Strategy().IsHonest() {
+		chain = s.data.PublicChain
+	}
+	parent := chain.CurrentBlock()
+	block := s.generate(parent, Attacker)
+
+	statedb, err := state.New(parent.Root(), chain.StateCache(), nil)
+	if err != nil {
+		return err
+	}
+	receipts, logs, _, err := chain.Processor().Process(block, statedb, vm.Config{})
+	if err != nil {
+		return err
+	}
+	s.withheld[block.Hash()] = block
+	logic.OnFoundBlock(s.data, chain, block, receipts, logs, statedb)
+	s.publish()
+	return nil
+}
+
+// honestMines lets the honest network find a block. In a tie with a block of
+// the attacker, the block is mined on the attacker's block with probability
+// gamma.
+func (s *simulator) honestMines() error {
+	parent := s.network.CurrentBlock()
+	if rival, ok := s.published[parent.NumberU64()]; ok && rival.Hash() != parent.Hash() {
+		if td := s.network.GetTd(rival.Hash(), rival.NumberU64()); td != nil && td.Cmp(s.network.GetTd(parent.Hash(), parent.NumberU64())) == 0 {
+			if s.rand.Float64() < s.config.Gamma {
+				parent = rival
+			}
+		}
+	}
+	block := s.generate(parent, Honest)
+	if _, err := s.network.InsertChain(types.Blocks{block}); err != nil {
+		return fmt.Errorf("network rejected block %d: %v", block.NumberU64(), err)
+	}
+	s.schedule(s.config.Delay, toAttackerEvent, block)
+	return nil
+}
+
+// publish forwards the blocks the attacker published to the network. Blocks are
+// published by inserting them into the public chain of the attacker. Withheld
+// blocks that are no longer part of the private chain were abandoned.
+func (s *simulator) publish() {
+	var published types.Blocks
+	for hash, block := range s.withheld {
+		number := block.NumberU64()
+		if s.data.PublicChain.HasBlock(hash, number) {
+			published = append(published, block)
+			delete(s.withheld, hash)
+		} else if !s.data.Strategy().IsHonest() && s.data.PrivateChain.GetCanonicalHash(number) != hash {
+			delete(s.withheld, hash)
+		}
+	}
+	// Parents have to reach the network before their children
+	sort.Slice(published, func(i, j int) bool {
+		return published[i].NumberU64() < published[j].NumberU64()
+	})
+	for _, block := range published {
+		s.schedule(s.config.Delay, toNetworkEvent, block)
+	}
+}
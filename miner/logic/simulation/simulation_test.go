@@ -0,0 +1,41 @@
+package simulation
+
+import (
+	"math"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/miner/logic"
+)
+
+func TestSelfishRevenue(t *testing.T) {
+	// The threshold of profitability is 1/3 without network capability
+	if r := SelfishRevenue(1.0/3, 0); math.Abs(r-1.0/3) > 1e-9 {
+		t.Errorf("revenue at threshold mismatch: have %v, want %v", r, 1.0/3)
+	}
+	// and any share is profitable with full network capability
+	if r := SelfishRevenue(0.1, 1); r <= 0.1 {
+		t.Errorf("revenue with full network capability not above honest share: %v", r)
+	}
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		strategy string
+		alpha    float64
+		gamma    float64
+		want     float64
+	}{
+		{logic.HONEST, 0.3, 0, 0.3},
+		{logic.SelfishAllUncles, 0.4, 0, SelfishRevenue(0.4, 0)},
+		{logic.SelfishAllUncles, 0.3, 1, SelfishRevenue(0.3, 1)},
+	}
+	for _, tt := range tests {
+		result, err := Run(Config{Strategy: tt.strategy, Alpha: tt.alpha, Gamma: tt.gamma, Blocks: 2000, Seed: 1})
+		if err != nil {
+			t.Fatalf("%s: simulation failed: %v", tt.strategy, err)
+		}
+		if math.Abs(result.Revenue-tt.want) > 0.05 {
+			t.Errorf("%s, alpha %v, gamma %v: revenue mismatch: have %v, want %v", tt.strategy, tt.alpha, tt.gamma, result.Revenue, tt.want)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package logic
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Names of the built-in strategies.
+const (
+	HONEST           = "honest"
+	SelfishNoUncles  = "selfish-no-uncles"
+	SelfishOwnUncles = "selfish-own-uncles"
+	SelfishAllUncles = "selfish-all-uncles"
+
+	LeadStubborn      = "lead-stubborn"
+	EqualForkStubborn = "equal-fork-stubborn"
+	TrailStubborn     = "trail-stubborn"
+
+	Optimal = "optimal"
+)
+
+// legacyStrategies maps the numeric values formerly accepted by --miner.strategy
+// to the names of the corresponding strategies.
+var legacyStrategies = []string{HONEST, SelfishNoUncles, SelfishOwnUncles, SelfishAllUncles}
+
+// Action is a publish decision taken by a strategy.
+type Action int
+
+const (
+	Wait       Action = iota // keep withholding the private branch
+	Adopt                    // abandon the private branch and continue on the public head
+	Match                    // publish the private blocks up to the height of the public head
+	Override                 // publish the private blocks up to one above the height of the public head
+	PublishOne               // publish the first unpublished private block
+)
+
+func (a Action) String() string {
+	switch a {
+	case Wait:
+		return "wait"
+	case Adopt:
+		return "adopt"
+	case Match:
+		return "match"
+	case Override:
+		return "override"
+	case PublishOne:
+		return "publish-one"
+	default:
+		return fmt.Sprintf("action(%d)", int(a))
+	}
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (a Action) MarshalText() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (a *Action) UnmarshalText(text []byte) error {
+	for _, action := range []Action{Wait, Adopt, Match, Override, PublishOne} {
+		if action.String() == string(text) {
+			*a = action
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown action %q", text)
+}
+
+// State is the view of the private and public chain a strategy bases its
+// decisions on.
+type State struct {
+	PrivateLength       int `json:"privateLength"`       // number of the private chain head
+	PublicLength        int `json:"publicLength"`        // number of the public chain head
+	PrivateBranchLength int `json:"privateBranchLength"` // number of blocks mined on the private branch since it was last published or adopted
+	NextToPublish       int `json:"nextToPublish"`       // number of the first private block that has not been published yet
+	CommonAncestor      int `json:"commonAncestor"`      // number of the latest block shared by the private and the public chain
+
+	TDLead    int  `json:"tdLead"`    // lead of the private chain in blocks of total difficulty, see ComparisonConfig
+	CompareTD bool `json:"compareTD"` // whether the lead is measured by total difficulty instead of length
+}
+
+// Lead returns how many blocks the private chain is ahead of the public chain,
+// by length or by total difficulty depending on the comparison mode. It is
+// negative if the private chain is behind.
+func (s State) Lead() int {
+	if s.CompareTD {
+		return s.TDLead
+	}
+	return s.LengthLead()
+}
+
+// LengthLead returns how many blocks the private chain is longer than the
+// public chain.
+func (s State) LengthLead() int {
+	return s.PrivateLength - s.PublicLength
+}
+
+// Unpublished returns the number of private blocks that have not been published.
+func (s State) Unpublished() int {
+	return s.PrivateLength - s.NextToPublish + 1
+}
+
+// WonTie reports whether the block just found by this node extends a private
+// branch that was fully published to tie with the public chain.
+func (s State) WonTie() bool {
+	return s.Lead() == 1 && s.Unpublished() == 1 && s.PrivateBranchLength >= 2
+}
+
+// Config contains the parameters of the configurable strategies.
+type Config struct {
+	TrailDepth int    // Number of blocks a trail-stubborn miner may fall behind before adopting the public chain
+	PolicyFile string // Path of the policy table followed by the optimal strategy
+	Timestamps string // Timestamp policy of the private blocks, the wall clock if empty
+}
+
+// Strategy decides how a miner reacts to blocks found by itself and by others.
+//
+// Honest strategies mine directly on the public chain and are never asked for
+// publish decisions. All other strategies mine on the private chain, and the
+// returned actions are carried out by OnFoundBlock and OnOthersFoundBlocks.
+type Strategy interface {
+	// Name returns the name the strategy is registered under.
+	Name() string
+
+	// IsHonest reports whether the strategy mines on the public chain.
+	IsHonest() bool
+
+	// OnFoundBlock is called after a block mined by this node has been written
+	// to the private chain.
+	OnFoundBlock(state State) Action
+
+	// OnOthersFoundBlocks is called after blocks mined by others have been
+	// inserted into the public chain.
+	OnOthersFoundBlocks(state State) Action
+
+	// FilterUncles selects the uncles to include into a block being mined.
+	FilterUncles(uncles []*types.Header, isLocal func(header *types.Header) bool) []*types.Header
+}
+
+var (
+	strategiesMu sync.RWMutex
+	strategies   = make(map[string]func(config *Config) (Strategy, error))
+)
+
+// Register makes a strategy available under the given name. It panics if a
+// strategy with the same name is already registered.
+func Register(name string, constructor func(config *Config) (Strategy, error)) {
+	strategiesMu.Lock()
+	defer strategiesMu.Unlock()
+
+	if _, exist := strategies[name]; exist {
+		panic("logic: strategy " + name + " registered twice")
+	}
+	strategies[name] = constructor
+}
+
+// New creates the strategy registered under the given name, or the honest one
+// if no name is given. For backwards compatibility the numeric values of the
+// former strategy enumeration are accepted as well.
+func New(name string, config *Config) (Strategy, error) {
+	if config == nil {
+		config = new(Config)
+	}
+	if name == "" {
+		name = HONEST
+	}
+	if n, err := strconv.Atoi(name); err == nil && n >= 0 && n < len(legacyStrategies) {
+		name = legacyStrategies[n]
+	}
+	strategiesMu.RLock()
+	constructor, ok := strategies[name]
+	strategiesMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown mining strategy %q", name)
+	}
+	if !validTimestampPolicy(config.Timestamps) {
+		return nil, fmt.Errorf("unknown timestamp policy %q", config.Timestamps)
+	}
+	return constructor(config)
+}
+
+// Names returns the sorted names of all registered strategies.
+func Names() []string {
+	strategiesMu.RLock()
+	defer strategiesMu.RUnlock()
+
+	names := make([]string, 0, len(strategies))
+	for name := range strategies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// unclePolicy defines which uncles a strategy includes into its blocks.
+type unclePolicy int
+
+const (
+	allUncles unclePolicy = iota
+	ownUncles
+	noUncles
+)
+
+func (p unclePolicy) filter(uncles []*types.Header, isLocal func(header *types.Header) bool) []*types.Header {
+	switch p {
+	case noUncles:
+		return nil
+	case ownUncles:
+		var filtered []*types.Header
+		for _, uncle := range uncles {
+			if isLocal(uncle) {
+				filtered = append(filtered, uncle)
+			}
+		}
+		return filtered
+	default:
+		return uncles
+	}
+}
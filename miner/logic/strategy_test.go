@@ -0,0 +1,150 @@
+package logic
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestNew(t *testing.T) {
+	for _, name := range []string{HONEST, SelfishNoUncles, SelfishOwnUncles, SelfishAllUncles} {
+		strategy, err := New(name, nil)
+		if err != nil {
+			t.Fatalf("failed to create strategy %q: %v", name, err)
+		}
+		if strategy.Name() != name {
+			t.Errorf("strategy name mismatch: have %q, want %q", strategy.Name(), name)
+		}
+		if strategy.IsHonest() != (name == HONEST) {
+			t.Errorf("strategy %q: honesty mismatch", name)
+		}
+	}
+	for i, name := range legacyStrategies {
+		strategy, err := New(string(rune('0'+i)), nil)
+		if err != nil {
+			t.Fatalf("failed to create legacy strategy %d: %v", i, err)
+		}
+		if strategy.Name() != name {
+			t.Errorf("legacy strategy %d: have %q, want %q", i, strategy.Name(), name)
+		}
+	}
+	if _, err := New("nonexistent", nil); err == nil {
+		t.Error("expected error for unknown strategy")
+	}
+}
+
+func TestSelfishDecisions(t *testing.T) {
+	strategy, _ := New(SelfishAllUncles, nil)
+
+	found := []struct {
+		lead, branch int
+		want         Action
+	}{
+		{1, 1, Wait},
+		{2, 2, Wait},
+		{1, 2, Override}, // won the tie
+		{3, 3, Wait},
+	}
+	for i, tt := range found {
+		state := State{PrivateLength: 10 + tt.lead, PublicLength: 10, PrivateBranchLength: tt.branch, NextToPublish: 11}
+		if have := strategy.OnFoundBlock(state); have != tt.want {
+			t.Errorf("own block %d: have %v, want %v", i, have, tt.want)
+		}
+	}
+	others := []struct {
+		lead int
+		want Action
+	}{
+		{-1, Adopt},
+		{0, Match},
+		{1, Override},
+		{2, PublishOne},
+		{5, PublishOne},
+	}
+	for i, tt := range others {
+		state := State{PrivateLength: 10 + tt.lead, PublicLength: 10}
+		if have := strategy.OnOthersFoundBlocks(state); have != tt.want {
+			t.Errorf("others block %d: have %v, want %v", i, have, tt.want)
+		}
+	}
+	// Unlike the stubborn strategies, the selfish strategy overrides on a
+	// branch of two blocks regardless of how much of it was published
+	ties := []struct {
+		branch, next int
+		want         Action
+	}{
+		{2, 10, Override}, // nothing published, no tie won
+		{2, 11, Override}, // won the tie
+		{3, 11, Wait},     // won a tie after a longer branch
+	}
+	for i, tt := range ties {
+		state := State{PrivateLength: 11, PublicLength: 10, PrivateBranchLength: tt.branch, NextToPublish: tt.next}
+		if have := strategy.OnFoundBlock(state); have != tt.want {
+			t.Errorf("tie %d: have %v, want %v", i, have, tt.want)
+		}
+	}
+}
+
+func TestFilterUncles(t *testing.T) {
+	var (
+		own     = &types.Header{Extra: []byte("own")}
+		foreign = &types.Header{Extra: []byte("foreign")}
+		uncles  = []*types.Header{own, foreign}
+		local   = func(header *types.Header) bool { return header == own }
+	)
+	tests := []struct {
+		name string
+		want int
+	}{
+		{HONEST, 2},
+		{SelfishNoUncles, 0},
+		{SelfishOwnUncles, 1},
+		{SelfishAllUncles, 2},
+	}
+	for _, tt := range tests {
+		strategy, _ := New(tt.name, nil)
+		if have := strategy.FilterUncles(uncles, local); len(have) != tt.want {
+			t.Errorf("%s: have %d uncles, want %d", tt.name, len(have), tt.want)
+		}
+	}
+}
+
+func TestStubbornDecisions(t *testing.T) {
+	tests := []struct {
+		name  string
+		own   bool // whether the state follows a block found by this node
+		state State
+		want  Action
+	}{
+		// Lead-stubborn matches where selfish mining overrides
+		{LeadStubborn, false, State{PrivateLength: 11, PublicLength: 10, NextToPublish: 10}, Match},
+		{LeadStubborn, false, State{PrivateLength: 9, PublicLength: 10}, Adopt},
+		{LeadStubborn, true, State{PrivateLength: 11, PublicLength: 10, PrivateBranchLength: 2, NextToPublish: 11}, Override},
+
+		// Equal-fork-stubborn keeps its block private after winning a tie
+		{EqualForkStubborn, true, State{PrivateLength: 11, PublicLength: 10, PrivateBranchLength: 2, NextToPublish: 11}, Wait},
+		{EqualForkStubborn, false, State{PrivateLength: 11, PublicLength: 10, NextToPublish: 10}, Override},
+		{EqualForkStubborn, false, State{PrivateLength: 10, PublicLength: 10, NextToPublish: 10}, Match},
+
+		// Trail-stubborn keeps a shorter private branch and races once caught up
+		{TrailStubborn, false, State{PrivateLength: 9, PublicLength: 10}, Adopt},
+		{TrailStubborn, false, State{PrivateLength: 9, PublicLength: 10, PrivateBranchLength: 2, NextToPublish: 8}, Wait},
+		{TrailStubborn, false, State{PrivateLength: 8, PublicLength: 10}, Adopt},
+		{TrailStubborn, true, State{PrivateLength: 10, PublicLength: 10, PrivateBranchLength: 3, NextToPublish: 8}, Match},
+	}
+	for i, tt := range tests {
+		strategy, err := New(tt.name, &Config{TrailDepth: 1})
+		if err != nil {
+			t.Fatalf("test %d: failed to create strategy: %v", i, err)
+		}
+		var have Action
+		if tt.own {
+			have = strategy.OnFoundBlock(tt.state)
+		} else {
+			have = strategy.OnOthersFoundBlocks(tt.state)
+		}
+		if have != tt.want {
+			t.Errorf("test %d (%s): have %v, want %v", i, tt.name, have, tt.want)
+		}
+	}
+}
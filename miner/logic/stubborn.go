@@ -0,0 +1,73 @@
+package logic
+
+import (
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+func init() {
+	Register(LeadStubborn, func(*Config) (Strategy, error) {
+		return &stubborn{name: LeadStubborn, lead: true}, nil
+	})
+	Register(EqualForkStubborn, func(*Config) (Strategy, error) {
+		return &stubborn{name: EqualForkStubborn, equalFork: true}, nil
+	})
+	Register(TrailStubborn, func(config *Config) (Strategy, error) {
+		depth := config.TrailDepth
+		if depth < 1 {
+			log.Warn("Sanitizing trail-stubborn depth", "provided", depth, "updated", 1)
+			depth = 1
+		}
+		return &stubborn{name: TrailStubborn, trail: depth}, nil
+	})
+}
+
+// stubborn implements the stubborn mining strategies of Nayak et al., "Stubborn
+// Mining: Generalizing Selfish Mining and Combining with an Eclipse Attack".
+// Each variant deviates from the selfish strategy in one situation only and
+// includes all available uncles into its blocks.
+type stubborn struct {
+	name      string
+	lead      bool // match instead of overriding when ahead by one
+	equalFork bool // keep mining privately after finding a block in a tie
+	trail     int  // number of blocks the private branch may fall behind before adopting
+}
+
+func (s *stubborn) Name() string { return s.name }
+
+func (s *stubborn) IsHonest() bool { return false }
+
+func (s *stubborn) OnFoundBlock(state State) Action {
+	switch {
+	case state.Lead() == 0:
+		// A trailing private branch caught up, race the public chain
+		return Match
+	case state.WonTie():
+		if s.equalFork {
+			return Wait
+		}
+		return Override
+	default:
+		return Wait
+	}
+}
+
+func (s *stubborn) OnOthersFoundBlocks(state State) Action {
+	switch lead := state.Lead(); {
+	case lead < -s.trail:
+		return Adopt
+	case lead < 0 && state.PrivateBranchLength == 0:
+		// Without withheld blocks there is no branch to trail with
+		return Adopt
+	case lead < 0:
+		return Wait
+	case lead == 1 && !s.lead:
+		return Override
+	default:
+		return Match
+	}
+}
+
+func (s *stubborn) FilterUncles(uncles []*types.Header, isLocal func(header *types.Header) bool) []*types.Header {
+	return allUncles.filter(uncles, isLocal)
+}
@@ -0,0 +1,240 @@
+package testbed
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/miner/logic"
+)
+
+// Duration is a time.Duration encoded as a string like "90s" or "10m" in JSON.
+type Duration time.Duration
+
+// MarshalJSON implements json.Marshaler.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *Duration) UnmarshalJSON(input []byte) error {
+	var s string
+	if err := json.Unmarshal(input, &s); err != nil {
+		return err
+	}
+	duration, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(duration)
+	return nil
+}
+
+// Intermittent configures a node to alternate between selfish and honest mining
+// phases, see logic.IntermittentConfig.
+type Intermittent struct {
+	Schedule      string                `json:"schedule"` // Schedule of the phases: blocks, time or difficulty
+	SelfishBlocks uint64                `json:"selfishBlocks,omitempty"`
+	HonestBlocks  uint64                `json:"honestBlocks,omitempty"`
+	SelfishTime   Duration              `json:"selfishTime,omitempty"`
+	HonestTime    Duration              `json:"honestTime,omitempty"`
+	SelfishAbove  *math.HexOrDecimal256 `json:"selfishAbove,omitempty"`
+	HonestBelow   *math.HexOrDecimal256 `json:"honestBelow,omitempty"`
+	Publish       bool                  `json:"publish,omitempty"` // Whether the withheld blocks are published when an honest phase starts
+}
+
+// config converts the phases into the configuration of the miner.
+func (i *Intermittent) config() logic.IntermittentConfig {
+	return logic.IntermittentConfig{
+		Schedule:      i.Schedule,
+		SelfishBlocks: i.SelfishBlocks,
+		HonestBlocks:  i.HonestBlocks,
+		SelfishTime:   time.Duration(i.SelfishTime),
+		HonestTime:    time.Duration(i.HonestTime),
+		SelfishAbove:  (*big.Int)(i.SelfishAbove),
+		HonestBelow:   (*big.Int)(i.HonestBelow),
+		Publish:       i.Publish,
+	}
+}
+
+// NodeConfig is the configuration of a node of the testbed.
+type NodeConfig struct {
+	Name       string   `json:"name"`
+	Strategy   string   `json:"strategy,omitempty"`   // Name of the mining strategy, honest if empty
+	TrailDepth int      `json:"trailDepth,omitempty"` // Trail depth of the trail-stubborn strategies
+	PolicyFile string   `json:"policyFile,omitempty"` // Policy table of the optimal strategy
+	Timestamps string   `json:"timestamps,omitempty"` // Timestamp policy of the private blocks, the wall clock if empty
+	Comparison string   `json:"comparison,omitempty"` // Comparison of the private and the public chain, by length if empty
+	TieBlocks  float64  `json:"tieBlocks,omitempty"`  // Tie threshold in blocks when comparing by total difficulty
+	Hashrate   float64  `json:"hashrate"`             // Share of the total hashrate, zero to not mine
+	Eclipse    []string `json:"eclipse,omitempty"`    // Names of the nodes eclipsed by this node
+	Pool       []string `json:"pool,omitempty"`       // Names of the colluding nodes this node shares its private chain with
+	ForkChoice string   `json:"forkChoice,omitempty"` // Fork choice rule of the public chain, by total difficulty if empty
+
+	Intermittent  *Intermittent        `json:"intermittent,omitempty"`  // Phases of the selfish strategy, selfish throughout if nil
+	EclipsePolicy *logic.EclipsePolicy `json:"eclipsePolicy,omitempty"` // Treatment of the eclipsed nodes, blocks of others withheld if nil
+}
+
+// strategyConfig returns the parameters of the strategy of the node.
+func (n *NodeConfig) strategyConfig() *logic.Config {
+	return &logic.Config{
+		TrailDepth: n.TrailDepth,
+		PolicyFile: n.PolicyFile,
+		Timestamps: n.Timestamps,
+	}
+}
+
+// comparison returns the comparison of the private and the public chain of the
+// node.
+func (n *NodeConfig) comparison() logic.ComparisonConfig {
+	return logic.ComparisonConfig{Mode: n.Comparison, TieBlocks: n.TieBlocks}
+}
+
+// Scenario describes a testbed run: the nodes, how they are connected, and how
+// long they mine.
+type Scenario struct {
+	Duration      Duration     `json:"duration"`                // Time the nodes mine
+	Seed          int64        `json:"seed"`                    // Seed the node keys and random choices are derived from, choices are random if zero
+	BlockInterval Duration     `json:"blockInterval,omitempty"` // Mean block interval of all nodes together (default = 13s)
+	Nodes         []NodeConfig `json:"nodes"`
+	Links         [][2]string  `json:"links,omitempty"` // Pairs of connected nodes, all nodes are connected if empty
+
+	ScaleDifficulty bool   `json:"scaleDifficulty,omitempty"` // Whether the block interval scales with the difficulty, so that difficulty adjustments take effect
+	RevenueWindow   uint64 `json:"revenueWindow,omitempty"`   // Number of blocks of the revenue windows, no windows if zero
+}
+
+// LoadScenario reads a scenario from a JSON file.
+func LoadScenario(path string) (*Scenario, error) {
+	blob, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	scenario := new(Scenario)
+	if err := json.Unmarshal(blob, scenario); err != nil {
+		return nil, fmt.Errorf("invalid scenario %s: %v", path, err)
+	}
+	if err := scenario.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid scenario %s: %v", path, err)
+	}
+	return scenario, nil
+}
+
+// Validate checks that the scenario can be run.
+func (s *Scenario) Validate() error {
+	if s.Duration <= 0 {
+		return errors.New("duration must be positive")
+	}
+	// Block timestamps advance by at least a second, shorter intervals would
+	// let them run ahead of the clock until blocks are rejected
+	if s.BlockInterval != 0 && s.BlockInterval < Duration(time.Second) {
+		return errors.New("block interval must be at least a second")
+	}
+	if len(s.Nodes) == 0 {
+		return errors.New("no nodes")
+	}
+	var (
+		names    = make(map[string]bool)
+		hashrate float64
+	)
+	for _, node := range s.Nodes {
+		if node.Name == "" {
+			return errors.New("node without name")
+		}
+		if names[node.Name] {
+			return fmt.Errorf("duplicate node %q", node.Name)
+		}
+		names[node.Name] = true
+
+		if _, err := logic.New(node.Strategy, node.strategyConfig()); err != nil {
+			return fmt.Errorf("node %q: %v", node.Name, err)
+		}
+		comparison := node.comparison()
+		if err := comparison.Validate(); err != nil {
+			return fmt.Errorf("node %q: %v", node.Name, err)
+		}
+		if err := (core.ForkChoiceConfig{Rule: node.ForkChoice}).Validate(); err != nil {
+			return fmt.Errorf("node %q: %v", node.Name, err)
+		}
+		if node.Intermittent != nil {
+			if node.Strategy == "" || node.Strategy == logic.HONEST {
+				return fmt.Errorf("node %q: intermittent mining requires a selfish strategy", node.Name)
+			}
+			config := node.Intermittent.config()
+			if !config.Enabled() {
+				return fmt.Errorf("node %q: intermittent mining without schedule", node.Name)
+			}
+			if err := config.Validate(); err != nil {
+				return fmt.Errorf("node %q: %v", node.Name, err)
+			}
+		}
+		if node.Hashrate < 0 || node.Hashrate > 1 {
+			return fmt.Errorf("node %q: hashrate must be in [0, 1]", node.Name)
+		}
+		hashrate += node.Hashrate
+	}
+	if hashrate > 1+1e-9 {
+		return fmt.Errorf("total hashrate %g exceeds 1", hashrate)
+	}
+	for _, node := range s.Nodes {
+		for _, name := range node.Eclipse {
+			if !names[name] || name == node.Name {
+				return fmt.Errorf("node %q: invalid eclipsed node %q", node.Name, name)
+			}
+		}
+		if node.EclipsePolicy != nil {
+			if err := node.EclipsePolicy.Validate(); err != nil {
+				return fmt.Errorf("node %q: %v", node.Name, err)
+			}
+		}
+		if len(node.Pool) > 0 && (node.Strategy == "" || node.Strategy == logic.HONEST) {
+			return fmt.Errorf("node %q: pool mining requires a selfish strategy", node.Name)
+		}
+		for _, name := range node.Pool {
+			if !names[name] || name == node.Name {
+				return fmt.Errorf("node %q: invalid pool member %q", node.Name, name)
+			}
+		}
+	}
+	for _, link := range s.Links {
+		if !names[link[0]] || !names[link[1]] || link[0] == link[1] {
+			return fmt.Errorf("invalid link %s-%s", link[0], link[1])
+		}
+	}
+	return nil
+}
+
+// links returns the pairs of connected nodes, the first node of a pair dials the
+// second. The members of a pool are always connected.
+func (s *Scenario) links() [][2]string {
+	links := s.Links
+	if len(links) == 0 {
+		for i := range s.Nodes {
+			for j := i + 1; j < len(s.Nodes); j++ {
+				links = append(links, [2]string{s.Nodes[i].Name, s.Nodes[j].Name})
+			}
+		}
+	}
+	for _, node := range s.Nodes {
+		for _, member := range node.Pool {
+			if !linked(links, node.Name, member) {
+				links = append(links, [2]string{node.Name, member})
+			}
+		}
+	}
+	return links
+}
+
+// linked reports whether the two nodes are connected by one of the links.
+func linked(links [][2]string, name, other string) bool {
+	for _, link := range links {
+		if link == [2]string{name, other} || link == [2]string{other, name} {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,350 @@
+// Package testbed runs selfish mining experiments on a network of full eth
+// nodes running in the current process, built on the in-memory node adapter of
+// p2p/simulations.
+//
+// A scenario configures the strategy, hashrate share and eclipsed peers of
+// every node and the links between them. The nodes mine for the configured
+// duration, after which the chain of every node is collected into a results
+// directory, along with the mining event log of every node.
+package testbed
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth"
+	"github.com/ethereum/go-ethereum/eth/downloader"
+	"github.com/ethereum/go-ethereum/eth/ethconfig"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/ethclient/gethclient"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/miner/logic"
+	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/simulations"
+	"github.com/ethereum/go-ethereum/p2p/simulations/adapters"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+const (
+	// serviceName is the name of the node service running the eth protocol.
+	serviceName = "selfish-eth"
+
+	connectTimeout = 30 * time.Second // Time allowed for the nodes to connect to each other
+	settleTime     = 2 * time.Second  // Time allowed for the last blocks to propagate after mining stopped
+)
+
+// Genesis returns the genesis block shared by the nodes of the testbed.
+func Genesis() *core.Genesis {
+	return &core.Genesis{
+		Config:     params.AllEthashProtocolChanges,
+		Difficulty: params.MinimumDifficulty,
+		GasLimit:   params.GenesisGasLimit,
+		Alloc:      core.GenesisAlloc{},
+	}
+}
+
+// NodeResult is the outcome of the run for a node.
+type NodeResult struct {
+	Name     string                    `json:"name"`
+	ID       enode.ID                  `json:"id"`
+	Strategy string                    `json:"strategy"`
+	Hashrate float64                   `json:"hashrate"`
+	Coinbase common.Address            `json:"coinbase"`
+	Peers    int                       `json:"peers"`
+	Head     uint64                    `json:"head"`
+	HeadHash common.Hash               `json:"headHash"`
+	Revenue  *gethclient.RevenueReport `json:"revenue,omitempty"` // Revenue on the public chain of the node, nil if no blocks were mined
+}
+
+// Result is the outcome of a run.
+type Result struct {
+	Scenario *Scenario     `json:"scenario"`
+	Start    time.Time     `json:"start"`
+	End      time.Time     `json:"end"`
+	Nodes    []*NodeResult `json:"nodes"`
+}
+
+// testbed is a running scenario.
+type testbed struct {
+	scenario *Scenario
+	dir      string
+	network  *simulations.Network
+	nodes    map[string]*adapters.NodeConfig
+	configs  map[enode.ID]NodeConfig
+	results  map[string]*NodeResult
+}
+
+// Run runs the scenario and writes the results into the given directory. The
+// directory receives a copy of the scenario, the results as results.json, and
+// the mining event log of every node under nodes/<name>.
+func Run(scenario *Scenario, dir string) (*Result, error) {
+	if err := scenario.Validate(); err != nil {
+		return nil, err
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	blob, err := json.MarshalIndent(scenario, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "scenario.json"), blob, 0644); err != nil {
+		return nil, err
+	}
+	tb := &testbed{
+		scenario: scenario,
+		dir:      dir,
+		nodes:    make(map[string]*adapters.NodeConfig),
+		configs:  make(map[enode.ID]NodeConfig),
+		results:  make(map[string]*NodeResult),
+	}
+	adapter := adapters.NewSimAdapter(adapters.LifecycleConstructors{serviceName: tb.newEthereum})
+	tb.network = simulations.NewNetwork(adapter, &simulations.NetworkConfig{ID: "testbed", DefaultService: serviceName})
+	defer tb.network.Shutdown()
+
+	if err := tb.start(); err != nil {
+		return nil, err
+	}
+	if err := tb.connect(); err != nil {
+		return nil, err
+	}
+	result := &Result{Scenario: scenario, Start: time.Now()}
+	if err := tb.mine(); err != nil {
+		return nil, err
+	}
+	result.End = time.Now()
+
+	for _, config := range scenario.Nodes {
+		if err := tb.collect(config.Name); err != nil {
+			return nil, fmt.Errorf("node %q: %v", config.Name, err)
+		}
+		result.Nodes = append(result.Nodes, tb.results[config.Name])
+	}
+	if blob, err = json.MarshalIndent(result, "", "  "); err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "results.json"), blob, 0644); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// start creates and starts the nodes.
+func (tb *testbed) start() error {
+	for _, config := range tb.scenario.Nodes {
+		key, err := nodeKey(tb.scenario.Seed, config.Name)
+		if err != nil {
+			return err
+		}
+		nodeConfig := adapters.RandomNodeConfig()
+		nodeConfig.ID = enode.PubkeyToIDV4(&key.PublicKey)
+		nodeConfig.PrivateKey = key
+		nodeConfig.Name = config.Name
+		nodeConfig.Lifecycles = []string{serviceName}
+
+		if err := os.MkdirAll(filepath.Join(tb.dir, "nodes", config.Name), 0755); err != nil {
+			return err
+		}
+		if _, err := tb.network.NewNodeWithConfig(nodeConfig); err != nil {
+			return fmt.Errorf("node %q: %v", config.Name, err)
+		}
+		tb.nodes[config.Name] = nodeConfig
+		tb.configs[nodeConfig.ID] = config
+
+		result := &NodeResult{
+			Name:     config.Name,
+			ID:       nodeConfig.ID,
+			Strategy: config.Strategy,
+			Hashrate: config.Hashrate,
+			Coinbase: crypto.PubkeyToAddress(key.PublicKey),
+		}
+		if result.Strategy == "" {
+			result.Strategy = logic.HONEST
+		}
+		tb.results[config.Name] = result
+	}
+	for _, config := range tb.scenario.Nodes {
+		if err := tb.network.Start(tb.nodes[config.Name].ID); err != nil {
+			return fmt.Errorf("node %q: %v", config.Name, err)
+		}
+	}
+	return nil
+}
+
+// newEthereum creates the eth service of a node of the testbed.
+func (tb *testbed) newEthereum(ctx *adapters.ServiceContext, stack *node.Node) (node.Lifecycle, error) {
+	nodeConfig, ok := tb.configs[ctx.Config.ID]
+	if !ok {
+		return nil, fmt.Errorf("unknown node %s", ctx.Config.ID)
+	}
+	config := ethconfig.Defaults
+	config.Genesis = Genesis()
+	config.NetworkId = config.Genesis.Config.ChainID.Uint64()
+	config.SyncMode = downloader.FullSync
+	config.DatabaseCache = 16
+	config.TrieCleanCache = 16
+	config.TrieDirtyCache = 16
+	config.SnapshotCache = 0
+	config.TxPool.Journal = ""
+
+	// Emulate the hashrate share instead of burning the CPU on proof-of-work
+	config.Ethash.PowMode = ethash.ModePoisson
+	config.Ethash.Hashrate = nodeConfig.Hashrate
+	config.Ethash.BlockInterval = time.Duration(tb.scenario.BlockInterval)
+	config.Ethash.ScaleDifficulty = tb.scenario.ScaleDifficulty
+
+	config.ExperimentSeed = nodeSeed(tb.scenario.Seed, nodeConfig.Name)
+	config.ForkChoice.Rule = nodeConfig.ForkChoice
+
+	config.Miner.Etherbase = tb.results[nodeConfig.Name].Coinbase
+	config.Miner.MinerStrategy = nodeConfig.Strategy
+	config.Miner.StrategyConfig = *nodeConfig.strategyConfig()
+	config.Miner.Comparison = nodeConfig.comparison()
+	if nodeConfig.Intermittent != nil {
+		config.Miner.Intermittent = nodeConfig.Intermittent.config()
+	}
+	config.Miner.LogFile = filepath.Join(tb.dir, "nodes", nodeConfig.Name, "events.jsonl")
+
+	for _, name := range nodeConfig.Eclipse {
+		config.Miner.EclipsePeers = append(config.Miner.EclipsePeers, tb.nodes[name].Node().URLv4())
+	}
+	if nodeConfig.EclipsePolicy != nil {
+		config.Miner.Eclipse = *nodeConfig.EclipsePolicy
+	}
+	for _, name := range nodeConfig.Pool {
+		config.Miner.Pool = append(config.Miner.Pool, tb.nodes[name].Node().URLv4())
+	}
+	return eth.New(stack, &config)
+}
+
+// connect connects the linked nodes and waits until all connections are up.
+func (tb *testbed) connect() error {
+	peers := make(map[string]int)
+	for _, link := range tb.scenario.links() {
+		if err := tb.network.Connect(tb.nodes[link[0]].ID, tb.nodes[link[1]].ID); err != nil {
+			return fmt.Errorf("failed to connect %q to %q: %v", link[0], link[1], err)
+		}
+		peers[link[0]]++
+		peers[link[1]]++
+	}
+	deadline := time.Now().Add(connectTimeout)
+	for _, config := range tb.scenario.Nodes {
+		client, err := tb.network.GetNodeByName(config.Name).Client()
+		if err != nil {
+			return err
+		}
+		for {
+			var infos []*p2p.PeerInfo
+			if err := client.Call(&infos, "admin_peers"); err != nil {
+				return err
+			}
+			if len(infos) >= peers[config.Name] {
+				break
+			}
+			if time.Now().After(deadline) {
+				return fmt.Errorf("node %q connected to %d of %d peers", config.Name, len(infos), peers[config.Name])
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+	return nil
+}
+
+// mine lets the nodes mine for the duration of the scenario.
+func (tb *testbed) mine() error {
+	for _, config := range tb.scenario.Nodes {
+		if config.Hashrate == 0 {
+			continue
+		}
+		client, err := tb.network.GetNodeByName(config.Name).Client()
+		if err != nil {
+			return err
+		}
+		if err := client.Call(nil, "miner_start", 1); err != nil {
+			return fmt.Errorf("node %q failed to start mining: %v", config.Name, err)
+		}
+	}
+	log.Info("Testbed mining", "nodes", len(tb.scenario.Nodes), "duration", time.Duration(tb.scenario.Duration))
+	time.Sleep(time.Duration(tb.scenario.Duration))
+
+	for _, config := range tb.scenario.Nodes {
+		if config.Hashrate == 0 {
+			continue
+		}
+		client, err := tb.network.GetNodeByName(config.Name).Client()
+		if err != nil {
+			return err
+		}
+		if err := client.Call(nil, "miner_stop"); err != nil {
+			return fmt.Errorf("node %q failed to stop mining: %v", config.Name, err)
+		}
+	}
+	time.Sleep(settleTime)
+	return nil
+}
+
+// collect gathers the chain outcome of a node.
+func (tb *testbed) collect(name string) error {
+	client, err := tb.network.GetNodeByName(name).Client()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	result := tb.results[name]
+	header, err := ethclient.NewClient(client).HeaderByNumber(ctx, nil)
+	if err != nil {
+		return err
+	}
+	result.Head, result.HeadHash = header.Number.Uint64(), header.Hash()
+
+	var infos []*p2p.PeerInfo
+	if err := client.CallContext(ctx, &infos, "admin_peers"); err != nil {
+		return err
+	}
+	result.Peers = len(infos)
+
+	if result.Head > 0 {
+		if result.Revenue, err = gethclient.New(client).Revenue(ctx, 1, result.Head, tb.scenario.RevenueWindow); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nodeKey derives the key of a node from the seed of the scenario, so that the
+// node IDs and coinbases are the same in every run of a scenario.
+func nodeKey(seed int64, name string) (*ecdsa.PrivateKey, error) {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], uint64(seed))
+	return crypto.ToECDSA(crypto.Keccak256(buf[:], []byte(name)))
+}
+
+// nodeSeed derives the seed of the random choices of a node from the seed of the
+// scenario. A zero scenario seed leaves the choices random.
+func nodeSeed(seed int64, name string) int64 {
+	if seed == 0 {
+		return 0
+	}
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], uint64(seed))
+	return int64(binary.BigEndian.Uint64(crypto.Keccak256(buf[:], []byte(name), []byte("seed"))))
+}
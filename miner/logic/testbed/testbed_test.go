@@ -0,0 +1,109 @@
+package testbed
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/miner/logic"
+)
+
+func TestScenarioValidate(t *testing.T) {
+	tests := []struct {
+		scenario string
+		valid    bool
+	}{
+		{`{"duration": "10s", "nodes": [{"name": "a", "hashrate": 0.5}, {"name": "b", "hashrate": 0.5}]}`, true},
+		{`{"duration": "10s", "nodes": [{"name": "a", "strategy": "unknown"}]}`, false},
+		{`{"duration": "10s", "nodes": [{"name": "a", "hashrate": 0.7}, {"name": "b", "hashrate": 0.7}]}`, false},
+		{`{"duration": "10s", "nodes": [{"name": "a"}, {"name": "a"}]}`, false},
+		{`{"duration": "10s", "nodes": [{"name": "a", "eclipse": ["c"]}, {"name": "b"}]}`, false},
+		{`{"duration": "10s", "nodes": [{"name": "a"}, {"name": "b"}], "links": [["a", "c"]]}`, false},
+		{`{"nodes": [{"name": "a"}]}`, false},
+		{`{"duration": "10s", "nodes": [{"name": "a", "strategy": "selfish-all-uncles", "intermittent": {"schedule": "time", "selfishTime": "1m", "honestTime": "30s"}}]}`, true},
+		{`{"duration": "10s", "nodes": [{"name": "a", "intermittent": {"schedule": "blocks", "selfishBlocks": 2, "honestBlocks": 1}}]}`, false},
+		{`{"duration": "10s", "nodes": [{"name": "a", "strategy": "selfish-all-uncles", "intermittent": {"schedule": "difficulty"}}]}`, false},
+		{`{"duration": "10s", "nodes": [{"name": "a", "strategy": "selfish-all-uncles", "comparison": "td", "tieBlocks": 0.5}]}`, true},
+		{`{"duration": "10s", "nodes": [{"name": "a", "strategy": "selfish-all-uncles", "comparison": "weight"}]}`, false},
+		{`{"duration": "10s", "nodes": [{"name": "a", "strategy": "selfish-all-uncles", "pool": ["b"]}, {"name": "b", "strategy": "selfish-all-uncles", "pool": ["a"]}]}`, true},
+		{`{"duration": "10s", "nodes": [{"name": "a", "pool": ["b"]}, {"name": "b", "strategy": "selfish-all-uncles"}]}`, false},
+		{`{"duration": "10s", "nodes": [{"name": "a", "strategy": "selfish-all-uncles", "pool": ["a"]}]}`, false},
+		{`{"duration": "10s", "nodes": [{"name": "a", "hashrate": 0.5, "forkChoice": "publish-or-perish"}]}`, true},
+		{`{"duration": "10s", "nodes": [{"name": "a", "forkChoice": "longest"}]}`, false},
+		{`{"duration": "10s", "nodes": [{"name": "a", "eclipse": ["b"], "eclipsePolicy": {"blocks": "delay", "blockDelay": "2s", "filterChain": true}}, {"name": "b"}]}`, true},
+		{`{"duration": "10s", "nodes": [{"name": "a", "eclipse": ["b"], "eclipsePolicy": {"txs": "delay"}}, {"name": "b"}]}`, false},
+		{`{"duration": "10s", "nodes": [{"name": "a", "eclipse": ["b"], "eclipsePolicy": {"blocks": "drop"}}, {"name": "b"}]}`, false},
+	}
+	for i, test := range tests {
+		scenario := new(Scenario)
+		if err := json.Unmarshal([]byte(test.scenario), scenario); err != nil {
+			t.Fatalf("test %d: failed to decode scenario: %v", i, err)
+		}
+		if err := scenario.Validate(); (err == nil) != test.valid {
+			t.Errorf("test %d: validation error %v, want valid %t", i, err, test.valid)
+		}
+	}
+}
+
+func TestScenarioLinks(t *testing.T) {
+	scenario := &Scenario{
+		Nodes: []NodeConfig{{Name: "a"}, {Name: "b"}, {Name: "c", Eclipse: []string{"a"}}},
+	}
+	links := scenario.links()
+	if len(links) != 3 {
+		t.Fatalf("full mesh has %d links, want 3", len(links))
+	}
+	// Explicit links are kept, pool members are connected on top
+	scenario.Links = [][2]string{{"a", "c"}}
+	scenario.Nodes[1].Pool = []string{"c"}
+	links = scenario.links()
+	if len(links) != 2 || links[0] != [2]string{"a", "c"} || links[1] != [2]string{"b", "c"} {
+		t.Errorf("links mismatch: have %v", links)
+	}
+}
+
+func TestRun(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping testbed run in short mode")
+	}
+	dir, err := ioutil.TempDir("", "testbed-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	scenario := &Scenario{
+		Duration:      Duration(5 * time.Second),
+		Seed:          2,
+		BlockInterval: Duration(time.Second),
+		Nodes: []NodeConfig{
+			{Name: "attacker", Strategy: logic.SelfishAllUncles, Hashrate: 0.5, Eclipse: []string{"victim"}},
+			{Name: "honest", Hashrate: 0.5},
+			{Name: "victim"},
+		},
+		Links: [][2]string{{"attacker", "honest"}, {"honest", "victim"}, {"victim", "attacker"}},
+	}
+	result, err := Run(scenario, dir)
+	if err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	if len(result.Nodes) != 3 {
+		t.Fatalf("got %d node results, want 3", len(result.Nodes))
+	}
+	for _, node := range result.Nodes {
+		if node.Peers != 2 {
+			t.Errorf("node %s has %d peers, want 2", node.Name, node.Peers)
+		}
+	}
+	if result.Nodes[1].Head == 0 {
+		t.Errorf("no blocks were mined")
+	}
+	for _, file := range []string{"scenario.json", "results.json", "nodes/attacker/events.jsonl"} {
+		if _, err := os.Stat(filepath.Join(dir, file)); err != nil {
+			t.Errorf("missing result file: %v", err)
+		}
+	}
+}
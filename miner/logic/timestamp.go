@@ -0,0 +1,45 @@
+package logic
+
+import (
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Timestamp policies of the private blocks.
+const (
+	TimestampWall          = "wall"           // the wall clock, like an honest miner
+	TimestampMaxDifficulty = "max-difficulty" // the timestamp that maximizes the difficulty of the block
+)
+
+// validTimestampPolicy reports whether the timestamp policy is known. An empty
+// policy stands for the wall clock.
+func validTimestampPolicy(policy string) bool {
+	switch policy {
+	case "", TimestampWall, TimestampMaxDifficulty:
+		return true
+	}
+	return false
+}
+
+// ChooseTimestamp returns the timestamp of a private block mined on the given
+// parent at the given wall clock time, following the timestamp policy.
+//
+// The ethash difficulty falls with the time elapsed since the parent, so the
+// max-difficulty policy picks the earliest timestamp the consensus rules accept,
+// one second after the parent, if it yields a higher difficulty than the wall
+// clock. Being in the past, it is never rejected as a future block. The private
+// chain thus gains more total difficulty per block than the public chain, which
+// wins it the races decided by total difficulty.
+func ChooseTimestamp(policy string, engine consensus.Engine, chain consensus.ChainHeaderReader, parent *types.Header, now uint64) uint64 {
+	earliest := parent.Time + 1
+	if now < earliest {
+		now = earliest
+	}
+	if policy != TimestampMaxDifficulty {
+		return now
+	}
+	if engine.CalcDifficulty(chain, earliest, parent).Cmp(engine.CalcDifficulty(chain, now, parent)) <= 0 {
+		return now
+	}
+	return earliest
+}
@@ -0,0 +1,43 @@
+package logic
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func TestChooseTimestamp(t *testing.T) {
+	chain, _ := newTestChain(t, &core.Genesis{Config: params.TestChainConfig})
+	defer chain.Stop()
+
+	var (
+		engine = ethash.NewFaker()
+		parent = &types.Header{Number: big.NewInt(1), Time: 100, Difficulty: big.NewInt(1000000), UncleHash: types.EmptyUncleHash}
+	)
+	tests := []struct {
+		policy string
+		now    uint64
+		want   uint64
+	}{
+		{TimestampWall, 200, 200},
+		{TimestampWall, 50, 101},
+		{TimestampMaxDifficulty, 200, 101},
+		{TimestampMaxDifficulty, 50, 101},
+		{TimestampMaxDifficulty, 105, 105}, // same difficulty as one second after the parent
+	}
+	for i, tt := range tests {
+		if have := ChooseTimestamp(tt.policy, engine, chain, parent, tt.now); have != tt.want {
+			t.Errorf("test %d: timestamp mismatch: have %d, want %d", i, have, tt.want)
+		}
+	}
+	if backdated, wall := engine.CalcDifficulty(chain, 101, parent), engine.CalcDifficulty(chain, 200, parent); backdated.Cmp(wall) <= 0 {
+		t.Errorf("backdated difficulty %v not above wall clock difficulty %v", backdated, wall)
+	}
+	if _, err := New(SelfishAllUncles, &Config{Timestamps: "future"}); err == nil {
+		t.Errorf("unknown timestamp policy accepted")
+	}
+}
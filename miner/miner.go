@@ -44,17 +44,25 @@ type Backend interface {
 
 // Config is the configuration parameters of mining.
 type Config struct {
-	Etherbase           common.Address `toml:",omitempty"` // Public address for block mining rewards (default = first account)
-	Notify              []string       `toml:",omitempty"` // HTTP URL list to be notified of new work packages (only useful in ethash).
-	NotifyFull          bool           `toml:",omitempty"` // Notify with pending block headers instead of work packages
-	ExtraData           hexutil.Bytes  `toml:",omitempty"` // Block extra data set by the miner
-	GasFloor            uint64         // Target gas floor for mined blocks.
-	GasCeil             uint64         // Target gas ceiling for mined blocks.
-	GasPrice            *big.Int       // Minimum gas price for mining a transaction
-	Recommit            time.Duration  // The time interval for miner to re-create mining work.
-	Noverify            bool           // Disable remote mining solution verification(only useful in ethash).
-	MinerStrategy       logic.Strategy // Strategy the miner should use
+	Etherbase           common.Address           `toml:",omitempty"` // Public address for block mining rewards (default = first account)
+	Notify              []string                 `toml:",omitempty"` // HTTP URL list to be notified of new work packages (only useful in ethash).
+	NotifyFull          bool                     `toml:",omitempty"` // Notify with pending block headers instead of work packages
+	ExtraData           hexutil.Bytes            `toml:",omitempty"` // Block extra data set by the miner
+	GasFloor            uint64                   // Target gas floor for mined blocks.
+	GasCeil             uint64                   // Target gas ceiling for mined blocks.
+	GasPrice            *big.Int                 // Minimum gas price for mining a transaction
+	Recommit            time.Duration            // The time interval for miner to re-create mining work.
+	Noverify            bool                     // Disable remote mining solution verification(only useful in ethash).
+	MinerStrategy       string                   // Name of the strategy the miner should use
+	StrategyConfig      logic.Config             // Parameters of the configurable strategies
+	Intermittent        logic.IntermittentConfig // Alternation of the strategy with honest mining
 	EclipsePeers        []string
+	Eclipse             logic.EclipsePolicy    // Treatment of the eclipsed peers
+	Race                logic.RaceConfig       // Propagation of blocks racing against the public chain
+	Comparison          logic.ComparisonConfig // Comparison of the private and the public chain
+	Pool                []string               // Enode URLs of the colluding nodes sharing the private chain
+	LogFile             string                 `toml:",omitempty"` // Path of the structured mining event log
+	LogFileSize         uint                   // Size in megabytes at which the mining event log is rotated (0 = never)
 	PrivateChain        *core.BlockChain
 	PrivateChainConfig  *params.ChainConfig
 	PrivateChainEngine  consensus.Engine
@@ -178,8 +186,11 @@ func (miner *Miner) Mining() bool {
 	return miner.worker.isRunning()
 }
 
+// Hashrate returns the hashrate of the engine the miner currently mines with,
+// which depends on the strategy.
 func (miner *Miner) Hashrate() uint64 {
-	if pow, ok := miner.engine.(consensus.PoW); ok {
+	_, engine := miner.worker.miningChain()
+	if pow, ok := engine.(consensus.PoW); ok {
 		return uint64(pow.Hashrate())
 	}
 	return 0
@@ -193,6 +204,13 @@ func (miner *Miner) SetExtra(extra []byte) error {
 	return nil
 }
 
+// SetStrategy switches the mining strategy of the running miner. The blocks
+// withheld by a selfish strategy are published if publish is set, and discarded
+// otherwise.
+func (miner *Miner) SetStrategy(strategy logic.Strategy, publish bool) {
+	miner.worker.setStrategy(strategy, publish)
+}
+
 // SetRecommitInterval sets the interval for sealing work resubmitting.
 func (miner *Miner) SetRecommitInterval(interval time.Duration) {
 	miner.worker.setRecommitInterval(interval)
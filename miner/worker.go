@@ -99,6 +99,7 @@ type task struct {
 	receipts  []*types.Receipt
 	state     *state.StateDB
 	block     *types.Block
+	chain     *core.BlockChain // chain the block extends, which changes with the strategy
 	createdAt time.Time
 }
 
@@ -115,6 +116,13 @@ type newWorkReq struct {
 	timestamp int64
 }
 
+// strategyReq represents a request to switch the mining strategy, the done
+// channel is closed once the worker mines on the chain of the new strategy.
+type strategyReq struct {
+	strategy logic.Strategy
+	done     chan struct{}
+}
+
 // intervalAdjust represents a resubmitting interval adjustment.
 type intervalAdjust struct {
 	ratio float64
@@ -131,6 +139,7 @@ type worker struct {
 	publicChain  *core.BlockChain
 	privateChain *core.BlockChain
 	chain        *core.BlockChain // chain that the worker is working on (privateChain for selfish miner, publicChain for honest miner)
+	chainMu      sync.RWMutex     // The lock used to protect the chain, chainConfig, engine and unconfirmed fields against strategy switches
 
 	privateBranchLength *int
 	nextToPublish       *int
@@ -158,6 +167,7 @@ type worker struct {
 	exitCh             chan struct{}
 	resubmitIntervalCh chan time.Duration
 	resubmitAdjustCh   chan *intervalAdjust
+	strategyCh         chan *strategyReq
 
 	wg sync.WaitGroup
 
@@ -167,6 +177,7 @@ type worker struct {
 	unconfirmed  *unconfirmedBlocks           // A set of locally mined blocks pending canonicalness confirmations.
 
 	mu       sync.RWMutex // The lock used to protect the coinbase and extra fields
+	switchMu sync.Mutex   // The lock used to serialize strategy switches
 	coinbase common.Address
 	extra    []byte
 
@@ -210,7 +221,7 @@ func newWorker(config *Config, chainConfig *params.ChainConfig, engine consensus
 		privateChain:        config.PrivateChain,
 		privateBranchLength: config.PrivateBranchLength,
 		nextToPublish:       config.NextToPublish,
-		minerStrategy:       config.MinerStrategy,
+		minerStrategy:       config.MiningData.Strategy(),
 		MiningData:          config.MiningData,
 		merger:              merger,
 		isLocalBlock:        isLocalBlock,
@@ -228,6 +239,7 @@ func newWorker(config *Config, chainConfig *params.ChainConfig, engine consensus
 		startCh:             make(chan struct{}, 1),
 		resubmitIntervalCh:  make(chan time.Duration),
 		resubmitAdjustCh:    make(chan *intervalAdjust, resubmitAdjustChanSize),
+		strategyCh:          make(chan *strategyReq),
 	}
 
 	if worker.minerStrategy.IsHonest() {
@@ -249,7 +261,7 @@ func newWorker(config *Config, chainConfig *params.ChainConfig, engine consensus
 	worker.chainSideSub = worker.chain.SubscribeChainSideEvent(worker.chainSideCh)
 
 	// selfish miner needs to subscribe to block event of the public chain because all new blocks from public chain are possible uncles
-	if worker.minerStrategy.IsSelfish() {
+	if !worker.minerStrategy.IsHonest() {
 		worker.publicChain.SetChainBlockEventSubscription(worker.publicChainBlockCh)
 	}
 
@@ -294,6 +306,69 @@ func (w *worker) setExtra(extra []byte) {
 	w.extra = extra
 }
 
+// setStrategy switches the mining strategy, publishing or discarding the blocks
+// withheld by a selfish strategy. The worker moves to the chain of the new
+// strategy, the public chain for honest mining and the private chain otherwise.
+// Blocks sealed on the previous chain that are found in between are discarded
+// by the mining logic.
+func (w *worker) setStrategy(strategy logic.Strategy, publish bool) {
+	w.switchMu.Lock()
+	defer w.switchMu.Unlock()
+
+	w.MiningData.SetStrategy(strategy, publish)
+
+	req := &strategyReq{strategy: strategy, done: make(chan struct{})}
+	select {
+	case w.strategyCh <- req:
+		<-req.done
+	case <-w.exitCh:
+	}
+}
+
+// switchChain moves the worker to the chain of the given strategy, along with
+// the config and engine of that chain, and restarts sealing on its head.
+func (w *worker) switchChain(strategy logic.Strategy) {
+	chain := w.privateChain
+	if strategy.IsHonest() {
+		chain = w.publicChain
+	}
+	w.minerStrategy = strategy
+	if chain == w.chain {
+		return
+	}
+	w.chainHeadSub.Unsubscribe()
+	w.chainSideSub.Unsubscribe()
+
+	w.chainMu.Lock()
+	w.chain = chain
+	w.chainConfig = chain.Config()
+	w.engine = chain.Engine()
+	w.unconfirmed = newUnconfirmedBlocks(chain, miningLogAtDepth)
+	w.chainMu.Unlock()
+
+	w.chainHeadSub = chain.SubscribeChainHeadEvent(w.chainHeadCh)
+	w.chainSideSub = chain.SubscribeChainSideEvent(w.chainSideCh)
+
+	// selfish miner needs to subscribe to block event of the public chain because all new blocks from public chain are possible uncles
+	if strategy.IsHonest() {
+		w.publicChain.SetChainBlockEventSubscription(nil)
+	} else {
+		w.publicChain.SetChainBlockEventSubscription(w.publicChainBlockCh)
+	}
+	select {
+	case w.startCh <- struct{}{}:
+	default:
+	}
+}
+
+// miningChain returns the chain the worker is mining on and its engine.
+func (w *worker) miningChain() (*core.BlockChain, consensus.Engine) {
+	w.chainMu.RLock()
+	defer w.chainMu.RUnlock()
+
+	return w.chain, w.engine
+}
+
 // setRecommitInterval updates the interval for miner sealing work recommitting.
 func (w *worker) setRecommitInterval(interval time.Duration) {
 	w.resubmitIntervalCh <- interval
@@ -423,7 +498,8 @@ func (w *worker) newWorkLoop(recommit time.Duration) {
 	for {
 		select {
 		case <-w.startCh:
-			clearPending(w.chain.CurrentBlock().NumberU64())
+			chain, _ := w.miningChain()
+			clearPending(chain.CurrentBlock().NumberU64())
 			timestamp = time.Now().Unix()
 			commit(false, commitInterruptNewHead)
 
@@ -435,7 +511,10 @@ func (w *worker) newWorkLoop(recommit time.Duration) {
 		case <-timer.C:
 			// If mining is running resubmit a new work cycle periodically to pull in
 			// higher priced transactions. Disable this overhead for pending blocks.
-			if w.isRunning() && (w.chainConfig.Clique == nil || w.chainConfig.Clique.Period > 0) {
+			w.chainMu.RLock()
+			clique := w.chainConfig.Clique
+			w.chainMu.RUnlock()
+			if w.isRunning() && (clique == nil || clique.Period > 0) {
 				// Short circuit if no new transaction arrives.
 				if atomic.LoadInt32(&w.newTxs) == 0 {
 					timer.Reset(recommit)
@@ -484,8 +563,11 @@ func (w *worker) newWorkLoop(recommit time.Duration) {
 func (w *worker) mainLoop() {
 	defer w.wg.Done()
 	defer w.txsSub.Unsubscribe()
-	defer w.chainHeadSub.Unsubscribe()
-	defer w.chainSideSub.Unsubscribe()
+	defer func() {
+		// The subscriptions are replaced when switching strategies
+		w.chainHeadSub.Unsubscribe()
+		w.chainSideSub.Unsubscribe()
+	}()
 	defer func() {
 		if w.current != nil && w.current.state != nil {
 			w.current.state.StopPrefetcher()
@@ -503,6 +585,10 @@ func (w *worker) mainLoop() {
 		case ev := <-w.publicChainBlockCh:
 			w.onReceivedBlock(ev.Block)
 
+		case req := <-w.strategyCh:
+			w.switchChain(req.strategy)
+			close(req.done)
+
 		case ev := <-w.txsCh:
 			// Apply transactions to the pending state if we're not mining.
 			//
@@ -617,7 +703,8 @@ func (w *worker) taskLoop() {
 				w.newTaskHook(task)
 			}
 			// Reject duplicate sealing work due to resubmitting.
-			sealHash := w.engine.SealHash(task.block.Header())
+			chain, engine := w.miningChain()
+			sealHash := engine.SealHash(task.block.Header())
 			if sealHash == prev {
 				continue
 			}
@@ -633,7 +720,7 @@ func (w *worker) taskLoop() {
 			w.pendingMu.Unlock()
 
 			// #-# this is where we start mining on a new block and pass in our own result channel
-			if err := w.engine.Seal(w.chain, task.block, w.resultCh, stopCh); err != nil {
+			if err := engine.Seal(chain, task.block, w.resultCh, stopCh); err != nil {
 				w.pendingMu.Lock()
 				delete(w.pendingTasks, sealHash)
 				w.pendingMu.Unlock()
@@ -658,12 +745,13 @@ func (w *worker) resultLoop() {
 			}
 
 			// Short circuit when receiving duplicate result caused by resubmitting.
-			if w.chain.HasBlock(block.Hash(), block.NumberU64()) {
+			chain, engine := w.miningChain()
+			if chain.HasBlock(block.Hash(), block.NumberU64()) {
 				continue
 			}
 
 			var (
-				sealhash = w.engine.SealHash(block.Header())
+				sealhash = engine.SealHash(block.Header())
 				hash     = block.Hash()
 			)
 			w.pendingMu.RLock()
@@ -673,6 +761,10 @@ func (w *worker) resultLoop() {
 				log.Error("Block found but no relative pending task", "number", block.Number(), "sealhash", sealhash, "hash", hash)
 				continue
 			}
+			if task.chain != chain {
+				log.Debug("Discarding block sealed before strategy switch", "number", block.Number(), "sealhash", sealhash, "hash", hash)
+				continue
+			}
 			// Different block could share same sealhash, deep copy here to prevent write-write conflict.
 			var (
 				receipts = make([]*types.Receipt, len(task.receipts))
@@ -700,10 +792,12 @@ func (w *worker) resultLoop() {
 				logs = append(logs, receipt.Logs...)
 			}
 
-			logic.OnFoundBlock(w.MiningData, block, receipts, logs, task.state)
+			logic.OnFoundBlock(w.MiningData, task.chain, block, receipts, logs, task.state)
 
 			// Insert the block into the set of pending ones to resultLoop for confirmations
+			w.chainMu.RLock()
 			w.unconfirmed.Insert(block.NumberU64(), block.Hash())
+			w.chainMu.RUnlock()
 		case <-w.exitCh:
 			return
 		}
@@ -945,6 +1039,10 @@ func (w *worker) commitNewWork(interrupt *int32, noempty bool, timestamp int64)
 	if parent.Time() >= uint64(timestamp) {
 		timestamp = int64(parent.Time() + 1)
 	}
+	// Private blocks may be backdated to raise their difficulty
+	if !w.minerStrategy.IsHonest() {
+		timestamp = int64(logic.ChooseTimestamp(w.config.StrategyConfig.Timestamps, w.engine, w.chain, parent.Header(), uint64(timestamp)))
+	}
 	num := parent.Number()
 	header := &types.Header{
 		ParentHash: parent.Hash(),
@@ -1067,20 +1165,7 @@ func (w *worker) commit(uncles []*types.Header, interval func(), update bool, st
 	receipts := copyReceipts(w.current.receipts)
 	s := w.current.state.Copy()
 
-	var filteredUncles []*types.Header
-
-	switch w.minerStrategy {
-	case logic.SelfishNoUncles:
-		filteredUncles = nil
-	case logic.SelfishOwnUncles:
-		for _, uncle := range uncles {
-			if w.isLocalBlock(uncle) {
-				filteredUncles = append(filteredUncles, uncle)
-			}
-		}
-	case logic.SelfishAllUncles, logic.HONEST:
-		filteredUncles = uncles
-	}
+	filteredUncles := w.minerStrategy.FilterUncles(uncles, w.isLocalBlock)
 
 	block, err := w.engine.FinalizeAndAssemble(w.chain, w.current.header, s, w.current.txs, filteredUncles, receipts)
 	if err != nil {
@@ -1099,7 +1184,7 @@ func (w *worker) commit(uncles []*types.Header, interval func(), update bool, st
 		}
 		select {
 		// #-# control what block gets passed as block to be mined on (like private chain)
-		case w.taskCh <- &task{receipts: receipts, state: s, block: block, createdAt: time.Now()}:
+		case w.taskCh <- &task{receipts: receipts, state: s, block: block, chain: w.chain, createdAt: time.Now()}:
 			w.unconfirmed.Shift(block.NumberU64() - 1)
 			log.Info("Commit new mining work", "number", block.Number(), "sealhash", w.engine.SealHash(block.Header()),
 				"uncles", len(filteredUncles), "txs", w.current.tcount,
@@ -30,11 +30,13 @@ import (
 	"github.com/ethereum/go-ethereum/consensus/ethash"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/miner/logic"
 	"github.com/ethereum/go-ethereum/params"
 )
 
@@ -521,3 +523,82 @@ func testAdjustInterval(t *testing.T, chainConfig *params.ChainConfig, engine co
 		t.Error("interval reset timeout")
 	}
 }
+
+// Tests that a block sealed on the public chain, and found after the strategy
+// switched to selfish mining but before the worker moved to the private chain,
+// is not written into the private chain.
+func TestSwitchStrategyDiscardsStaleBlock(t *testing.T) {
+	var (
+		engine  = ethash.NewFaker()
+		db      = rawdb.NewMemoryDatabase()
+		backend = newTestWorkerBackend(t, ethashChainConfig, engine, db, 0)
+	)
+	defer backend.chain.Stop()
+
+	privateDb := rawdb.NewMemoryDatabase()
+	backend.genesis.MustCommit(privateDb)
+	privateChain, err := core.NewBlockChain(privateDb, nil, ethashChainConfig, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create private chain: %v", err)
+	}
+	defer privateChain.Stop()
+
+	forks, err := backend.chain.NewForkTree(0)
+	if err != nil {
+		t.Fatalf("failed to create fork tree: %v", err)
+	}
+	honest, _ := logic.New(logic.HONEST, nil)
+	selfish, _ := logic.New(logic.SelfishAllUncles, nil)
+	branchLength, next := 0, 1
+	config := *testConfig
+	config.PrivateChain = privateChain
+	config.PrivateChainConfig = ethashChainConfig
+	config.PrivateChainEngine = engine
+	config.PrivateBranchLength = &branchLength
+	config.NextToPublish = &next
+	config.MiningData = &logic.MiningData{
+		PublicChain:         backend.chain,
+		PrivateChain:        privateChain,
+		PrivateBranchLength: &branchLength,
+		NextToPublish:       &next,
+		MinerStrategy:       honest,
+		EventMux:            new(event.TypeMux),
+		PrivateChainDb:      privateDb,
+		ForkTree:            forks,
+	}
+	w := newWorker(&config, ethashChainConfig, engine, backend, new(event.TypeMux), nil, false, consensus.NewMerger(rawdb.NewMemoryDatabase()))
+	defer w.close()
+
+	// Build a block on the public chain, as the honest worker would have sealed
+	genesis := backend.chain.Genesis()
+	blocks, _ := core.GenerateChain(ethashChainConfig, genesis, engine, db, 1, func(i int, gen *core.BlockGen) {
+		gen.SetCoinbase(testBankAddress)
+	})
+	statedb, err := state.New(genesis.Root(), backend.chain.StateCache(), nil)
+	if err != nil {
+		t.Fatalf("failed to open genesis state: %v", err)
+	}
+	receipts, _, _, err := backend.chain.Processor().Process(blocks[0], statedb, vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to process block: %v", err)
+	}
+	w.pendingMu.Lock()
+	w.pendingTasks[engine.SealHash(blocks[0].Header())] = &task{receipts: receipts, state: statedb, block: blocks[0], chain: backend.chain, createdAt: time.Now()}
+	w.pendingMu.Unlock()
+
+	// Switch the strategy without moving the worker yet, then deliver the block
+	w.MiningData.SetStrategy(selfish, false)
+	w.resultCh <- blocks[0]
+
+	for len(w.resultCh) > 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	if privateChain.HasBlock(blocks[0].Hash(), 1) {
+		t.Errorf("stale block written into the private chain")
+	}
+	if backend.chain.HasBlock(blocks[0].Hash(), 1) {
+		t.Errorf("stale block written into the public chain")
+	}
+}